@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/batch"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newBatchCmd() *cobra.Command {
+	// Create batch command that runs every request in a JSONL file
+	batchCmd := &cobra.Command{
+		Use:   "batch --model <model> --input <file> --output <file>",
+		Short: "Run the chat requests in a JSONL file and write the responses to another",
+		Long: `Run the chat requests in a JSONL file and write the responses to another.
+
+Each input line is a JSON object with a "prompt" or "messages" (in the chat
+completions format), and optionally an "id", a "system" prompt and "options"
+overriding the sampling parameters, e.g.
+
+  {"id": "q1", "prompt": "What is the capital of France?", "options": {"temperature": 0}}
+  {"messages": [{"role": "user", "content": "Say hi"}], "system": "Be brief."}
+
+Each output line holds the request's id and input line, the answer content,
+the full chat completion response with token usage, the number of attempts,
+the start time and the latency. Lines are written as requests finish, so
+after an interruption --resume runs only the requests that have not
+succeeded yet and appends their results to the output.`,
+		Args: cobra.NoArgs,
+		RunE: batchHandler,
+	}
+	batchCmd.Flags().String("model", "", "LLM to run the requests with, as <model> or <model_server>/<model>")
+	batchCmd.Flags().StringP("input", "i", "", "JSONL file of requests")
+	batchCmd.Flags().StringP("output", "o", "", "JSONL file to write the results to")
+	batchCmd.Flags().Int("concurrency", batch.DefaultConcurrency, "Number of requests sent at once")
+	batchCmd.Flags().Int("retries", batch.DefaultRetries, "Times to retry a failed request")
+	batchCmd.Flags().Bool("resume", false, "Skip requests that already succeeded in the output file and append to it")
+	batchCmd.Flags().String("system", "", "System prompt for requests without one (a Go text/template)")
+	batchCmd.Flags().String("persona", "", "Persona from ~/.golms/personas/ to use")
+	addSamplingFlags(batchCmd)
+	for _, name := range []string{"model", "input", "output"} {
+		batchCmd.MarkFlagRequired(name)
+	}
+
+	return batchCmd
+}
+
+// ==================== Command Handlers ====================
+func batchHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so progress can be followed while output is redirected
+	stderr := os.Stderr
+
+	modelName, _ := cmd.Flags().GetString("model")
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	retries, _ := cmd.Flags().GetInt("retries")
+	resume, _ := cmd.Flags().GetBool("resume")
+	if concurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+	if retries < 0 {
+		return errors.New("--retries must not be negative")
+	}
+
+	model, err := resolveModel(modelName)
+	if err != nil {
+		return err
+	}
+	chatOptions, systemPrompt, _, err := loadChatOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Read every request up front so a malformed file fails before any work is done
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	requests, err := batch.ReadRequests(input)
+	input.Close()
+	if err != nil {
+		return fmt.Errorf("invalid input %s: %w", inputPath, err)
+	}
+
+	// Apply the default system prompt to requests without their own
+	if systemPrompt != "" {
+		system, err := persona.Render(systemPrompt, persona.CurrentVars(model.Name))
+		if err != nil {
+			return err
+		}
+		for i := range requests {
+			if requests[i].System == "" && (len(requests[i].Messages) == 0 || requests[i].Messages[0].Role != "system") {
+				requests[i].System = system
+			}
+		}
+	}
+
+	// Find the requests finished by an earlier run, refusing to overwrite its results otherwise
+	var completed map[string]bool
+	truncated := false
+	flags := os.O_CREATE | os.O_WRONLY | os.O_EXCL
+	if resume {
+		if previous, err := os.ReadFile(outputPath); err == nil {
+			completed, err = batch.Completed(bytes.NewReader(previous))
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", outputPath, err)
+			}
+			truncated = len(previous) > 0 && previous[len(previous)-1] != '\n'
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	output, err := os.OpenFile(outputPath, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists, use --resume to continue that run or remove it", outputPath)
+	}
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	if truncated {
+		// End the line cut off by the interruption so the new results start on their own line
+		if _, err := output.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	// Start model server if needed, stopping it again once we are done
+	modelServerManager, port, started, err := startModelServer(stderr, model.Server, model.Name)
+	if err != nil {
+		return err
+	}
+	if started {
+		defer modelServerManager.Stop()
+	}
+
+	total := 0
+	for _, req := range requests {
+		if !completed[req.ID] {
+			total++
+		}
+	}
+	if skipped := len(requests) - total; skipped > 0 {
+		fmt.Fprintln(stderr, ui.SubtleStyle.Render(fmt.Sprintf("Resuming: %d requests already completed", skipped)))
+	}
+	done := 0
+	runner := &batch.Runner{
+		Client:      client.NewClient(model.Server, model.Name, constants.Localhost, port),
+		Options:     chatOptions,
+		Concurrency: concurrency,
+		Retries:     retries,
+		Backoff:     batch.DefaultBackoff,
+		Skip:        completed,
+		OnResult: func(result batch.Result) {
+			done++
+			progress := fmt.Sprintf("[%d/%d] %s", done, total, result.ID)
+			if result.Error != "" {
+				fmt.Fprintln(stderr, ui.FormatError(fmt.Sprintf("%s failed after %d attempts: %s", progress, result.Attempts, result.Error)))
+				return
+			}
+			fmt.Fprintln(stderr, ui.FormatSuccess(fmt.Sprintf("%s (%.1fs)", progress, float64(result.LatencyMS)/1000)))
+		},
+	}
+	// Ctrl+C stops the run, keeping the results written so far for --resume
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	summary, err := runner.Run(ctx, requests, output)
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(fmt.Sprintf("Failed to write results: %v", err)))
+		return err
+	}
+
+	fmt.Fprintln(stderr)
+	fmt.Fprintln(stderr, ui.FormatInfoBox(fmt.Sprintf("Succeeded: %d\nFailed: %d\nSkipped: %d\nResults: %s",
+		summary.Succeeded, summary.Failed, summary.Skipped, outputPath)))
+	if ctx.Err() != nil {
+		return errors.New("interrupted, run again with --resume to finish")
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d requests failed, run again with --resume to retry them", summary.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/chat"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newCompareCmd() *cobra.Command {
+	// Create compare command that chats with several LLMs side by side
+	compareCmd := &cobra.Command{
+		Use:   "compare --model <model> --model <model>...",
+		Short: "Send the same messages to several LLMs and compare their answers",
+		Long: `Send the same messages to several LLMs and compare their answers.
+
+Every message is sent to all models at once and their answers are shown side
+by side with their latency and token count. The answer you pick is kept in the
+history shared by all models for the following turns, e.g.
+
+  golms compare --model qwen3-8b --model mlx_lm/llama-3.2-3b`,
+		Args: cobra.NoArgs,
+		RunE: compareHandler,
+	}
+	compareCmd.Flags().StringArray("model", nil, "LLM to compare, as <model> or <model_server>/<model> (repeat for each model)")
+	compareCmd.Flags().Bool("raw", false, "Print answers as plain text instead of rendered markdown")
+	compareCmd.Flags().String("think", string(reasoning.Hide), "How to display model reasoning: show or hide")
+	compareCmd.Flags().String("think-context", string(chat.DefaultOptions.ReasoningContext), "Whether to keep or strip reasoning from the context sent back to the models")
+	compareCmd.Flags().String("system", "", "System prompt for the chat (a Go text/template)")
+	compareCmd.Flags().String("persona", "", "Persona from ~/.golms/personas/ to use for the chat")
+	addSamplingFlags(compareCmd)
+
+	return compareCmd
+}
+
+// ==================== Command Handlers ====================
+func compareHandler(cmd *cobra.Command, args []string) error {
+	modelNames, _ := cmd.Flags().GetStringArray("model")
+	if len(modelNames) < 2 {
+		return errors.New("compare needs at least two --model flags")
+	}
+
+	// Validate flags before starting any model server
+	thinkFlag, _ := cmd.Flags().GetString("think")
+	reasoningMode, err := reasoning.ParseMode(thinkFlag)
+	if err != nil {
+		return err
+	}
+	// Collapsed reasoning could not be expanded, since compare has no /think
+	if reasoningMode == reasoning.Collapse {
+		return errors.New("--think must be show or hide when comparing models")
+	}
+	thinkContextFlag, _ := cmd.Flags().GetString("think-context")
+	reasoningContext, err := reasoning.ParseContextMode(thinkContextFlag)
+	if err != nil {
+		return err
+	}
+	chatOptions, systemPrompt, _, err := loadChatOptions(cmd)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+
+	// Start every model server that is not running yet, stopping them again once we are done
+	var clients []client.ModelServerClient
+	var llms []string
+	for _, name := range modelNames {
+		model, err := resolveModel(name)
+		if err != nil {
+			fmt.Println(ui.FormatError(err.Error()))
+			return err
+		}
+
+		modelServerManager, port, started, err := startModelServer(os.Stdout, model.Server, model.Name)
+		if err != nil {
+			return err
+		}
+		if started {
+			defer modelServerManager.Stop()
+		}
+		clients = append(clients, client.NewClient(model.Server, model.Name, constants.Localhost, port))
+		llms = append(llms, model.Name)
+	}
+	fmt.Println(ui.FormatDivider())
+	fmt.Println()
+
+	// Markdown is only rendered when writing to a terminal
+	raw, _ := cmd.Flags().GetBool("raw")
+	options := chat.DefaultOptions
+	options.Raw = raw || !ui.IsTerminal()
+	options.Reasoning = reasoningMode
+	options.ReasoningContext = reasoningContext
+	if systemPrompt != "" {
+		options.System, err = persona.Render(systemPrompt, persona.CurrentVars(strings.Join(llms, ", ")))
+		if err != nil {
+			fmt.Println(ui.FormatError(err.Error()))
+			return err
+		}
+	}
+
+	// Will clean up with defer of modelServerManager.Stop()
+	return chat.NewCompare(clients, chatOptions, options).Start()
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/changminbark/golms/pkg/chattemplate"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newCompleteCmd() *cobra.Command {
+	// Create complete command that continues a raw prompt
+	completeCmd := &cobra.Command{
+		Use:   "complete <model> [prompt...]",
+		Short: "Continue a raw text prompt with a base model",
+		Long: `Continue a raw text prompt with a base model.
+
+The prompt is sent as is to the completion endpoint (/v1/completions, or
+/api/generate in raw mode for Ollama) instead of through the model's chat
+template, and the continuation is streamed to stdout. Text piped to stdin is
+appended to the prompt, e.g.
+
+  golms complete llama-3.2-1b "Once upon a time"
+  cat story.txt | golms complete llama-3.2-1b --max-tokens 200
+
+With --template the prompt is sent as a user message formatted with a chat
+template instead, whose end of turn is added to the stop sequences:
+
+  golms complete qwen2.5-0.5b --template chatml --system "Be brief." "What is Go?"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: completeHandler,
+	}
+	completeCmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml serving the model")
+	completeCmd.Flags().String("template", "", fmt.Sprintf("Chat template to format the prompt with: %s", strings.Join(chattemplate.Names(), ", ")))
+	completeCmd.Flags().String("system", "", "System prompt for --template (a Go text/template)")
+	completeCmd.Flags().Bool("echo", false, "Print the prompt before its continuation")
+	addSamplingFlags(completeCmd)
+
+	return completeCmd
+}
+
+// ==================== Command Handlers ====================
+func completeHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so stdout only carries the text
+	stderr := os.Stderr
+
+	endpointName, _ := cmd.Flags().GetString("endpoint")
+	templateName, _ := cmd.Flags().GetString("template")
+	echo, _ := cmd.Flags().GetBool("echo")
+	chatOptions, systemPrompt, _, err := loadChatOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if chatOptions.LogProbs > 0 {
+		return errors.New("--logprobs is not supported by golms complete")
+	}
+	if systemPrompt != "" && templateName == "" {
+		return errors.New("--system needs a chat template, set one with --template")
+	}
+
+	prompt := strings.Join(args[1:], " ")
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		stdin, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if prompt != "" && len(stdin) > 0 {
+			prompt += "\n"
+		}
+		prompt += string(stdin)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return errors.New("no prompt given and nothing piped to stdin")
+	}
+
+	if templateName != "" {
+		tmpl, err := chattemplate.Lookup(templateName)
+		if err != nil {
+			return err
+		}
+		messages := []client.Message{}
+		if systemPrompt != "" {
+			system, err := persona.Render(systemPrompt, persona.CurrentVars(args[0]))
+			if err != nil {
+				return err
+			}
+			messages = append(messages, client.Message{Role: "system", Content: system})
+		}
+		messages = append(messages, client.Message{Role: "user", Content: prompt})
+		if prompt, err = tmpl.Prompt(messages); err != nil {
+			return err
+		}
+		chatOptions.Sampling.Stop = append(chatOptions.Sampling.Stop, tmpl.Stop...)
+	}
+
+	modelServerClient, _, stopServer, err := modelClient(cmd.Context(), stderr, endpointName, args[0])
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	// Ctrl+C cancels the generation and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	if echo {
+		fmt.Print(prompt)
+	}
+	chatOptions.Stream = true
+	_, err = modelServerClient.Complete(ctx, client.NewCompletionRequest(prompt, chatOptions), func(delta client.Delta) {
+		fmt.Print(delta.Content)
+	})
+	fmt.Println()
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(fmt.Sprintf("Failed to get completion: %v", err)))
+		return err
+	}
+	return nil
+}
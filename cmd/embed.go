@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/embedding"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newEmbedCmd() *cobra.Command {
+	// Create embed command that writes the embeddings of lines of text
+	embedCmd := &cobra.Command{
+		Use:   "embed --model <model> [file]",
+		Short: "Embed each line of a file or stdin and write the vectors as JSONL or .npy",
+		Long: `Embed each line of a file or stdin and write the vectors as JSONL or .npy.
+
+Blank lines are skipped. JSONL output has an object per line with its line
+number, text and embedding. NumPy output is a float32 matrix with a row per
+non-blank line, e.g. for np.load("vectors.npy"). The model is started and
+stopped like for golms run, or served by a remote endpoint with --endpoint.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: embedHandler,
+	}
+	embedCmd.Flags().String("model", "", "Embedding model, as <model> or <model_server>/<model>")
+	embedCmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml serving the embedding model")
+	embedCmd.Flags().StringP("output", "o", "", "File to write the vectors to instead of stdout")
+	embedCmd.Flags().StringP("format", "f", "", "Output format: jsonl or npy (default from the output file's extension, else jsonl)")
+	embedCmd.Flags().Int("batch-size", embedding.DefaultBatchSize, "Number of lines embedded per request")
+	embedCmd.MarkFlagRequired("model")
+
+	return embedCmd
+}
+
+// ==================== Command Handlers ====================
+func embedHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so stdout only carries the vectors
+	stderr := os.Stderr
+
+	modelName, _ := cmd.Flags().GetString("model")
+	endpointName, _ := cmd.Flags().GetString("endpoint")
+	outputPath, _ := cmd.Flags().GetString("output")
+	formatFlag, _ := cmd.Flags().GetString("format")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	if batchSize < 1 {
+		return errors.New("--batch-size must be at least 1")
+	}
+	format := embedding.FormatFromPath(outputPath)
+	if formatFlag != "" {
+		var err error
+		if format, err = embedding.ParseFormat(formatFlag); err != nil {
+			return err
+		}
+	}
+
+	// Read every line up front so bad input fails before a model server is started
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	inputs, err := embedding.ReadInputs(r)
+	if err != nil {
+		return err
+	}
+
+	modelServerClient, _, stopServer, err := modelClient(cmd.Context(), stderr, endpointName, modelName)
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	// Ctrl+C stops embedding and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	results, err := embedding.Embed(ctx, modelServerClient, inputs, batchSize, func(done, total int) {
+		fmt.Fprintf(stderr, "\r%s", ui.SubtleStyle.Render(fmt.Sprintf("Embedded %d/%d lines", done, total)))
+	})
+	fmt.Fprintln(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(err.Error()))
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := embedding.Write(w, results, format); err != nil {
+		return err
+	}
+	if outputPath != "" {
+		fmt.Fprintln(stderr, ui.FormatSuccess(fmt.Sprintf("Wrote %d embeddings of %d dimensions to %s", len(results), len(results[0].Embedding), outputPath)))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/changminbark/golms/pkg/fim"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newFIMCmd() *cobra.Command {
+	// Create fim command that completes code between a prefix and a suffix
+	fimCmd := &cobra.Command{
+		Use:   "fim <model> [file]",
+		Short: "Fill in the code between a prefix and a suffix with a code model",
+		Long: `Fill in the code between a prefix and a suffix with a code model.
+
+The prefix and suffix are given with --prefix and --suffix, or by splitting a
+file (or stdin) at the --at line:column position, e.g.
+
+  golms fim qwen2.5-coder-1.5b main.go --at 12:5
+  golms fim starcoder2-3b --prefix $'def add(a, b):\n    ' --suffix $'\n'
+
+They are formatted with the fill-in-the-middle tokens of the model's family,
+found from its name or set with --family, and sent to the completion endpoint.
+Only the infill is printed. Families are defined in a data file, and more can
+be added in ~/.golms/fim.yaml.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: fimHandler,
+	}
+	fimCmd.Flags().String("prefix", "", "Code before the infill")
+	fimCmd.Flags().String("suffix", "", "Code after the infill")
+	fimCmd.Flags().String("at", "", "Split the file at this line:column instead of its end")
+	fimCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	addFIMFlags(fimCmd)
+
+	return fimCmd
+}
+
+// addFIMFlags adds the flags that choose the model and its infill settings
+func addFIMFlags(cmd *cobra.Command) {
+	cmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml serving the model")
+	cmd.Flags().String("family", "", "FIM template family, instead of matching the model's name")
+	cmd.Flags().Int("max-tokens", fim.DefaultOptions.MaxTokens, "Maximum number of tokens to generate")
+	cmd.Flags().Float64("temperature", fim.DefaultOptions.Temperature, "Sampling temperature (0-2)")
+	cmd.Flags().StringArray("stop", nil, "Stop generating at this sequence (repeatable)")
+}
+
+// ==================== Command Handlers ====================
+func fimHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so stdout only carries the infill
+	stderr := os.Stderr
+
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("invalid --output %q (expected text or json)", output)
+	}
+	req, err := fimRequest(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	infiller, stopServer, err := newInfiller(cmd, stderr, args[0])
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	// Ctrl+C cancels the generation and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	resp, err := infiller.Infill(ctx, req)
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(fmt.Sprintf("Failed to get infill: %v", err)))
+		return err
+	}
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(resp)
+	}
+	fmt.Print(resp.Text)
+	if ui.IsTerminal() {
+		fmt.Println()
+	}
+	return nil
+}
+
+// ==================== Helper Functions ====================
+
+// fimRequest builds the request from --prefix and --suffix, or from a file
+// or stdin split at --at
+func fimRequest(cmd *cobra.Command, args []string) (fim.Request, error) {
+	var req fim.Request
+	if cmd.Flags().Changed("prefix") || cmd.Flags().Changed("suffix") {
+		if len(args) > 1 || cmd.Flags().Changed("at") {
+			return req, errors.New("--prefix and --suffix can't be combined with a file or --at")
+		}
+		req.Prefix, _ = cmd.Flags().GetString("prefix")
+		req.Suffix, _ = cmd.Flags().GetString("suffix")
+		return req, nil
+	}
+
+	var r io.Reader
+	switch {
+	case len(args) > 1 && args[1] != "-":
+		file, err := os.Open(args[1])
+		if err != nil {
+			return req, err
+		}
+		defer file.Close()
+		r = file
+	case len(args) > 1 || !term.IsTerminal(int(os.Stdin.Fd())):
+		r = os.Stdin
+	default:
+		return req, errors.New("give --prefix and --suffix, a file or code on stdin")
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return req, err
+	}
+	position, _ := cmd.Flags().GetString("at")
+	req.Prefix, req.Suffix, err = fim.Split(string(content), position)
+	return req, err
+}
+
+// newInfiller connects to modelName and picks its FIM template. stop stops
+// the model server if it was started here.
+func newInfiller(cmd *cobra.Command, out io.Writer, modelName string) (infiller *fim.Infiller, stop func(), err error) {
+	endpointName, _ := cmd.Flags().GetString("endpoint")
+	family, _ := cmd.Flags().GetString("family")
+	options := fim.DefaultOptions
+	options.MaxTokens, _ = cmd.Flags().GetInt("max-tokens")
+	options.Temperature, _ = cmd.Flags().GetFloat64("temperature")
+	options.Sampling.Stop, _ = cmd.Flags().GetStringArray("stop")
+	if err := options.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	// Find the template before starting a model server it can't be used with
+	templates, err := fim.Templates()
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl, err := fim.Find(templates, modelName, family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modelServerClient, _, stop, err := modelClient(cmd.Context(), out, endpointName, modelName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &fim.Infiller{Client: modelServerClient, Template: tmpl, Options: options}, stop, nil
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/search"
+	"github.com/changminbark/golms/pkg/session"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newHistoryCmd() *cobra.Command {
+	// Create history command that groups chat history subcommands
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Search the messages of saved conversations",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Print(cmd.UsageString())
+		},
+	}
+
+	// Create history search command
+	historySearchCmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Find messages in saved sessions containing every word of a query",
+		Long: `Find messages in saved sessions containing every word of a query, best
+matches first. Quote a phrase to match its words in order and end a word with
+* to match any word starting with it, e.g.
+
+  golms history search '"connection refused" dock*'
+
+Each result shows the session ID and turn number, which can be resumed from
+the full-screen chat or exported with golms sessions export.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: historySearchHandler,
+	}
+	historySearchCmd.Flags().String("model", "", "Only match messages of sessions with this LLM")
+	historySearchCmd.Flags().String("role", "", "Only match messages with this role: system, user, assistant or tool")
+	historySearchCmd.Flags().String("since", "", "Only match messages written on or after a date (YYYY-MM-DD, RFC 3339 or a duration ago like 7d or 12h)")
+	historySearchCmd.Flags().String("until", "", "Only match messages written on or before a date (YYYY-MM-DD, RFC 3339 or a duration ago like 7d or 12h)")
+	historySearchCmd.Flags().IntP("limit", "n", search.DefaultLimit, "Maximum number of results")
+	historySearchCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+
+	// Create history reindex command
+	historyReindexCmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the search index from every saved session",
+		Args:  cobra.NoArgs,
+		RunE:  historyReindexHandler,
+	}
+
+	historyCmd.AddCommand(historySearchCmd, historyReindexCmd)
+	return historyCmd
+}
+
+// ==================== Command Handlers ====================
+func historySearchHandler(cmd *cobra.Command, args []string) error {
+	model, _ := cmd.Flags().GetString("model")
+	role, _ := cmd.Flags().GetString("role")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+
+	if role != "" && !slices.Contains([]string{"system", "user", "assistant", "tool"}, role) {
+		return fmt.Errorf("invalid --role %q (expected one of system, user, assistant, tool)", role)
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("invalid --output %q (expected text or json)", output)
+	}
+	query := search.Query{Text: strings.Join(args, " "), Model: model, Role: role, Limit: limit}
+	var err error
+	if query.Since, err = parseTimeFlag(since, false); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if query.Until, err = parseTimeFlag(until, true); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	index, err := search.NewIndex()
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	results, err := index.Search(query)
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Search failed: %v", err)))
+		return err
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	if len(results) == 0 {
+		fmt.Println(ui.SubtleStyle.Render("No matching messages"))
+		return nil
+	}
+	fmt.Println(ui.FormatHeader("Search Results", fmt.Sprintf("%d messages matching %q", len(results), query.Text)))
+	for _, result := range results {
+		fmt.Println(ui.FormatListItem(fmt.Sprintf("%s turn %d ", result.Session, result.Turn) +
+			ui.SubtleStyle.Render(fmt.Sprintf("— %s, %s, %s", result.Model, result.Role, result.Time.Local().Format(time.DateTime)))))
+		fmt.Println("    " + result.Snippet)
+	}
+	return nil
+}
+
+func historyReindexHandler(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	index, err := search.NewIndex()
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	// Sessions are listed with the index locked, so turns saved meanwhile are not lost
+	var sessions []*session.Session
+	count, err := index.Rebuild(func() ([]*session.Session, error) {
+		sessions, err = store.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		return sessions, nil
+	})
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to rebuild search index: %v", err)))
+		return err
+	}
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Indexed %d messages from %d sessions", count, len(sessions))))
+	return nil
+}
+
+// ==================== Helper Functions ====================
+
+// parseTimeFlag parses a date, an RFC 3339 time or a duration before now such
+// as 7d, 12h or 2w. A date given as the end of a range includes the whole day.
+func parseTimeFlag(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	// Durations ago, with days and weeks on top of what time.ParseDuration accepts
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+				return time.Now().Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD), an RFC 3339 time or a duration like 7d", value)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/rag"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newIndexCmd() *cobra.Command {
+	// Create index command that embeds a directory for retrieval during chats
+	indexCmd := &cobra.Command{
+		Use:   "index <dir> --model <embedding model>",
+		Short: "Index the text files of a directory for golms connect --rag",
+		Long: `Index the text files of a directory for golms connect --rag.
+
+Files are split into overlapping chunks of lines, which are embedded with an
+embedding model through the model server's embeddings endpoint (/v1/embeddings,
+or /api/embed for Ollama). The index is stored under ~/.golms/rag/<name>/ and
+remembers the model, which also embeds the questions asked during a chat.
+Hidden files, binary files and dependency directories such as node_modules
+and vendor are skipped. Indexing a directory again replaces its index.`,
+		Args: cobra.ExactArgs(1),
+		RunE: indexHandler,
+	}
+	indexCmd.Flags().String("model", "", "Embedding model, as <model> or <model_server>/<model>")
+	indexCmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml serving the embedding model")
+	indexCmd.Flags().String("name", "", "Name of the index (default the directory's name)")
+	indexCmd.Flags().Int("chunk-lines", rag.DefaultOptions.ChunkLines, "Number of lines in a chunk")
+	indexCmd.Flags().Int("overlap", rag.DefaultOptions.Overlap, "Number of lines consecutive chunks share")
+	indexCmd.MarkFlagRequired("model")
+
+	return indexCmd
+}
+
+// ==================== Command Handlers ====================
+func indexHandler(cmd *cobra.Command, args []string) error {
+	modelName, _ := cmd.Flags().GetString("model")
+	endpointName, _ := cmd.Flags().GetString("endpoint")
+	name, _ := cmd.Flags().GetString("name")
+	var options rag.Options
+	options.ChunkLines, _ = cmd.Flags().GetInt("chunk-lines")
+	options.Overlap, _ = cmd.Flags().GetInt("overlap")
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	indexPath, err := rag.Path(name)
+	if err != nil {
+		return err
+	}
+
+	modelServerClient, modelServer, stopServer, err := modelClient(cmd.Context(), os.Stdout, endpointName, modelName)
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	// Ctrl+C stops indexing and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Embedding %s with %s", dir, modelServerClient.LLM())))
+	ix, err := rag.Build(ctx, modelServerClient, dir, options, func(done, total int) {
+		fmt.Printf("\r%s", ui.SubtleStyle.Render(fmt.Sprintf("Embedded %d/%d chunks", done, total)))
+	})
+	fmt.Println()
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to index %s: %v", dir, err)))
+		return err
+	}
+	ix.Server = modelServer
+	ix.Endpoint = endpointName
+	ix.Model = modelServerClient.LLM()
+	if err := ix.Save(indexPath); err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to save index: %v", err)))
+		return err
+	}
+
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Indexed %d chunks from %d files as %s", len(ix.Chunks), ix.Files, name)))
+	fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Chat with it using golms connect --rag %s", name)))
+	return nil
+}
+
+// ==================== Helper Functions ====================
+
+// modelClient returns a client for a model served by a remote endpoint when
+// endpointName is set and by a local model server otherwise.
+// modelServer is the local model server, and stop stops it if it was started here.
+func modelClient(ctx context.Context, out io.Writer, endpointName string, modelName string) (modelServerClient client.ModelServerClient, modelServer string, stop func(), err error) {
+	stop = func() {}
+	if endpointName != "" {
+		cfg, err := loadConfig(ctx)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		endpoint, err := cfg.Endpoint(endpointName)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		modelServerClient, err = client.NewRemoteClient(endpoint, modelName)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	} else {
+		model, err := resolveModel(modelName)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		modelServerManager, port, started, err := startModelServer(out, model.Server, model.Name)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if started {
+			stop = func() { modelServerManager.Stop() }
+		}
+		modelServer = model.Server
+		modelServerClient = client.NewClient(model.Server, model.Name, constants.Localhost, port)
+	}
+	return modelServerClient, modelServer, stop, nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newPersonaCmd() *cobra.Command {
+	// Create persona command that groups persona management subcommands
+	personaCmd := &cobra.Command{
+		Use:   "persona",
+		Short: "Manage reusable system prompts and chat defaults",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Print(cmd.UsageString())
+		},
+	}
+
+	// Create persona list command
+	personaListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all personas",
+		Args:  cobra.NoArgs,
+		RunE:  personaListHandler,
+	}
+
+	// Create persona show command
+	personaShowCmd := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show a persona and its rendered system prompt",
+		Args:  cobra.ExactArgs(1),
+		RunE:  personaShowHandler,
+	}
+
+	// Create persona edit command that opens a persona in $EDITOR
+	personaEditCmd := &cobra.Command{
+		Use:   "edit <name>",
+		Short: "Create or edit a persona in $EDITOR",
+		Args:  cobra.ExactArgs(1),
+		RunE:  personaEditHandler,
+	}
+
+	personaCmd.AddCommand(personaListCmd, personaShowCmd, personaEditCmd)
+	return personaCmd
+}
+
+// ==================== Command Handlers ====================
+func personaListHandler(cmd *cobra.Command, args []string) error {
+	personas, loadErrs, err := persona.List()
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing personas: %v", err)))
+		return err
+	}
+
+	if len(personas) == 0 && len(loadErrs) == 0 {
+		fmt.Println(ui.FormatWarning("No personas found"))
+		fmt.Println(ui.SubtleStyle.Render("Create one with: golms persona edit <name>"))
+		return nil
+	}
+
+	fmt.Println(ui.FormatHeader("Personas", "Stored in ~/.golms/personas/"))
+	for _, p := range personas {
+		item := p.Name
+		if p.Description != "" {
+			item += " " + ui.SubtleStyle.Render("— "+p.Description)
+		}
+		fmt.Println(ui.FormatListItem(item))
+	}
+	for _, loadErr := range loadErrs {
+		fmt.Println(ui.FormatWarning(loadErr.Error()))
+	}
+	return nil
+}
+
+func personaShowHandler(cmd *cobra.Command, args []string) error {
+	p, err := persona.Load(args[0])
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+
+	fmt.Println(ui.FormatHeader(p.Name, p.Description))
+	if p.Model != "" {
+		fmt.Println(ui.FormatListItem("Preferred model: " + p.Model))
+	}
+	if p.Temperature != nil {
+		fmt.Println(ui.FormatListItem(fmt.Sprintf("Temperature: %g", *p.Temperature)))
+	}
+	if p.MaxTokens != nil {
+		fmt.Println(ui.FormatListItem(fmt.Sprintf("Max Tokens: %d", *p.MaxTokens)))
+	}
+	for _, line := range p.Sampling.Describe() {
+		fmt.Println(ui.FormatListItem(line))
+	}
+
+	system, err := persona.Render(p.System, persona.CurrentVars(p.Model))
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	fmt.Println(ui.FormatInfoBox(ui.PromptStyle.Render("System Prompt") + "\n" + system))
+	return nil
+}
+
+func personaEditHandler(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	personaPath, err := persona.Path(name)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+
+	// Start new personas from a commented skeleton
+	if _, err := os.Stat(personaPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(personaPath, []byte(persona.Skeleton(name)), 0o644); err != nil {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to create persona: %v", err)))
+			return err
+		}
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Run through the shell so editors configured with arguments (e.g. "code --wait") work
+	editCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", personaPath)
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+	if err := editCmd.Run(); err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Editor exited with error: %v", err)))
+		return err
+	}
+
+	// Validate the result so mistakes are caught now rather than when connecting
+	if _, err := persona.Load(name); err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		fmt.Println(ui.SubtleStyle.Render("Run 'golms persona edit " + name + "' again to fix it"))
+		return err
+	}
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Saved persona %s", name)))
+	return nil
+}
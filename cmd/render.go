@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/changminbark/golms/pkg/chattemplate"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/discovery"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/server"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newRenderCmd() *cobra.Command {
+	// Create render command that shows the prompt a model sees
+	renderCmd := &cobra.Command{
+		Use:   "render <model> [prompt...]",
+		Short: "Show the prompt a model sees for a message, formatted with its chat template",
+		Long: `Show the prompt a model sees for a message, formatted with its chat template.
+
+The template is read from the model directory: an Ollama Modelfile, a
+chat_template.jinja file or the chat_template of tokenizer_config.json. The
+prompt is written to stdout and its token count to stderr. The count is exact
+when the model server is already running and estimated otherwise, since
+golms render never starts one. Text piped to stdin is appended to the prompt,
+e.g.
+
+  golms render qwen2.5-0.5b --system "Be brief." "What is Go?"
+  golms render llama-3.2-1b --template llama3 < question.txt`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: renderHandler,
+	}
+	renderCmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml counting the tokens (needs --template)")
+	renderCmd.Flags().String("template", "", fmt.Sprintf("Builtin chat template to use instead of the model's: %s", strings.Join(chattemplate.Names(), ", ")))
+	renderCmd.Flags().String("system", "", "System prompt (a Go text/template)")
+	renderCmd.Flags().String("persona", "", "Persona from ~/.golms/personas/ whose system prompt to use")
+
+	return renderCmd
+}
+
+// ==================== Command Handlers ====================
+func renderHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so stdout only carries the prompt
+	stderr := os.Stderr
+
+	endpointName, _ := cmd.Flags().GetString("endpoint")
+	templateName, _ := cmd.Flags().GetString("template")
+	if endpointName != "" && templateName == "" {
+		return errors.New("--endpoint needs --template, since the model's files are not available")
+	}
+	_, systemPrompt, _, err := loadChatOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	prompt := strings.Join(args[1:], " ")
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		stdin, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if prompt != "" && len(stdin) > 0 {
+			prompt += "\n"
+		}
+		prompt += strings.TrimSuffix(string(stdin), "\n")
+	}
+
+	var messages []client.Message
+	if systemPrompt != "" {
+		system, err := persona.Render(systemPrompt, persona.CurrentVars(args[0]))
+		if err != nil {
+			return err
+		}
+		messages = append(messages, client.Message{Role: "system", Content: system})
+	}
+	if prompt != "" {
+		messages = append(messages, client.Message{Role: "user", Content: prompt})
+	}
+
+	// The model's server counts the tokens if it is reachable without starting it
+	var tmpl chattemplate.Template
+	var counter client.Completer
+	if endpointName != "" {
+		if tmpl, err = chattemplate.Lookup(templateName); err != nil {
+			return err
+		}
+		cfg, err := loadConfig(cmd.Context())
+		if err != nil {
+			return err
+		}
+		endpoint, err := cfg.Endpoint(endpointName)
+		if err != nil {
+			return err
+		}
+		if counter, err = client.NewRemoteClient(endpoint, args[0]); err != nil {
+			return err
+		}
+	} else {
+		model, err := resolveModel(args[0])
+		if err != nil {
+			fmt.Fprintln(stderr, ui.FormatError(err.Error()))
+			return err
+		}
+		if tmpl, err = modelTemplate(model, templateName); err != nil {
+			fmt.Fprintln(stderr, ui.FormatError(err.Error()))
+			return err
+		}
+		if c := runningClient(model); c != nil {
+			counter = c
+		}
+	}
+
+	rendered, err := tmpl.Prompt(messages)
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(err.Error()))
+		return err
+	}
+	fmt.Print(rendered)
+	if term.IsTerminal(int(os.Stdout.Fd())) && !strings.HasSuffix(rendered, "\n") {
+		fmt.Println()
+	}
+
+	fmt.Fprintln(stderr, ui.SubtleStyle.Render(fmt.Sprintf("Template: %s", tmpl.Name)))
+	fmt.Fprintln(stderr, ui.SubtleStyle.Render(tokenCount(cmd, counter, rendered)))
+	return nil
+}
+
+// modelTemplate returns the builtin template called templateName, or the
+// chat template shipped with the model when templateName is empty
+func modelTemplate(model discovery.Model, templateName string) (chattemplate.Template, error) {
+	if templateName != "" {
+		return chattemplate.Lookup(templateName)
+	}
+	tmpl, err := chattemplate.ForModel(model.Path)
+	if errors.Is(err, chattemplate.ErrNoTemplate) {
+		return tmpl, fmt.Errorf("%s has no chat template, choose a builtin one with --template", model.Name)
+	}
+	return tmpl, err
+}
+
+// runningClient returns a client for the model if its model server is
+// already running, or nil
+func runningClient(model discovery.Model) client.ModelServerClient {
+	modelServerManager := server.NewServerManager(model.Server, model.Name)
+	if modelServerManager == nil {
+		return nil
+	}
+	if running, _ := modelServerManager.IsRunning(); !running {
+		return nil
+	}
+	port, err := modelServerManager.GetPort()
+	if err != nil {
+		return nil
+	}
+	return client.NewClient(model.Server, model.Name, constants.Localhost, port)
+}
+
+// tokenCount describes the number of tokens of prompt, counted by counter
+// or estimated when it is nil or fails
+func tokenCount(cmd *cobra.Command, counter client.Completer, prompt string) string {
+	if counter != nil {
+		n, err := client.CountTokens(cmd.Context(), counter, prompt)
+		if err == nil {
+			return fmt.Sprintf("%d tokens", n)
+		}
+		return fmt.Sprintf("About %d tokens (estimated, counting failed: %v)", client.EstimateTokens(prompt), err)
+	}
+	return fmt.Sprintf("About %d tokens (estimated, start the model server for an exact count)", client.EstimateTokens(prompt))
+}
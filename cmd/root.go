@@ -1,20 +1,34 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 
+	"github.com/changminbark/golms/pkg/chat"
+	"github.com/changminbark/golms/pkg/chattemplate"
 	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/config"
 	"github.com/changminbark/golms/pkg/constants"
 	"github.com/changminbark/golms/pkg/discovery"
+	"github.com/changminbark/golms/pkg/output"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/rag"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/search"
 	"github.com/changminbark/golms/pkg/server"
+	"github.com/changminbark/golms/pkg/session"
+	"github.com/changminbark/golms/pkg/tools"
+	"github.com/changminbark/golms/pkg/tui"
 	"github.com/changminbark/golms/pkg/ui"
 )
 
@@ -31,7 +45,15 @@ func NewCLI() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Print(cmd.UsageString())
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The config file is read once and shared through the command's context
+			cfg, err := config.Load()
+			cmd.SetContext(context.WithValue(cmd.Context(), configKey{}, loadedConfig{cfg, err}))
+			return applyTimeouts(cmd, cfg, err)
+		},
 	}
+	rootCmd.PersistentFlags().Duration("connect-timeout", 0, fmt.Sprintf("How long to wait when connecting to a model server (default %s)", client.DefaultTimeouts.Connect))
+	rootCmd.PersistentFlags().Duration("response-timeout", 0, fmt.Sprintf("How long to wait for a model server to start answering (default %s)", client.DefaultTimeouts.Response))
 
 	// Create list command that lists available LLMs and model servers
 	listCmd := &cobra.Command{
@@ -40,11 +62,25 @@ func NewCLI() *cobra.Command {
 		RunE:  listHandler,
 	}
 
+	listCmd.Flags().String("endpoint", "", "List the LLMs served by a remote endpoint from ~/.golms/config.yaml instead")
+
 	// Create servers command that lists support model servers
 	serversCmd := &cobra.Command{
 		Use:   "servers",
 		Short: "List all supported model servers",
-		Run:   serversHandler,
+		RunE:  serversHandler,
+	}
+
+	// Create ps command that lists running model servers and their LLMs
+	psCmd := &cobra.Command{
+		Use:   "ps",
+		Short: "List running model servers and the LLMs they serve",
+		RunE:  psHandler,
+	}
+
+	// Every listing command can emit machine-readable output
+	for _, c := range []*cobra.Command{listCmd, serversCmd, psCmd} {
+		c.Flags().StringP("output", "o", string(output.Text), "Output format: text, table, json or yaml")
 	}
 
 	// Create connect command that will connect to model server and LLM
@@ -53,45 +89,79 @@ func NewCLI() *cobra.Command {
 		Short: "Connect to a model server and LLM",
 		RunE:  connectHandler,
 	}
+	connectCmd.Flags().String("endpoint", "", "Remote endpoint from ~/.golms/config.yaml to chat with instead of a local model server")
+	connectCmd.Flags().Bool("tui", false, "Use the full-screen chat interface")
+	connectCmd.Flags().Bool("raw", false, "Print responses as plain text instead of rendered markdown")
+	connectCmd.Flags().String("think", string(chat.DefaultOptions.Reasoning), "How to display model reasoning: show, collapse or hide")
+	connectCmd.Flags().String("system", "", "System prompt for the chat (a Go text/template)")
+	connectCmd.Flags().String("persona", "", "Persona from ~/.golms/personas/ to use for the chat")
+	connectCmd.Flags().String("think-context", string(chat.DefaultOptions.ReasoningContext), "Whether to keep or strip reasoning from the context sent back to the model")
+	connectCmd.Flags().Bool("tools", false, "Let the model call local tools (read files, list directories, run allowed commands, fetch localhost URLs)")
+	connectCmd.Flags().StringSlice("allow-command", tools.DefaultAllowedCommands, "Commands the run_command tool may run")
+	connectCmd.Flags().String("rag", "", "Index created with golms index to retrieve context from for every message")
+	connectCmd.Flags().Int("rag-top-k", rag.DefaultTopK, "Number of chunks retrieved from the --rag index per message")
+	connectCmd.Flags().String("mode", "chat", "How to prompt the LLM: chat, or completion for base models")
+	connectCmd.Flags().String("template", chattemplate.Plain.Name, fmt.Sprintf("Chat template formatting the conversation in completion mode: %s", strings.Join(chattemplate.Names(), ", ")))
+	addSamplingFlags(connectCmd)
 
 	// Add subcommands to root command
-	rootCmd.AddCommand(listCmd, serversCmd, connectCmd)
+	rootCmd.AddCommand(listCmd, serversCmd, psCmd, connectCmd, newRunCmd(), newCompareCmd(), newBatchCmd(), newPersonaCmd(), newSessionsCmd(), newHistoryCmd(), newIndexCmd(), newEmbedCmd(), newCompleteCmd(), newFIMCmd(), newServeCmd(), newRenderCmd())
 
 	return rootCmd
 }
 
 // ==================== Command Handlers ====================
 func listHandler(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if endpointName, _ := cmd.Flags().GetString("endpoint"); endpointName != "" {
+		return listEndpoint(cmd.Context(), endpointName, format)
+	}
+
 	// Get list of all LLMs
-	llmListMap, err := discovery.ListAllLLMs()
+	models, err := discovery.ListAllModels()
 	if err != nil {
-		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing LLMs: %v", err)))
+		if format == output.Text {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing LLMs: %v", err)))
+		}
 		return err
 	}
-	if len(llmListMap) == 0 {
+	if len(models) == 0 && format == output.Text {
 		fmt.Println(ui.FormatWarning("No model server directories found"))
 		fmt.Println(ui.SubtleStyle.Render("Make sure models are placed in ~/golms/<model_server>/ directories"))
 		return errors.New("no model server directories found")
 	}
 
+	// Get list of all model servers
+	modelServerList, err := discovery.ListAllModelServers()
+	if err != nil {
+		if format == output.Text {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing model servers: %v", err)))
+		}
+		return err
+	}
+
+	// Machine-readable formats print the rows and nothing else
+	if format != output.Text {
+		return output.Write(os.Stdout, format, modelRows(models, modelServerList))
+	}
+
 	// Print available LLMs
 	fmt.Println(ui.FormatHeader("Available LLMs", "Models organized by server"))
-	for modelServer, llmList := range llmListMap {
-		fmt.Println(ui.FormatListItem(modelServer + ":"))
-		for _, llm := range llmList {
-			fmt.Println(ui.FormatNestedListItem(llm))
+	currentServer := ""
+	for _, model := range models {
+		if model.Server != currentServer {
+			currentServer = model.Server
+			fmt.Println(ui.FormatListItem(currentServer + ":"))
 		}
+		fmt.Println(ui.FormatNestedListItem(model.Name))
 	}
 
 	// Spacing
 	fmt.Println()
 
-	// Get list of all model servers
-	modelServerList, err := discovery.ListAllModelServers()
-	if err != nil {
-		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing model servers: %v", err)))
-		return err
-	}
 	if len(modelServerList) == 0 {
 		fmt.Println(ui.FormatWarning("No model servers available"))
 		fmt.Println(ui.SubtleStyle.Render("Install one of the following supported model servers:"))
@@ -110,24 +180,331 @@ func listHandler(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func serversHandler(cmd *cobra.Command, args []string) {
+// listEndpoint lists the LLMs a remote endpoint serves, which are all running
+func listEndpoint(ctx context.Context, endpointName string, format output.Format) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cfg.Endpoint(endpointName)
+	if err != nil {
+		if format == output.Text {
+			fmt.Println(ui.FormatError(err.Error()))
+		}
+		return err
+	}
+	llmList, err := client.ListModels(ctx, endpoint)
+	if err != nil {
+		if format == output.Text {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to list the models of %s: %v", endpointName, err)))
+		}
+		return err
+	}
+
+	if format != output.Text {
+		var rows []output.Row
+		for _, llm := range llmList {
+			rows = append(rows, output.Row{Server: endpointName, Model: llm, Path: endpoint.URL, Available: true, Running: true})
+		}
+		return output.Write(os.Stdout, format, rows)
+	}
+
+	fmt.Println(ui.FormatHeader("Available LLMs", endpointName+" at "+endpoint.URL))
+	if len(llmList) == 0 {
+		fmt.Println(ui.SubtleStyle.Render("No LLMs served"))
+	}
+	for _, llm := range llmList {
+		fmt.Println(ui.FormatListItem(llm))
+	}
+	return nil
+}
+
+func serversHandler(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if format != output.Text {
+		modelServerList, err := discovery.ListAllModelServers()
+		if err != nil {
+			return err
+		}
+		var rows []output.Row
+		for _, modelServer := range sortedModelServers() {
+			rows = append(rows, output.Row{
+				Server:    modelServer,
+				Available: slices.Contains(modelServerList, modelServer),
+				Running:   len(server.Processes(modelServer)) > 0,
+			})
+		}
+		return output.Write(os.Stdout, format, rows)
+	}
+
 	fmt.Println(ui.FormatHeader("Supported Model Servers", "Install any of these to use with golms"))
 	for _, modelServer := range constants.AvailableModelServers {
 		fmt.Println(ui.FormatListItem(modelServer))
 	}
+	return nil
+}
+
+func psHandler(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	models, err := discovery.ListAllModels()
+	if err != nil {
+		if format == output.Text {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing LLMs: %v", err)))
+		}
+		return err
+	}
+	modelServerList, err := discovery.ListAllModelServers()
+	if err != nil {
+		return err
+	}
+
+	// Keep only the models that are currently being served
+	var rows []output.Row
+	for _, row := range modelRows(models, modelServerList) {
+		if row.Running {
+			rows = append(rows, row)
+		}
+	}
+
+	// Report running servers whose model could not be matched to ~/golms/
+	for _, modelServer := range sortedModelServers() {
+		for _, process := range server.Processes(modelServer) {
+			matched := slices.ContainsFunc(rows, func(row output.Row) bool {
+				return row.Server == modelServer && process.ServesModel(row.Path)
+			})
+			if !matched {
+				rows = append(rows, output.Row{
+					Server:    modelServer,
+					Available: slices.Contains(modelServerList, modelServer),
+					Running:   true,
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b output.Row) int { return strings.Compare(a.Server, b.Server) })
+
+	if format != output.Text {
+		return output.Write(os.Stdout, format, rows)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println(ui.SubtleStyle.Render("No model servers running"))
+		return nil
+	}
+
+	fmt.Println(ui.FormatHeader("Running Model Servers"))
+	for _, row := range rows {
+		if row.Model == "" {
+			fmt.Println(ui.FormatListItem(row.Server + ": " + ui.SubtleStyle.Render("unknown model")))
+		} else {
+			fmt.Println(ui.FormatListItem(row.Server + ": " + row.Model))
+		}
+	}
+	return nil
 }
 
 func connectHandler(cmd *cobra.Command, args []string) error {
 	// Initialize data objects
-	reader := bufio.NewReader(os.Stdin)
 	var selectedModelServer string
 	var selectedLLM string
 
+	// Validate reasoning flags before doing any work
+	thinkFlag, _ := cmd.Flags().GetString("think")
+	reasoningMode, err := reasoning.ParseMode(thinkFlag)
+	if err != nil {
+		return err
+	}
+	thinkContextFlag, _ := cmd.Flags().GetString("think-context")
+	reasoningContext, err := reasoning.ParseContextMode(thinkContextFlag)
+	if err != nil {
+		return err
+	}
+	useTools, _ := cmd.Flags().GetBool("tools")
+	useTUI, _ := cmd.Flags().GetBool("tui")
+	if useTools && useTUI {
+		return errors.New("--tools is not supported with --tui")
+	}
+	// Completion mode formats the conversation as a raw prompt on our side
+	var completionTemplate *chattemplate.Template
+	switch mode, _ := cmd.Flags().GetString("mode"); mode {
+	case "chat":
+		if cmd.Flags().Changed("template") {
+			return errors.New("--template needs --mode completion")
+		}
+	case "completion":
+		if useTools {
+			return errors.New("--tools is not supported with --mode completion")
+		}
+		templateName, _ := cmd.Flags().GetString("template")
+		tmpl, err := chattemplate.Lookup(templateName)
+		if err != nil {
+			return err
+		}
+		completionTemplate = &tmpl
+	default:
+		return fmt.Errorf("invalid mode %q (expected chat or completion)", mode)
+	}
+	ragName, _ := cmd.Flags().GetString("rag")
+	if ragName != "" && useTUI {
+		return errors.New("--rag is not supported with --tui")
+	}
+	var ragIndex *rag.Index
+	if ragName != "" {
+		indexPath, err := rag.Path(ragName)
+		if err != nil {
+			return err
+		}
+		if ragIndex, err = rag.Load(indexPath); err != nil {
+			fmt.Println(ui.FormatError(err.Error()))
+			return err
+		}
+	}
+
+	// Load chat options from the config file and persona, overridden by flags
+	modelChatOptions, systemPrompt, p, err := loadChatOptions(cmd)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	var preferredLLM string
+	if p != nil {
+		preferredLLM = p.Model
+	}
+	if completionTemplate != nil && modelChatOptions.LogProbs > 0 {
+		return errors.New("--logprobs is not supported with --mode completion")
+	}
+
+	var modelServerClient client.ModelServerClient
+	if endpointName, _ := cmd.Flags().GetString("endpoint"); endpointName != "" {
+		// Remote model servers are managed by someone else, so they are never started or stopped
+		selectedModelServer = endpointName
+		selectedLLM, modelServerClient, err = selectRemoteLLM(cmd.Context(), endpointName, preferredLLM)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Println(ui.FormatDivider())
+		fmt.Println()
+	} else {
+		selectedModelServer, selectedLLM, err = selectLocalLLM(preferredLLM)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		fmt.Println(ui.FormatDivider())
+
+		// Start model server if needed
+		modelServerManager, port, started, err := startModelServer(os.Stdout, selectedModelServer, selectedLLM)
+		if err != nil {
+			return err
+		}
+		if started {
+			defer modelServerManager.Stop()
+		}
+
+		fmt.Println(ui.FormatDivider())
+		fmt.Println()
+
+		// Create client to communicate with model server
+		modelServerClient = client.NewClient(selectedModelServer, selectedLLM, constants.Localhost, port)
+	}
+	if completionTemplate != nil {
+		modelServerClient = client.WithCompletion(modelServerClient, completionTemplate.Prompt, completionTemplate.Stop)
+		fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Completion mode with the %s template", completionTemplate.Name)))
+	}
+
+	// Conversations are saved so they can be resumed from the TUI sidebar
+	chatSession := session.New(selectedModelServer, selectedLLM)
+	store, err := session.NewStore()
+	if err != nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Sessions will not be saved: %v", err)))
+		store = nil
+	}
+
+	// Markdown is only rendered when writing to a terminal
+	raw, _ := cmd.Flags().GetBool("raw")
+	chatOptions := chat.DefaultOptions
+	chatOptions.Raw = raw || !ui.IsTerminal()
+	chatOptions.Reasoning = reasoningMode
+	chatOptions.ReasoningContext = reasoningContext
+	chatOptions.SkipSetup = chatOptionsConfigured(cmd, p)
+	// /preview shows the prompt formatted with the completion template, or
+	// the template shipped with a local model
+	if completionTemplate != nil {
+		chatOptions.Template = completionTemplate
+	} else if endpointName, _ := cmd.Flags().GetString("endpoint"); endpointName == "" {
+		if model, err := resolveModel(selectedModelServer + "/" + selectedLLM); err == nil {
+			if tmpl, err := chattemplate.ForModel(model.Path); err == nil {
+				chatOptions.Template = &tmpl
+			}
+		}
+	}
+	if useTools {
+		allowedCommands, _ := cmd.Flags().GetStringSlice("allow-command")
+		chatOptions.Tools = tools.Builtin(allowedCommands)
+	}
+
+	// Retrieve context with the embedding model the index was built with
+	if ragIndex != nil {
+		embedder, _, stopEmbedder, err := modelClient(cmd.Context(), os.Stdout, ragIndex.Endpoint, ragModelName(ragIndex))
+		if err != nil {
+			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to connect to embedding model %s: %v", ragIndex.Model, err)))
+			return err
+		}
+		defer stopEmbedder()
+		topK, _ := cmd.Flags().GetInt("rag-top-k")
+		chatOptions.Retriever = &rag.Retriever{Index: ragIndex, Embedder: embedder, TopK: topK}
+		fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Answering from %s (%d chunks of %s)", ragName, len(ragIndex.Chunks), ragIndex.Dir)))
+	}
+
+	// Saved turns are indexed for golms history search
+	if store != nil {
+		if chatOptions.Index, err = search.NewIndex(); err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Chat history will not be searchable: %v", err)))
+		}
+	}
+
+	// Render system prompt template now that the model is known
+	if systemPrompt != "" {
+		chatOptions.System, err = persona.Render(systemPrompt, persona.CurrentVars(selectedLLM))
+		if err != nil {
+			fmt.Println(ui.FormatError(err.Error()))
+			return err
+		}
+	}
+
+	// Start chat
+	if useTUI {
+		err = tui.Run(modelServerClient, chatSession, store, modelChatOptions, chatOptions)
+	} else {
+		err = chat.New(modelServerClient, chatSession, store, modelChatOptions, chatOptions).Start()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Will clean up with defer of modelServerManager.Stop()
+	return nil
+}
+
+// ==================== Helpers ====================
+
+// selectLocalLLM lets the user pick a model server and one of its LLMs under ~/golms/
+func selectLocalLLM(preferredLLM string) (selectedModelServer string, selectedLLM string, err error) {
 	// Get list of all model servers
 	modelServerList, err := discovery.ListAllModelServers()
 	if err != nil {
 		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing model servers: %v", err)))
-		return err
+		return "", "", err
 	}
 	if len(modelServerList) == 0 {
 		fmt.Println(ui.FormatWarning("No model servers available"))
@@ -135,25 +512,13 @@ func connectHandler(cmd *cobra.Command, args []string) error {
 		for _, modelServer := range constants.AvailableModelServers {
 			fmt.Println(ui.FormatListItem(modelServer))
 		}
-		return errors.New("no model servers found")
+		return "", "", errors.New("no model servers found")
 	}
 
 	// Let user choose a model server with interactive prompt
-	modelServerPrompt := promptui.Select{
-		Label: ui.PromptStyle.Render("Select a model server"),
-		Items: modelServerList,
-		Templates: &promptui.SelectTemplates{
-			Label:    "{{ . }}",
-			Active:   "▸ {{ . | cyan }}",
-			Inactive: "  {{ . }}",
-			Selected: ui.SuccessStyle.Render("✓") + " {{ . }}",
-		},
-	}
-
-	_, selectedModelServer, err = modelServerPrompt.Run()
+	selectedModelServer, err = promptSelect("Select a model server", modelServerList, 0)
 	if err != nil {
-		fmt.Println(ui.FormatError("Selection cancelled"))
-		return err
+		return "", "", err
 	}
 
 	fmt.Println()
@@ -162,27 +527,83 @@ func connectHandler(cmd *cobra.Command, args []string) error {
 	llmListMap, err := discovery.ListAllLLMs()
 	if err != nil {
 		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing LLMs: %v", err)))
-		return err
+		return "", "", err
 	}
 	if len(llmListMap) == 0 {
 		fmt.Println(ui.FormatWarning("No model server directories found"))
 		fmt.Println(ui.SubtleStyle.Render("Make sure models are placed in ~/golms/<model_server>/ directories"))
-		return errors.New("no model server directories found")
+		return "", "", errors.New("no model server directories found")
 	}
 	llmList, ok := llmListMap[selectedModelServer]
 	if !ok {
 		fmt.Println(ui.FormatError(fmt.Sprintf("No subdirectory found for model server: %s", selectedModelServer)))
-		return fmt.Errorf("no subdirectory found for model server: %s", selectedModelServer)
+		return "", "", fmt.Errorf("no subdirectory found for model server: %s", selectedModelServer)
 	}
 	if len(llmList) == 0 {
 		fmt.Println(ui.FormatError(fmt.Sprintf("No LLMs available for model server: %s", selectedModelServer)))
-		return fmt.Errorf("no llms available for model server: %s", selectedModelServer)
+		return "", "", fmt.Errorf("no llms available for model server: %s", selectedModelServer)
+	}
+
+	// Let user choose LLM with interactive prompt, starting at the persona's preferred model
+	selectedLLM, err = promptSelect("Select an LLM", llmList, max(slices.Index(llmList, preferredLLM), 0))
+	if err != nil {
+		return "", "", err
+	}
+
+	return selectedModelServer, selectedLLM, nil
+}
+
+// selectRemoteLLM lets the user pick one of the LLMs served by a remote endpoint
+// and returns a client for it
+func selectRemoteLLM(ctx context.Context, endpointName string, preferredLLM string) (string, client.ModelServerClient, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return "", nil, err
+	}
+	endpoint, err := cfg.Endpoint(endpointName)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return "", nil, err
 	}
 
-	// Let user choose LLM with interactive prompt
-	llmPrompt := promptui.Select{
-		Label: ui.PromptStyle.Render("Select an LLM"),
-		Items: llmList,
+	llmList, err := client.ListModels(ctx, endpoint)
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to list the models of %s: %v", endpointName, err)))
+		return "", nil, err
+	}
+	if len(llmList) == 0 {
+		fmt.Println(ui.FormatError(fmt.Sprintf("No LLMs available at endpoint: %s", endpointName)))
+		return "", nil, fmt.Errorf("no llms available at endpoint: %s", endpointName)
+	}
+
+	selectedLLM, err := promptSelect("Select an LLM", llmList, max(slices.Index(llmList, preferredLLM), 0))
+	if err != nil {
+		return "", nil, err
+	}
+	modelServerClient, err := client.NewRemoteClient(endpoint, selectedLLM)
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return "", nil, err
+	}
+	fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Using %s at %s, golms does not start or stop remote model servers", selectedLLM, endpoint.URL)))
+	return selectedLLM, modelServerClient, nil
+}
+
+// ragModelName returns the embedding model of an index as accepted by resolveModel
+func ragModelName(ix *rag.Index) string {
+	if ix.Server == "" {
+		return ix.Model
+	}
+	return ix.Server + "/" + ix.Model
+}
+
+// promptSelect lets the user choose one of items, starting at cursor
+func promptSelect(label string, items []string, cursor int) (string, error) {
+	prompt := promptui.Select{
+		Label:     ui.PromptStyle.Render(label),
+		Items:     items,
+		CursorPos: cursor,
 		Templates: &promptui.SelectTemplates{
 			Label:    "{{ . }}",
 			Active:   "▸ {{ . | cyan }}",
@@ -191,66 +612,264 @@ func connectHandler(cmd *cobra.Command, args []string) error {
 		},
 	}
 
-	_, selectedLLM, err = llmPrompt.Run()
+	_, selected, err := prompt.Run()
 	if err != nil {
 		fmt.Println(ui.FormatError("Selection cancelled"))
-		return err
+		return "", err
 	}
+	return selected, nil
+}
 
-	fmt.Println()
-	fmt.Println(ui.FormatDivider())
+// loadChatOptions merges the chat options from the config file, the --persona
+// and --system flags and the sampling flags, returning the chat options to use,
+// the system prompt template and the persona, which is nil without --persona
+func loadChatOptions(cmd *cobra.Command) (chatOptions client.ChatOptions, systemPrompt string, p *persona.Persona, err error) {
+	chatOptions = client.DefaultChatOptions
+	systemPrompt, _ = cmd.Flags().GetString("system")
 
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return chatOptions, "", nil, err
+	}
+	chatOptions.Apply(cfg.Chat)
+
+	personaName, _ := cmd.Flags().GetString("persona")
+	if personaName != "" {
+		p, err = persona.Load(personaName)
+		if err != nil {
+			return chatOptions, "", nil, fmt.Errorf("failed to load persona: %w", err)
+		}
+		if !cmd.Flags().Changed("system") {
+			systemPrompt = p.System
+		}
+		chatOptions.Apply(p.Overrides)
+	}
+
+	if err := applySamplingFlags(cmd, &chatOptions); err != nil {
+		return chatOptions, "", nil, err
+	}
+	if err := chatOptions.Validate(); err != nil {
+		return chatOptions, "", nil, fmt.Errorf("invalid chat options: %w", err)
+	}
+	return chatOptions, systemPrompt, p, nil
+}
+
+// chatOptionsConfigured reports whether flags, the config file or the persona
+// loaded by loadChatOptions set any chat option, in which case connect doesn't
+// ask for them
+func chatOptionsConfigured(cmd *cobra.Command, p *persona.Persona) bool {
+	for _, name := range client.OptionNames() {
+		if cmd.Flags().Changed(strings.ReplaceAll(name, "_", "-")) {
+			return true
+		}
+	}
+	if p != nil && !p.Overrides.Empty() {
+		return true
+	}
+	cfg, err := loadConfig(cmd.Context())
+	return err == nil && !cfg.Chat.Empty()
+}
+
+// configKey keys the config file loaded before a command runs in its context
+type configKey struct{}
+
+// loadedConfig is the config file, or the error reading it
+type loadedConfig struct {
+	cfg *config.Config
+	err error
+}
+
+// loadConfig returns the config file loaded before the command ran, reading
+// it when the context doesn't hold it
+func loadConfig(ctx context.Context) (*config.Config, error) {
+	if loaded, ok := ctx.Value(configKey{}).(loadedConfig); ok {
+		return loaded.cfg, loaded.err
+	}
+	return config.Load()
+}
+
+// applyTimeouts sets the timeouts of model server requests from the config
+// file cfg, overridden by the --connect-timeout and --response-timeout flags.
+// A config file that cannot be read only warns, so commands that never use
+// it keep working
+func applyTimeouts(cmd *cobra.Command, cfg *config.Config, err error) error {
+	var timeouts client.Timeouts
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ui.FormatWarning(fmt.Sprintf("Using the default timeouts: %v", err)))
+	} else {
+		timeouts = cfg.Timeouts
+	}
+	if cmd.Flags().Changed("connect-timeout") {
+		timeouts.Connect, _ = cmd.Flags().GetDuration("connect-timeout")
+	}
+	if cmd.Flags().Changed("response-timeout") {
+		timeouts.Response, _ = cmd.Flags().GetDuration("response-timeout")
+	}
+	if timeouts.Connect < 0 || timeouts.Response < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	client.SetTimeouts(timeouts)
+	return nil
+}
+
+// addSamplingFlags registers a flag for every chat option that can be set by name
+func addSamplingFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Float64("temperature", client.DefaultChatOptions.Temperature, "Sampling temperature (0-2)")
+	flags.Int("max-tokens", client.DefaultChatOptions.MaxTokens, "Maximum number of tokens to generate")
+	flags.Float64("top-p", 0, "Nucleus sampling: only sample from tokens within this cumulative probability (0-1]")
+	flags.Int("top-k", 0, "Only sample from the k most likely tokens")
+	flags.Float64("min-p", 0, "Only sample tokens at least this likely relative to the most likely token (0-1)")
+	flags.Float64("repetition-penalty", 0, "Penalty applied to repeated tokens (1 is no penalty)")
+	flags.Float64("presence-penalty", 0, "Penalize tokens that have appeared at all (-2 to 2)")
+	flags.Float64("frequency-penalty", 0, "Penalize tokens by how often they have appeared (-2 to 2)")
+	flags.Int("seed", 0, "Random seed for reproducible sampling")
+	flags.StringArray("stop", nil, "Stop generating at this sequence (repeatable)")
+	flags.StringSlice("logit-bias", nil, "Bias a token ID, e.g. 50256=-100 (repeatable)")
+	flags.Int("logprobs", 0, fmt.Sprintf("Show how likely each generated token was, with this many alternatives (1-%d)", client.MaxLogProbs))
+}
+
+// applySamplingFlags sets the chat options whose flags were given
+func applySamplingFlags(cmd *cobra.Command, chatOptions *client.ChatOptions) error {
+	for _, name := range client.OptionNames() {
+		flag := cmd.Flags().Lookup(strings.ReplaceAll(name, "_", "-"))
+		if flag == nil || !flag.Changed {
+			continue
+		}
+
+		switch name {
+		case "stop":
+			chatOptions.Sampling.Stop, _ = cmd.Flags().GetStringArray("stop")
+		case "logit_bias":
+			pairs, _ := cmd.Flags().GetStringSlice("logit-bias")
+			bias, err := client.ParseLogitBias(pairs)
+			if err != nil {
+				return fmt.Errorf("invalid --logit-bias: %w", err)
+			}
+			chatOptions.Sampling.LogitBias = bias
+		default:
+			if err := client.SetOption(chatOptions, name, flag.Value.String()); err != nil {
+				return fmt.Errorf("invalid --%s: %w", flag.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveModel finds an LLM under ~/golms/ by name, or by server/name when
+// the same name exists for several model servers
+func resolveModel(name string) (discovery.Model, error) {
+	models, err := discovery.ListAllModels()
+	if err != nil {
+		return discovery.Model{}, err
+	}
+
+	modelServer, llm, qualified := strings.Cut(name, "/")
+	if !qualified {
+		llm = name
+	}
+
+	var matches []discovery.Model
+	for _, model := range models {
+		if model.Name == llm && (!qualified || model.Server == modelServer) {
+			matches = append(matches, model)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return discovery.Model{}, fmt.Errorf("no LLM named %s found under ~/golms/", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return discovery.Model{}, fmt.Errorf("LLM %s exists for several model servers, use <model_server>/%s", llm, llm)
+	}
+}
+
+// startModelServer starts the model server for an LLM unless it is already running,
+// writing progress to out. started reports whether the caller should stop it when done.
+func startModelServer(out io.Writer, modelServer string, llm string) (modelServerManager server.ModelServerManager, port int, started bool, err error) {
 	// Create model server instance
-	modelServerManager := server.NewServerManager(selectedModelServer, selectedLLM)
-	if !modelServerManager.IsAvailable() {
-		fmt.Println(ui.FormatError(fmt.Sprintf("Model server not available: %s", selectedModelServer)))
-		return errors.New("model server unavailable")
+	modelServerManager = server.NewServerManager(modelServer, llm)
+	if modelServerManager == nil || !modelServerManager.IsAvailable() {
+		fmt.Fprintln(out, ui.FormatError(fmt.Sprintf("Model server not available: %s", modelServer)))
+		return nil, 0, false, errors.New("model server unavailable")
 	}
 
 	running, _ := modelServerManager.IsRunning()
 	if !running {
-		fmt.Println()
-		fmt.Println(ui.HeaderStyle.Render("Starting Model Server"))
-		fmt.Println()
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, ui.HeaderStyle.Render("Starting Model Server"))
+		fmt.Fprintln(out)
 
 		// Show spinner while starting server
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(out))
 		s.Suffix = "  Initializing server...\n"
 		s.Start()
 
+		previousOutput := server.Output
+		server.Output = out
 		err := modelServerManager.Start()
+		server.Output = previousOutput
 		s.Stop()
 
 		if err != nil {
-			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to start model server: %v", err)))
-			return err
+			fmt.Fprintln(out, ui.FormatError(fmt.Sprintf("Failed to start model server: %v", err)))
+			return nil, 0, false, err
 		}
-		fmt.Println(ui.FormatSuccess("Model server is ready"))
-		fmt.Println()
-		defer modelServerManager.Stop()
+		fmt.Fprintln(out, ui.FormatSuccess("Model server is ready"))
+		fmt.Fprintln(out)
+		started = true
 	} else {
-		fmt.Println(ui.SubtleStyle.Render("Model server already running"))
-		fmt.Println()
+		fmt.Fprintln(out, ui.SubtleStyle.Render("Model server already running"))
+		fmt.Fprintln(out)
 	}
 
-	port, err := modelServerManager.GetPort()
+	port, err = modelServerManager.GetPort()
 	if err != nil {
-		fmt.Println(ui.FormatError("Failed to get server port"))
-		return err
+		fmt.Fprintln(out, ui.FormatError("Failed to get server port"))
+		if started {
+			modelServerManager.Stop()
+		}
+		return nil, 0, false, err
 	}
+	return modelServerManager, port, started, nil
+}
 
-	fmt.Println(ui.FormatDivider())
-	fmt.Println()
-
-	// Create client to communicate with model server
-	modelServerClient := client.NewClient(selectedModelServer, selectedLLM, constants.Localhost, port, reader)
-
-	// Start chat
-	err = modelServerClient.StartChat()
+// outputFormat reads and validates the --output flag of a listing command
+func outputFormat(cmd *cobra.Command) (output.Format, error) {
+	value, err := cmd.Flags().GetString("output")
 	if err != nil {
-		return err
+		return "", err
 	}
+	return output.ParseFormat(value)
+}
 
-	// Will clean up with defer of modelServerManager.Stop()
-	return nil
+// sortedModelServers returns the supported model servers in a stable order
+func sortedModelServers() []string {
+	modelServers := slices.Clone(constants.AvailableModelServers)
+	slices.Sort(modelServers)
+	return modelServers
+}
+
+// modelRows converts discovered models into listing rows with server status
+func modelRows(models []discovery.Model, modelServerList []string) []output.Row {
+	processes := make(map[string][]server.Process)
+
+	rows := make([]output.Row, 0, len(models))
+	for _, model := range models {
+		running, ok := processes[model.Server]
+		if !ok {
+			running = server.Processes(model.Server)
+			processes[model.Server] = running
+		}
+		rows = append(rows, output.Row{
+			Server:    model.Server,
+			Model:     model.Name,
+			Path:      model.Path,
+			Size:      model.Size,
+			Available: slices.Contains(modelServerList, model.Server),
+			Running:   slices.ContainsFunc(running, func(p server.Process) bool { return p.ServesModel(model.Path) }),
+		})
+	}
+	return rows
 }
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/chat"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/logprobs"
+	"github.com/changminbark/golms/pkg/persona"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/schema"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newRunCmd() *cobra.Command {
+	// Create run command that sends a single prompt and prints the response
+	runCmd := &cobra.Command{
+		Use:   "run <model> [prompt...]",
+		Short: "Send a single prompt to an LLM and print the response",
+		Long: `Send a single prompt to an LLM and print the response.
+
+Content piped to stdin is attached to the prompt as a fenced block, and @path
+references in the prompt attach files, e.g.
+
+  cat err.log | golms run qwen3-8b "explain this error"
+  golms run qwen3-8b "review @main.go"
+
+With --json-schema the model is asked for JSON matching the schema, which is
+validated and retried with the validation error until it matches. Only the
+validated JSON is printed:
+
+  golms run qwen3-8b --json-schema person.json "extract the person from @bio.txt"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runHandler,
+	}
+	runCmd.Flags().String("system", "", "System prompt (a Go text/template)")
+	runCmd.Flags().String("persona", "", "Persona from ~/.golms/personas/ to use")
+	runCmd.Flags().Bool("raw", false, "Print the response as plain text instead of rendered markdown")
+	runCmd.Flags().String("json-schema", "", "JSON schema file the response must match")
+	runCmd.Flags().Int("retries", 2, "Times to retry when the response does not match --json-schema")
+	runCmd.Flags().String("logprobs-format", string(logprobs.Color), "How to show --logprobs: color, table or json")
+	addSamplingFlags(runCmd)
+
+	return runCmd
+}
+
+// ==================== Command Handlers ====================
+func runHandler(cmd *cobra.Command, args []string) error {
+	// Status goes to stderr so stdout only carries the response
+	stderr := os.Stderr
+
+	model, err := resolveModel(args[0])
+	if err != nil {
+		return err
+	}
+	chatOptions, systemPrompt, _, err := loadChatOptions(cmd)
+	if err != nil {
+		return err
+	}
+	logProbsFlag, _ := cmd.Flags().GetString("logprobs-format")
+	logProbsFormat, err := logprobs.ParseFormat(logProbsFlag)
+	if err != nil {
+		return err
+	}
+	var responseSchema *schema.Schema
+	if schemaPath, _ := cmd.Flags().GetString("json-schema"); schemaPath != "" {
+		responseSchema, err = schema.Load(schemaPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Build the user message from the prompt, @path references and piped stdin
+	prompt := strings.Join(args[1:], " ")
+	attachments, err := attach.ExpandReferences(prompt)
+	if err != nil {
+		return err
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		stdin, err := attach.FromReader("stdin", os.Stdin)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(stdin.Content) != "" {
+			if attachments, err = attach.Append(attachments, []attach.Attachment{*stdin}); err != nil {
+				return err
+			}
+		}
+	}
+	content := attach.Compose(prompt, attachments)
+	if strings.TrimSpace(content) == "" {
+		return errors.New("no prompt given and nothing piped to stdin")
+	}
+
+	messages := []client.Message{}
+	if systemPrompt != "" {
+		system, err := persona.Render(systemPrompt, persona.CurrentVars(model.Name))
+		if err != nil {
+			return err
+		}
+		messages = append(messages, client.Message{Role: "system", Content: system})
+	}
+	if responseSchema != nil {
+		// Not every server enforces response_format, so also ask in the prompt
+		content += "\n\nRespond only with JSON that matches this JSON schema:\n" + string(responseSchema.Raw)
+	}
+	messages = append(messages, client.Message{Role: "user", Content: content})
+
+	// Start model server if needed, stopping it again once we are done
+	modelServerManager, port, started, err := startModelServer(stderr, model.Server, model.Name)
+	if err != nil {
+		return err
+	}
+	if started {
+		defer modelServerManager.Stop()
+	}
+
+	// Ctrl+C cancels the generation and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	modelServerClient := client.NewClient(model.Server, model.Name, constants.Localhost, port)
+	if responseSchema != nil {
+		retries, _ := cmd.Flags().GetInt("retries")
+		return runJSONSchema(ctx, modelServerClient, chatOptions, messages, responseSchema, retries)
+	}
+	if chatOptions.LogProbs > 0 {
+		return runLogProbs(ctx, modelServerClient, chatOptions, messages, logProbsFormat)
+	}
+	raw, _ := cmd.Flags().GetBool("raw")
+	if raw || !ui.IsTerminal() {
+		return runRaw(ctx, modelServerClient, chatOptions, messages)
+	}
+
+	// Render the finished response as markdown, showing a spinner meanwhile
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(stderr))
+	s.Suffix = "  Generating..."
+	s.Start()
+	chatReq := client.NewChatRequest(chatOptions)
+	chatReq.Messages = messages
+	resp, err := modelServerClient.Chat(ctx, chatReq, nil)
+	s.Stop()
+	if err != nil {
+		fmt.Fprintln(stderr, ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
+		return err
+	}
+	if len(resp.Choices) > 0 {
+		fmt.Println(chat.FormatResponse(model.Name, resp.Choices[0].Message, chat.DefaultOptions))
+	}
+	return nil
+}
+
+// runRaw streams only the answer to stdout, leaving out any reasoning
+func runRaw(ctx context.Context, modelServerClient client.ModelServerClient, chatOptions client.ChatOptions, messages []client.Message) error {
+	chatOptions.Stream = true
+	chatReq := client.NewChatRequest(chatOptions)
+	chatReq.Messages = messages
+
+	parser := &reasoning.Parser{}
+	answerStarted := false
+	_, err := modelServerClient.Chat(ctx, chatReq, func(delta client.Delta) {
+		_, answer := parser.Feed(delta.Content)
+		// Drop the blank lines models usually emit after their reasoning
+		if !answerStarted {
+			answer = strings.TrimLeft(answer, " \t\n")
+			answerStarted = answer != ""
+		}
+		fmt.Print(answer)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
+		return err
+	}
+	_, answer := parser.Flush()
+	fmt.Println(answer)
+	return nil
+}
+
+// runJSONSchema asks for JSON matching responseSchema, sending validation errors
+// back to the model until the response matches, and prints only the validated JSON
+func runJSONSchema(ctx context.Context, modelServerClient client.ModelServerClient, chatOptions client.ChatOptions, messages []client.Message, responseSchema *schema.Schema, retries int) error {
+	chatOptions.Stream = false
+	chatReq := client.NewChatRequest(chatOptions)
+	chatReq.Messages = messages
+	chatReq.ResponseFormat = client.JSONSchemaFormat(responseSchema.Raw)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := modelServerClient.Chat(ctx, chatReq, nil)
+		if err == nil && len(resp.Choices) == 0 {
+			err = errors.New("response has no choices")
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
+			return err
+		}
+
+		_, answer := reasoning.FromMessage(resp.Choices[0].Message)
+		document, err := schema.Extract(answer)
+		if err == nil {
+			err = responseSchema.Validate(document)
+		}
+		if err == nil {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, document, "", "  "); err != nil {
+				return err
+			}
+			fmt.Println(indented.String())
+			return nil
+		}
+
+		if attempt > retries {
+			fmt.Fprintln(os.Stderr, ui.FormatError(fmt.Sprintf("Response does not match the schema after %d attempts: %v", attempt, err)))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, ui.FormatWarning(fmt.Sprintf("Response does not match the schema, retrying: %v", err)))
+
+		// Show the model its answer and what was wrong with it
+		chatReq.Messages = append(chatReq.Messages,
+			client.Message{Role: "assistant", Content: answer},
+			client.Message{Role: "user", Content: fmt.Sprintf("That response is invalid: %v\nRespond again with only the corrected JSON.", err)},
+		)
+	}
+}
+
+// runLogProbs prints the response with the log probabilities of its tokens
+func runLogProbs(ctx context.Context, modelServerClient client.ModelServerClient, chatOptions client.ChatOptions, messages []client.Message, format logprobs.Format) error {
+	chatOptions.Stream = false
+	chatReq := client.NewChatRequest(chatOptions)
+	chatReq.Messages = messages
+
+	resp, err := modelServerClient.Chat(ctx, chatReq, nil)
+	if err == nil && len(resp.Choices) == 0 {
+		err = errors.New("response has no choices")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
+		return err
+	}
+
+	tokens := resp.Choices[0].LogProbs.TokenList()
+	if len(tokens) == 0 {
+		fmt.Fprintln(os.Stderr, ui.FormatWarning("The model server did not return log probabilities"))
+		fmt.Println(resp.Choices[0].Message.Content)
+		return nil
+	}
+	rendered, err := logprobs.Write(format, tokens)
+	if err != nil {
+		return err
+	}
+	fmt.Println(rendered)
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/fim"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newServeCmd() *cobra.Command {
+	// Create serve command that answers editor requests over HTTP
+	serveCmd := &cobra.Command{
+		Use:   "serve <model>",
+		Short: "Serve fill-in-the-middle code completions over HTTP for editors",
+		Long: `Serve fill-in-the-middle code completions over HTTP for editors.
+
+POST /fim takes a JSON object with the prefix and suffix around the cursor,
+and optionally max_tokens, temperature and stop, and returns the infill:
+
+  curl -s localhost:8089/fim -d '{"prefix": "def add(a, b):\n    ", "suffix": "\n"}'
+  {"text":"return a + b","model":"qwen2.5-coder-1.5b","family":"qwen-coder","finish_reason":"stop"}
+
+The model is started once and stopped again with Ctrl+C. Prompts are formatted
+like for golms fim.`,
+		Args: cobra.ExactArgs(1),
+		RunE: serveHandler,
+	}
+	serveCmd.Flags().String("addr", "127.0.0.1:8089", "Address to listen on")
+	addFIMFlags(serveCmd)
+
+	return serveCmd
+}
+
+// ==================== Command Handlers ====================
+func serveHandler(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("addr")
+
+	infiller, stopServer, err := newInfiller(cmd, os.Stdout, args[0])
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	// Ctrl+C shuts the HTTP server down and still stops the model server
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: fim.NewHandler(infiller), ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Serving %s infills (%s) on http://%s/fim", infiller.Client.LLM(), infiller.Template.Family, addr)))
+	fmt.Println(ui.SubtleStyle.Render("Press Ctrl+C to stop"))
+
+	select {
+	case err := <-errs:
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to serve: %v", err)))
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
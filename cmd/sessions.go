@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/changminbark/golms/pkg/search"
+	"github.com/changminbark/golms/pkg/session"
+	"github.com/changminbark/golms/pkg/transcript"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+func newSessionsCmd() *cobra.Command {
+	// Create sessions command that groups saved conversation subcommands
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List, export and import saved conversations",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Print(cmd.UsageString())
+		},
+	}
+
+	// Create sessions list command
+	sessionsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions, most recent first",
+		Args:  cobra.NoArgs,
+		RunE:  sessionsListHandler,
+	}
+
+	// Create sessions export command that writes a transcript of a session
+	sessionsExportCmd := &cobra.Command{
+		Use:   "export <id>",
+		Short: "Export the current branch of a session as Markdown, JSON or HTML",
+		Args:  cobra.ExactArgs(1),
+		RunE:  sessionsExportHandler,
+	}
+	sessionsExportCmd.Flags().StringP("format", "f", "", "Transcript format: md, json or html (default from the output file's extension, else md)")
+	sessionsExportCmd.Flags().StringP("output", "o", "", "File to write the transcript to instead of stdout")
+	sessionsExportCmd.Flags().Bool("think", false, "Include the model's reasoning")
+	sessionsExportCmd.Flags().Bool("force", false, "Overwrite the output file if it exists")
+
+	// Create sessions import command that loads an OpenAI-format conversation
+	sessionsImportCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create a session from an OpenAI-format messages JSON file",
+		Long: `Create a session from an OpenAI-format messages JSON file, either a list of
+messages or a chat completions request with "messages" and "model". Use - to
+read from stdin. The session can then be continued from the full-screen chat.`,
+		Args: cobra.ExactArgs(1),
+		RunE: sessionsImportHandler,
+	}
+	sessionsImportCmd.Flags().String("model", "", "LLM to record in the session (default from the file)")
+	sessionsImportCmd.Flags().String("server", "", "Model server to record in the session")
+
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsExportCmd, sessionsImportCmd)
+	return sessionsCmd
+}
+
+// ==================== Command Handlers ====================
+func sessionsListHandler(cmd *cobra.Command, args []string) error {
+	store, err := session.NewStore()
+	if err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	sessions, err := store.List()
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Error encountered while listing sessions: %v", err)))
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println(ui.SubtleStyle.Render("No saved sessions"))
+		return nil
+	}
+
+	fmt.Println(ui.FormatHeader("Sessions", "Stored in ~/.golms/sessions/"))
+	for _, s := range sessions {
+		title := s.Title
+		if title == "" {
+			title = "(empty)"
+		}
+		fmt.Println(ui.FormatListItem(s.ID + "  " + title + " " + ui.SubtleStyle.Render(fmt.Sprintf("— %s, %d turns", s.Model, len(s.Turns)))))
+	}
+	return nil
+}
+
+func sessionsExportHandler(cmd *cobra.Command, args []string) error {
+	formatFlag, _ := cmd.Flags().GetString("format")
+	outputPath, _ := cmd.Flags().GetString("output")
+	think, _ := cmd.Flags().GetBool("think")
+	force, _ := cmd.Flags().GetBool("force")
+
+	format := transcript.FormatFromPath(outputPath)
+	if formatFlag != "" {
+		var err error
+		if format, err = transcript.ParseFormat(formatFlag); err != nil {
+			return err
+		}
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	s, err := store.Load(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ui.FormatError(err.Error()))
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := transcript.Create(outputPath, force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, ui.FormatError(err.Error()))
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := transcript.Write(w, s, format, transcript.Options{Reasoning: think}); err != nil {
+		return err
+	}
+	if outputPath != "" {
+		fmt.Println(ui.FormatSuccess(fmt.Sprintf("Exported %s to %s", s.ID, outputPath)))
+	}
+	return nil
+}
+
+func sessionsImportHandler(cmd *cobra.Command, args []string) error {
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	model, messages, err := transcript.ReadMessages(r)
+	if err != nil {
+		fmt.Println(ui.FormatError(fmt.Sprintf("Failed to import %s: %v", args[0], err)))
+		return err
+	}
+	if cmd.Flags().Changed("model") {
+		model, _ = cmd.Flags().GetString("model")
+	}
+	modelServer, _ := cmd.Flags().GetString("server")
+
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	s := transcript.Import(messages, modelServer, model)
+	if err := store.Save(s); err != nil {
+		fmt.Println(ui.FormatError(err.Error()))
+		return err
+	}
+	if index, err := search.NewIndex(); err == nil {
+		if err := index.Update(s); err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to update search index: %v", err)))
+		}
+	}
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Imported %d messages as session %s", len(messages), s.ID)))
+	return nil
+}
@@ -0,0 +1,234 @@
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxFileSize is the largest single file that can be attached
+	MaxFileSize = 256 * 1024
+	// MaxTotalSize is the largest combined size of the attachments of one message
+	MaxTotalSize = 1024 * 1024
+
+	// sniffLength is how much of a file is inspected to detect binary content
+	sniffLength = 8000
+)
+
+var (
+	ErrTooLarge = errors.New("file too large")
+	ErrBinary   = errors.New("binary file")
+
+	// referencePattern matches @path references at the start of a message or after whitespace
+	referencePattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+)
+
+// Attachment is text content to be included in a user message
+type Attachment struct {
+	Name    string
+	Content string
+}
+
+// Resolve expands ~ and glob patterns into a list of files
+func Resolve(pattern string) ([]string, error) {
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		homePath, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pattern = filepath.Join(homePath, rest)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+
+	// Directories can match a glob but cannot be attached
+	var files []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no regular files match %s", pattern)
+	}
+	return files, nil
+}
+
+// Load reads a text file, refusing files over MaxFileSize and binary files
+func Load(path string) (*Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes (limit %d)", ErrTooLarge, path, info.Size(), MaxFileSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return FromReader(path, f)
+}
+
+// FromReader reads an attachment from r, such as piped stdin
+func FromReader(name string, r io.Reader) (*Attachment, error) {
+	// Read one byte past the limit to detect oversized input
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrTooLarge, name, MaxFileSize)
+	}
+	if IsBinary(data) {
+		return nil, fmt.Errorf("%w: %s", ErrBinary, name)
+	}
+	return &Attachment{Name: name, Content: string(data)}, nil
+}
+
+// LoadAll resolves each pattern and loads every matching file,
+// enforcing MaxTotalSize across all of them
+func LoadAll(patterns []string) ([]Attachment, error) {
+	var attachments []Attachment
+	total := 0
+	for _, pattern := range patterns {
+		files, err := Resolve(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			a, err := Load(file)
+			if err != nil {
+				return nil, err
+			}
+			total += len(a.Content)
+			if total > MaxTotalSize {
+				return nil, fmt.Errorf("%w: attachments exceed %d bytes in total", ErrTooLarge, MaxTotalSize)
+			}
+			attachments = append(attachments, *a)
+		}
+	}
+	return attachments, nil
+}
+
+// Append adds attachments to those already pending for a message, such as
+// files added with several /file commands, enforcing MaxTotalSize across all of them
+func Append(pending []Attachment, added []Attachment) ([]Attachment, error) {
+	total := 0
+	for _, a := range slices.Concat(pending, added) {
+		total += len(a.Content)
+	}
+	if total > MaxTotalSize {
+		return pending, fmt.Errorf("%w: attachments would exceed %d bytes in total", ErrTooLarge, MaxTotalSize)
+	}
+	return slices.Concat(pending, added), nil
+}
+
+// IsBinary reports whether data looks like binary rather than text content
+func IsBinary(data []byte) bool {
+	sniff := data[:min(len(data), sniffLength)]
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	// A multi-byte character may be cut off at the end of the sniffed range
+	if len(sniff) < len(data) {
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(sniff); i++ {
+			sniff = sniff[:len(sniff)-1]
+		}
+	}
+	return !utf8.Valid(sniff)
+}
+
+// ExpandReferences attaches every @path in message that refers to an existing
+// file. References to paths that do not exist (e.g. @mentions) are left alone.
+func ExpandReferences(message string) ([]Attachment, error) {
+	var patterns []string
+	for _, match := range referencePattern.FindAllStringSubmatch(message, -1) {
+		reference := strings.TrimRight(match[2], ".,;:!?)")
+		if _, err := Resolve(reference); err == nil {
+			patterns = append(patterns, reference)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return LoadAll(patterns)
+}
+
+// Compose appends attachments to a message as fenced code blocks
+func Compose(message string, attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	b.WriteString(message)
+	for _, a := range attachments {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(a.Fenced())
+	}
+	return b.String()
+}
+
+// Fenced formats the attachment as a fenced code block labelled with its name
+func (a Attachment) Fenced() string {
+	content := strings.TrimRight(a.Content, "\n")
+	return fmt.Sprintf("`%s`:\n%s", a.Name, Fence(content, language(a.Name)))
+}
+
+// Fence wraps content in a code block with an optional language hint. The
+// fence is longer than any backtick run in the content, so the content cannot
+// close it early.
+func Fence(content, language string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + content + "\n" + fence
+}
+
+// language returns the markdown language hint for a file name
+func language(name string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	switch ext {
+	case "py":
+		return "python"
+	case "js", "mjs", "cjs":
+		return "javascript"
+	case "ts", "tsx":
+		return "typescript"
+	case "rs":
+		return "rust"
+	case "rb":
+		return "ruby"
+	case "sh", "bash", "zsh":
+		return "bash"
+	case "yml":
+		return "yaml"
+	case "md":
+		return "markdown"
+	case "h", "hpp", "cc", "cpp":
+		return "cpp"
+	case "txt", "log":
+		return ""
+	}
+	return ext
+}
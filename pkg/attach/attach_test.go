@@ -0,0 +1,190 @@
+package attach
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files in a temporary directory and makes it the working directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	return dir
+}
+
+func TestResolve(t *testing.T) {
+	writeFiles(t, map[string]string{
+		"main.go":      "package main\n",
+		"util.go":      "package main\n",
+		"README.md":    "# Readme\n",
+		"docs/a.md":    "a\n",
+		"dir.go/x.txt": "a directory named like a Go file\n",
+	})
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "main.go", want: []string{"main.go"}},
+		{pattern: "*.go", want: []string{"main.go", "util.go"}},
+		{pattern: "docs/*", want: []string{"docs/a.md"}},
+		{pattern: "*.rs", wantErr: true},
+		{pattern: "docs", wantErr: true},
+		{pattern: "[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := Resolve(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("Resolve(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandReferences(t *testing.T) {
+	writeFiles(t, map[string]string{
+		"notes.txt": "remember the milk\n",
+		"main.go":   "package main\n",
+	})
+	tests := []struct {
+		message string
+		want    []string
+	}{
+		{message: "summarize @notes.txt", want: []string{"notes.txt"}},
+		{message: "@main.go and @notes.txt, please", want: []string{"main.go", "notes.txt"}},
+		{message: "what is in @notes.txt?", want: []string{"notes.txt"}},
+		{message: "ask @alice about it", want: nil},
+		{message: "mail me at bob@notes.txt", want: nil},
+		{message: "no references", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			attachments, err := ExpandReferences(tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range attachments {
+				got = append(got, a.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ExpandReferences(%q) attached %v, want %v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	// A multi-byte character cut off at the end of the sniffed range is still text
+	cut := strings.Repeat("a", sniffLength-1) + "é and more"
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "text", data: "hello\nworld\n", want: false},
+		{name: "utf-8", data: "héllo wörld ✓", want: false},
+		{name: "empty", data: "", want: false},
+		{name: "null byte", data: "PK\x03\x04\x00\x00", want: true},
+		{name: "invalid utf-8", data: "\xff\xfe\xfd", want: true},
+		{name: "character cut at the sniff length", data: cut, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBinary([]byte(tt.data)); got != tt.want {
+				t.Errorf("IsBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFenced(t *testing.T) {
+	tests := []struct {
+		name       string
+		attachment Attachment
+		want       string
+	}{
+		{name: "language hint", attachment: Attachment{Name: "main.go", Content: "package main\n"}, want: "`main.go`:\n```go\npackage main\n```"},
+		{name: "no hint", attachment: Attachment{Name: "notes", Content: "hi"}, want: "`notes`:\n```\nhi\n```"},
+		{name: "backticks in content", attachment: Attachment{Name: "README", Content: "a ```` b"}, want: "`README`:\n`````\na ```` b\n`````"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.attachment.Fenced(); got != tt.want {
+				t.Errorf("Fenced() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	half := strings.Repeat("x", MaxFileSize/2)
+	writeFiles(t, map[string]string{
+		"small.txt": "small\n",
+		"large.txt": strings.Repeat("x", MaxFileSize+1),
+		"a.txt":     half, "b.txt": half, "c.txt": half, "d.txt": half,
+		"e.txt": half, "f.txt": half, "g.txt": half, "h.txt": half, "i.txt": half,
+	})
+	tests := []struct {
+		name     string
+		patterns []string
+		wantErr  error
+	}{
+		{name: "small file", patterns: []string{"small.txt"}},
+		{name: "file over the limit", patterns: []string{"large.txt"}, wantErr: ErrTooLarge},
+		{name: "files up to the total limit", patterns: []string{"[a-h].txt"}},
+		{name: "files over the total limit", patterns: []string{"[a-i].txt"}, wantErr: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadAll(tt.patterns)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LoadAll(%v) error = %v, want %v", tt.patterns, err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := FromReader("stdin", strings.NewReader(strings.Repeat("x", MaxFileSize+1))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected oversized stdin to be refused, got %v", err)
+	}
+	if _, err := FromReader("stdin", strings.NewReader("\x00\x01")); !errors.Is(err, ErrBinary) {
+		t.Errorf("expected binary stdin to be refused, got %v", err)
+	}
+}
+
+func TestAppendEnforcesTotalSize(t *testing.T) {
+	half := Attachment{Name: "half", Content: strings.Repeat("x", MaxTotalSize/2)}
+
+	// Attachments added one /file at a time count towards the same limit
+	pending, err := Append(nil, []Attachment{half})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, err = Append(pending, []Attachment{half}); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := Append(pending, []Attachment{{Name: "one more", Content: "x"}})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected the total limit to be enforced, got %v", err)
+	}
+	if len(kept) != 2 {
+		t.Errorf("expected the pending attachments to be kept, got %d", len(kept))
+	}
+}
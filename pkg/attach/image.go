@@ -0,0 +1,63 @@
+package attach
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// MaxImageSize is the largest image that can be attached
+const MaxImageSize = 20 * 1024 * 1024
+
+// imageTypes maps supported image extensions to their MIME types
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// LoadImage reads an image file and encodes it for sending to a vision model
+func LoadImage(path string) (*client.Image, error) {
+	files, err := Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 1 {
+		return nil, fmt.Errorf("%s matches %d files, attach images one at a time", path, len(files))
+	}
+	path = files[0]
+
+	mimeType, ok := imageTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image type: %s (supported: png, jpeg, gif, webp)", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxImageSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes (limit %d)", ErrTooLarge, path, info.Size(), MaxImageSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Catch files whose extension does not match their content
+	if detected := http.DetectContentType(data); detected != mimeType {
+		return nil, fmt.Errorf("%s does not contain a %s image (detected %s)", path, mimeType, detected)
+	}
+
+	return &client.Image{
+		MimeType: mimeType,
+		Data:     base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
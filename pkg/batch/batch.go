@@ -0,0 +1,249 @@
+// Package batch runs chat requests read from a JSONL file and writes their
+// responses to another JSONL file
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+const (
+	// maxLineSize bounds a single input or output line, which may contain long prompts
+	maxLineSize = 64 << 20
+
+	DefaultConcurrency = 4
+	DefaultRetries     = 2
+	DefaultBackoff     = time.Second
+)
+
+// Request is one line of a batch input file. Either Prompt or Messages must be given.
+type Request struct {
+	// ID identifies the request in the results, defaulting to its line number
+	ID       string           `json:"id,omitempty"`
+	Prompt   string           `json:"prompt,omitempty"`
+	System   string           `json:"system,omitempty"`
+	Messages []client.Message `json:"messages,omitempty"`
+	// Options override the batch's chat options for this request
+	Options client.Overrides `json:"options"`
+
+	// Line is the line of the input file the request was read from
+	Line int `json:"-"`
+}
+
+// Result is one line of a batch output file
+type Result struct {
+	ID        string               `json:"id"`
+	Line      int                  `json:"line"`
+	Content   string               `json:"content,omitempty"`
+	Response  *client.ChatResponse `json:"response,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Attempts  int                  `json:"attempts"`
+	StartedAt time.Time            `json:"started_at"`
+	LatencyMS int64                `json:"latency_ms"`
+}
+
+// Summary counts the outcomes of a batch run
+type Summary struct {
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
+
+// ReadRequests parses a JSONL input file, skipping blank lines
+func ReadRequests(r io.Reader) ([]Request, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+
+	var requests []Request
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if req.Prompt == "" && len(req.Messages) == 0 {
+			return nil, fmt.Errorf("line %d: request needs a prompt or messages", line)
+		}
+		if req.ID == "" {
+			req.ID = strconv.Itoa(line)
+		}
+		req.Line = line
+		requests = append(requests, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Completed returns the IDs of the requests that succeeded in an earlier run,
+// read from its output file. A truncated last line from an interrupted run is ignored.
+func Completed(r io.Reader) (map[string]bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+
+	completed := make(map[string]bool)
+	for scanner.Scan() {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		if result.Error == "" {
+			completed[result.ID] = true
+		}
+	}
+	return completed, scanner.Err()
+}
+
+// Conversation builds the messages sent for the request
+func (r Request) Conversation() []client.Message {
+	var messages []client.Message
+	if r.System != "" {
+		messages = append(messages, client.Message{Role: "system", Content: r.System})
+	}
+	messages = append(messages, r.Messages...)
+	if r.Prompt != "" {
+		messages = append(messages, client.Message{Role: "user", Content: r.Prompt})
+	}
+	return messages
+}
+
+// Runner sends batch requests to a model server
+type Runner struct {
+	Client client.ModelServerClient
+	// Options are the chat options of every request, before its own overrides
+	Options client.ChatOptions
+	// Concurrency is the number of requests in flight at once
+	Concurrency int
+	// Retries is how many times a failed request is retried
+	Retries int
+	// Backoff is the wait before the first retry, doubled for every following one
+	Backoff time.Duration
+	// Skip holds the IDs of requests that do not need to run again
+	Skip map[string]bool
+	// OnResult is called after each request finishes, for reporting progress
+	OnResult func(Result)
+}
+
+// Run sends every request not in Skip and writes a result line to w as each
+// one finishes, so results are in completion order rather than input order.
+// Cancelling ctx stops sending requests; those it interrupts are not written
+// so a resumed run sends them again.
+func (r *Runner) Run(ctx context.Context, requests []Request, w io.Writer) (Summary, error) {
+	var summary Summary
+	pending := make(chan Request)
+	results := make(chan Result)
+
+	var wg sync.WaitGroup
+	for range max(r.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range pending {
+				results <- r.run(ctx, req)
+			}
+		}()
+	}
+
+	go func() {
+	send:
+		for _, req := range requests {
+			if r.Skip[req.ID] {
+				summary.Skipped++
+				continue
+			}
+			select {
+			case pending <- req:
+			case <-ctx.Done():
+				break send
+			}
+		}
+		close(pending)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Write from a single goroutine so lines never interleave
+	var writeErr error
+	encoder := json.NewEncoder(w)
+	for result := range results {
+		if result.Error != "" && ctx.Err() != nil {
+			continue
+		}
+		if result.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		if writeErr == nil {
+			writeErr = encoder.Encode(result)
+		}
+		if r.OnResult != nil {
+			r.OnResult(result)
+		}
+	}
+	return summary, writeErr
+}
+
+// retryable reports whether sending a request again could succeed. Rejected
+// requests and conversations longer than the context fail the same way every time.
+func retryable(err error) bool {
+	return !errors.Is(err, client.ErrBadRequest) && !errors.Is(err, client.ErrContextOverflow)
+}
+
+// run sends a single request, retrying with exponential backoff
+func (r *Runner) run(ctx context.Context, req Request) Result {
+	result := Result{ID: req.ID, Line: req.Line, StartedAt: time.Now()}
+
+	options := r.Options
+	options.Stream = false
+	options.Apply(req.Options)
+	if err := options.Validate(); err != nil {
+		result.Error = fmt.Sprintf("invalid options: %v", err)
+		return result
+	}
+	chatReq := client.NewChatRequest(options)
+	chatReq.Messages = req.Conversation()
+
+	backoff := r.Backoff
+	for {
+		result.Attempts++
+		start := time.Now()
+		resp, err := r.Client.Chat(ctx, chatReq, nil)
+		if err == nil && len(resp.Choices) == 0 {
+			err = errors.New("response has no choices")
+		}
+		if err == nil {
+			result.LatencyMS = time.Since(start).Milliseconds()
+			result.Response = resp
+			result.Content = resp.Choices[0].Message.Content
+			result.Error = ""
+			return result
+		}
+
+		result.Error = err.Error()
+		if result.Attempts > r.Retries || ctx.Err() != nil || !retryable(err) {
+			return result
+		}
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+const input = `{"id": "capital", "prompt": "What is the capital of France?", "options": {"temperature": 0}}
+
+{"system": "Be brief.", "messages": [{"role": "user", "content": "Say hi"}]}
+`
+
+func newRunner(t *testing.T, replies ...fakeserver.Reply) (*Runner, *fakeserver.Server) {
+	t.Helper()
+	server := fakeserver.New(t, replies...)
+	return &Runner{
+		Client:      client.NewClient(constants.Mlx_lm, "fake", server.Host(), server.Port()),
+		Options:     client.DefaultChatOptions,
+		Concurrency: 2,
+	}, server
+}
+
+func decodeResults(t *testing.T, output string) map[string]Result {
+	t.Helper()
+	results := make(map[string]Result)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("invalid result line %q: %v", line, err)
+		}
+		results[result.ID] = result
+	}
+	return results
+}
+
+func TestReadRequests(t *testing.T) {
+	requests, err := ReadRequests(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if requests[1].ID != "3" || requests[1].Line != 3 {
+		t.Errorf("expected the second request to default to its line number, got id %q line %d", requests[1].ID, requests[1].Line)
+	}
+	if got := requests[1].Conversation(); len(got) != 2 || got[0].Role != "system" {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+	if requests[0].Options.Temperature == nil || *requests[0].Options.Temperature != 0 {
+		t.Errorf("expected the temperature override to be read")
+	}
+
+	if _, err := ReadRequests(strings.NewReader(`{"id": "empty"}`)); err == nil {
+		t.Error("expected an error for a request without a prompt")
+	}
+}
+
+func TestRunWritesResults(t *testing.T) {
+	requests, _ := ReadRequests(strings.NewReader(input))
+	runner, server := newRunner(t,
+		fakeserver.Reply{Message: client.Message{Content: "answer"}, Usage: client.Usage{TotalTokens: 7}},
+		fakeserver.Reply{Message: client.Message{Content: "answer"}, Usage: client.Usage{TotalTokens: 7}},
+	)
+
+	var output bytes.Buffer
+	summary, err := runner.Run(context.Background(), requests, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Succeeded != 2 || summary.Failed != 0 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	results := decodeResults(t, output.String())
+	for _, id := range []string{"capital", "3"} {
+		result, ok := results[id]
+		if !ok {
+			t.Fatalf("missing result for %s", id)
+		}
+		if result.Content != "answer" || result.Response.Usage.TotalTokens != 7 || result.Attempts != 1 {
+			t.Errorf("unexpected result for %s: %+v", id, result)
+		}
+	}
+
+	for _, req := range server.Requests() {
+		if req.Stream {
+			t.Error("batch requests must not stream")
+		}
+	}
+}
+
+func TestRunRetriesFailures(t *testing.T) {
+	requests, _ := ReadRequests(strings.NewReader(`{"prompt": "hi"}`))
+	runner, _ := newRunner(t,
+		fakeserver.Reply{Status: http.StatusServiceUnavailable, Body: "loading"},
+		fakeserver.Reply{Message: client.Message{Content: "hello"}},
+	)
+	runner.Retries = 1
+
+	var output bytes.Buffer
+	summary, err := runner.Run(context.Background(), requests, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := decodeResults(t, output.String())["1"]
+	if summary.Succeeded != 1 || result.Attempts != 2 || result.Error != "" {
+		t.Errorf("expected success on the second attempt, got %+v", result)
+	}
+
+	// Out of retries the error is recorded
+	runner, _ = newRunner(t, fakeserver.Reply{Status: http.StatusInternalServerError, Body: "boom"})
+	output.Reset()
+	summary, _ = runner.Run(context.Background(), requests, &output)
+	if result := decodeResults(t, output.String())["1"]; summary.Failed != 1 || result.Error == "" {
+		t.Errorf("expected a failed result, got %+v", result)
+	}
+
+	// Requests that cannot succeed are not retried
+	runner, _ = newRunner(t, fakeserver.Reply{Status: http.StatusBadRequest, Body: "maximum context length exceeded"})
+	output.Reset()
+	runner.Run(context.Background(), requests, &output)
+	if result := decodeResults(t, output.String())["1"]; result.Attempts != 1 {
+		t.Errorf("expected a single attempt, got %+v", result)
+	}
+}
+
+func TestResumeSkipsCompleted(t *testing.T) {
+	previous := `{"id": "capital", "line": 1, "content": "Paris", "attempts": 1}
+{"id": "3", "line": 3, "error": "connection refused", "attempts": 3}
+{"id": "trunc`
+	completed, err := Completed(strings.NewReader(previous))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !completed["capital"] || completed["3"] {
+		t.Fatalf("unexpected completed requests: %v", completed)
+	}
+
+	requests, _ := ReadRequests(strings.NewReader(input))
+	runner, server := newRunner(t, fakeserver.Reply{Message: client.Message{Content: "hi"}})
+	runner.Skip = completed
+
+	var output bytes.Buffer
+	summary, err := runner.Run(context.Background(), requests, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Skipped != 1 || summary.Succeeded != 1 || len(server.Requests()) != 1 {
+		t.Errorf("expected only the failed request to run again, got %+v", summary)
+	}
+}
@@ -0,0 +1,618 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/chattemplate"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/logprobs"
+	"github.com/changminbark/golms/pkg/rag"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/search"
+	"github.com/changminbark/golms/pkg/session"
+	"github.com/changminbark/golms/pkg/tools"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+var ErrExitRequested = errors.New("user requested exit")
+
+// Options controls how a chat is presented, shared by the line chat and the TUI
+type Options struct {
+	// Raw disables markdown rendering of responses
+	Raw bool
+	// Reasoning controls how <think> blocks and reasoning content are displayed
+	Reasoning reasoning.Mode
+	// ReasoningContext controls whether reasoning is sent back to the model
+	ReasoningContext reasoning.ContextMode
+	// System is the system prompt that starts new sessions
+	System string
+	// Tools are the local tools the model may call, nil disables tool calling
+	Tools *tools.Registry
+	// Index is updated with the turns of every saved session when non-nil
+	Index *search.Index
+	// Retriever adds relevant chunks of a local index to every user message when non-nil
+	Retriever *rag.Retriever
+	// Template formats the conversation for /preview, nil when unknown
+	Template *chattemplate.Template
+	// SkipSetup starts the chat without asking for the chat options, for
+	// options already set by flags, the config file or a persona
+	SkipSetup bool
+}
+
+// DefaultOptions collapses reasoning and strips it from the context, as
+// recommended for most reasoning models
+var DefaultOptions = Options{
+	Reasoning:        reasoning.Collapse,
+	ReasoningContext: reasoning.Strip,
+}
+
+// Chat is the line-based interactive chat loop
+type Chat struct {
+	client      client.ModelServerClient
+	chatOptions client.ChatOptions
+	options     Options
+	editor      *Editor
+	session     *session.Session
+	store       *session.Store
+
+	// Files attached with /file, sent with the next message
+	attachments []attach.Attachment
+	// Images attached with /image, sent with the next message
+	images []namedImage
+	// Tool calls the user allowed to run without asking again, by approvalKey
+	approvedTools map[string]bool
+	// Token log probabilities of the last response, when requested
+	lastLogProbs []client.TokenLogProb
+	// Chunks retrieved for the last user message, which are added to it
+	// only in requests so the session keeps the message as written
+	retrieval retrieval
+}
+
+// retrieval holds the chunks retrieved for a user message
+type retrieval struct {
+	message string
+	results []rag.Result
+}
+
+// namedImage is an attached image along with the path it was loaded from
+type namedImage struct {
+	name  string
+	image client.Image
+}
+
+// New creates a chat loop for the given client. Turns are recorded in s and,
+// when store is non-nil, saved after every response. chatOptions are offered
+// as the defaults when the user configures the chat.
+func New(c client.ModelServerClient, s *session.Session, store *session.Store, chatOptions client.ChatOptions, options Options) *Chat {
+	return &Chat{
+		client:      c,
+		chatOptions: chatOptions,
+		options:     options,
+		session:     s,
+		store:       store,
+
+		approvedTools: make(map[string]bool),
+	}
+}
+
+func (c *Chat) Start() error {
+	// Create line editor for user input
+	editor, err := NewEditor()
+	if err != nil {
+		return fmt.Errorf("failed to initialize input: %w", err)
+	}
+	defer editor.Close()
+	c.editor = editor
+
+	// Set Chat Options
+	if !c.options.SkipSetup {
+		c.setChatOptions()
+	}
+	c.showChatOptions()
+
+	// Display chat header with styled box
+	header := fmt.Sprintf("Chat Session: %s", c.client.LLM())
+	fmt.Println(ui.FormatInfoBox(header))
+	fmt.Println(ui.SubtleStyle.Render("Type '/help' for commands or '/exit' to quit the chat"))
+	fmt.Println(ui.FormatDivider())
+	fmt.Println()
+
+	// Start new sessions with the system prompt
+	StartSession(c.session, c.options)
+
+	// Create initial chat request, continuing the session if it already has turns
+	chatReq := client.NewChatRequest(c.chatOptions)
+	chatReq.Messages = c.session.Messages()
+	if c.options.Tools != nil {
+		chatReq.Tools = c.options.Tools.Definitions()
+	}
+
+	// Create infinite loop for chat
+	for {
+		// Prompt user for message and add to conversation thread
+		err := c.addUserMessage(chatReq)
+		if err != nil {
+			if errors.Is(err, ErrExitRequested) {
+				fmt.Println(ui.SubtleStyle.Render("\nExiting chat. Goodbye!"))
+				return nil
+			}
+			return err
+		}
+
+		// Send chat request to model server, running any tools the model calls.
+		// Ctrl+C cancels only this generation, not the chat.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = c.exchange(ctx, chatReq)
+		stop()
+		switch {
+		case err == nil:
+		case errors.Is(err, tools.ErrTooManyRounds):
+			fmt.Println(ui.FormatWarning(err.Error()))
+		case errors.Is(err, context.Canceled):
+			fmt.Println(ui.FormatWarning("Generation cancelled"))
+			fmt.Println(ui.SubtleStyle.Render("Press ↑ to edit and resend your message"))
+		default:
+			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
+			if hint := ErrorHint(err); hint != "" {
+				fmt.Println(ui.SubtleStyle.Render(hint))
+			}
+			fmt.Println(ui.SubtleStyle.Render("Press ↑ to edit and resend your message"))
+		}
+	}
+}
+
+// exchange sends the conversation ending in a new user message. When no answer
+// is received the session is moved back to before the message, so the
+// conversation keeps alternating between user and assistant. The message and
+// any tool calls stay in the session as another branch.
+func (c *Chat) exchange(ctx context.Context, req *client.ChatRequest) error {
+	err := c.runToolLoop(ctx, req)
+	if err != nil && !errors.Is(err, tools.ErrTooManyRounds) {
+		if turn, ok := c.session.LastTurn("user"); ok {
+			c.checkout(req, turn.Parent)
+			c.saveSession()
+		}
+	}
+	return err
+}
+
+// ErrorHint suggests how to recover from a failed request, or returns "" when
+// there is nothing specific to suggest
+func ErrorHint(err error) string {
+	var clientErr *client.Error
+	if errors.As(err, &clientErr) && (clientErr.Status == http.StatusUnauthorized || clientErr.Status == http.StatusForbidden) {
+		return "Check the endpoint's api_key and headers in ~/.golms/config.yaml"
+	}
+	switch {
+	case errors.Is(err, client.ErrServerUnavailable):
+		return "Check that the model server is still running with 'golms ps'"
+	case errors.Is(err, client.ErrModelNotLoaded):
+		return "The model server has not loaded the model yet, wait a moment and try again"
+	case errors.Is(err, client.ErrContextOverflow):
+		return "Shorten your message, switch to an earlier point with /branch or start a new session"
+	case errors.Is(err, client.ErrBadRequest):
+		return "Check the sampling parameters with /set"
+	case errors.Is(err, client.ErrMalformedResponse):
+		return "The model server sent an answer golms could not read, try again"
+	}
+	return ""
+}
+
+// runToolLoop sends the conversation and records every response and tool result
+// in the session until the model answers without calling tools
+func (c *Chat) runToolLoop(ctx context.Context, req *client.ChatRequest) error {
+	start := time.Now()
+	loop := &tools.Loop{
+		Registry: c.options.Tools,
+		Send: func(req *client.ChatRequest) (*client.ChatResponse, error) {
+			return c.sendChatReq(ctx, req)
+		},
+		Confirm: c.confirmToolCall,
+		OnMessage: func(message client.Message, resp *client.ChatResponse) {
+			if resp == nil {
+				// Tool results are not timed, the next response starts now
+				c.session.Append(message, nil, 0)
+				fmt.Println(ui.FormatToolResult(message.Name, message.Content))
+				start = time.Now()
+				return
+			}
+			c.session.Append(message, &resp.Usage, time.Since(start))
+			c.saveSession()
+
+			// Streamed responses have already been printed as they arrived
+			if !req.Stream && hasAnswer(message) {
+				fmt.Println(c.formatResponse(message))
+			}
+		},
+	}
+	_, err := loop.Run(req)
+	return err
+}
+
+// confirmToolCall shows a tool call and asks the user whether to run it
+func (c *Chat) confirmToolCall(call client.ToolCall) bool {
+	fmt.Println(ui.FormatToolCall(call.Function.Name, call.Function.Arguments))
+	key := approvalKey(call)
+	if c.approvedTools[key] {
+		return true
+	}
+
+	for {
+		input, err := c.editor.ReadLine(ui.PromptStyle.Render("Run this tool?") + " (y)es / (n)o / (a)lways: ")
+		if err != nil {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return true
+		case "n", "no", "":
+			return false
+		case "a", "always":
+			c.approvedTools[key] = true
+			return true
+		}
+	}
+}
+
+// approvalKey identifies a tool call by its tool and exact arguments, so
+// always allowing one command doesn't allow every other command
+func approvalKey(call client.ToolCall) string {
+	var arguments bytes.Buffer
+	if err := json.Compact(&arguments, []byte(call.Function.Arguments)); err != nil {
+		return call.Function.Name + " " + call.Function.Arguments
+	}
+	return call.Function.Name + " " + arguments.String()
+}
+
+// hasAnswer reports whether a response has content to show, as opposed to
+// only calling tools
+func hasAnswer(message client.Message) bool {
+	if len(message.ToolCalls) == 0 {
+		return true
+	}
+	thought, answer := reasoning.FromMessage(message)
+	return strings.TrimSpace(thought+answer) != ""
+}
+
+// formatResponse renders a response according to the chat options
+func (c *Chat) formatResponse(message client.Message) string {
+	// Color the whole generation, reasoning included, by confidence when inspecting logprobs
+	if c.chatOptions.LogProbs > 0 && len(c.lastLogProbs) > 0 {
+		return ui.FormatAIMessage(c.client.LLM(), logprobs.RenderColor(c.lastLogProbs)) + "\n" + ui.SubtleStyle.Render(logprobs.Legend())
+	}
+	return FormatResponse(c.client.LLM(), message, c.options)
+}
+
+// recordLogProbs keeps the token log probabilities of a response for display and /logprobs
+func (c *Chat) recordLogProbs(resp *client.ChatResponse) {
+	c.lastLogProbs = nil
+	if resp != nil && len(resp.Choices) > 0 {
+		c.lastLogProbs = resp.Choices[0].LogProbs.TokenList()
+	}
+}
+
+// FormatResponse renders a response's reasoning according to the display mode
+// and its answer as markdown unless raw output was requested
+func FormatResponse(llm string, message client.Message, options Options) string {
+	thought, answer := reasoning.FromMessage(message)
+
+	var b strings.Builder
+	if thought != "" {
+		switch options.Reasoning {
+		case reasoning.Show:
+			b.WriteString(ui.FormatReasoning(thought) + "\n\n")
+		case reasoning.Collapse:
+			b.WriteString(ui.FormatCollapsedReasoning(thought, "/think show to expand") + "\n")
+		}
+	}
+
+	if options.Raw {
+		b.WriteString(ui.FormatAIMessage(llm, answer))
+	} else {
+		b.WriteString(ui.FormatAIMarkdown(llm, answer, ui.TerminalWidth()))
+	}
+	return b.String()
+}
+
+func (c *Chat) setChatOptions() {
+	fmt.Println(ui.HeaderStyle.Render("Chat Options Setup"))
+	fmt.Println(ui.SubtleStyle.Render("Configure parameters for the model"))
+	fmt.Println()
+
+	defaults := c.chatOptions
+
+	// Set Temperature
+	tempInput, _ := c.editor.ReadLine(ui.PromptStyle.Render("Temperature") + fmt.Sprintf(" (0.0-2.0, default %g): ", defaults.Temperature))
+	tempInput = strings.TrimSpace(tempInput)
+
+	if tempInput == "" {
+		c.chatOptions.Temperature = defaults.Temperature
+	} else {
+		temp, err := strconv.ParseFloat(tempInput, 64)
+		if err != nil || temp < 0 || temp > 2.0 {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Invalid temperature, using default %g", defaults.Temperature)))
+			c.chatOptions.Temperature = defaults.Temperature
+		} else {
+			c.chatOptions.Temperature = temp
+		}
+	}
+
+	// Set MaxTokens
+	tokensInput, _ := c.editor.ReadLine(ui.PromptStyle.Render("Max Tokens") + fmt.Sprintf(" (default %d): ", defaults.MaxTokens))
+	tokensInput = strings.TrimSpace(tokensInput)
+
+	if tokensInput == "" {
+		c.chatOptions.MaxTokens = defaults.MaxTokens
+	} else {
+		tokens, err := strconv.Atoi(tokensInput)
+		if err != nil || tokens < 1 {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Invalid max tokens, using default %d", defaults.MaxTokens)))
+			c.chatOptions.MaxTokens = defaults.MaxTokens
+		} else {
+			c.chatOptions.MaxTokens = tokens
+		}
+	}
+
+	// Set Stream
+	streamDefault := "n"
+	if defaults.Stream {
+		streamDefault = "y"
+	}
+	streamInput, _ := c.editor.ReadLine(ui.PromptStyle.Render("Enable Streaming?") + fmt.Sprintf(" (y/n, default %s): ", streamDefault))
+	switch strings.TrimSpace(strings.ToLower(streamInput)) {
+	case "y", "yes":
+		c.chatOptions.Stream = true
+	case "n", "no":
+		c.chatOptions.Stream = false
+	default:
+		c.chatOptions.Stream = defaults.Stream
+	}
+	fmt.Println()
+}
+
+// showChatOptions displays the chat options in a box
+func (c *Chat) showChatOptions() {
+	optionsInfo := fmt.Sprintf("Temperature: %.2f\nMax Tokens: %d\nStreaming: %v",
+		c.chatOptions.Temperature, c.chatOptions.MaxTokens, c.chatOptions.Stream)
+	if sampling := c.chatOptions.Sampling.Describe(); len(sampling) > 0 {
+		optionsInfo += "\n" + strings.Join(sampling, "\n")
+	}
+	if c.options.System != "" {
+		optionsInfo += "\nSystem Prompt: " + ui.SubtleStyle.Render(firstLine(c.options.System))
+	}
+	fmt.Println(ui.FormatInfoBox(optionsInfo))
+	fmt.Println()
+	fmt.Println(ui.FormatDivider())
+}
+
+func (c *Chat) addUserMessage(req *client.ChatRequest) error {
+	var userInput string
+	for {
+		// Ask user for input with styled prompt
+		input, err := c.editor.ReadMessage(ui.UserStyle.Render("You: "))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return ErrExitRequested
+			}
+			return fmt.Errorf("failed to read user input: %w", err)
+		}
+
+		// Trim whitespace and skip empty messages
+		userInput = strings.TrimSpace(input)
+		if userInput == "" {
+			continue
+		}
+
+		// Run slash commands and prompt again
+		if isCommand(userInput) {
+			cmd, args, ok := findCommand(userInput)
+			if !ok {
+				fmt.Println(ui.FormatWarning(fmt.Sprintf("Unknown command %s, type /help for a list of commands", strings.Fields(userInput)[0])))
+				continue
+			}
+			if err := cmd.run(c, req, args); err != nil {
+				if errors.Is(err, errSend) {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+		// Attach files referenced as @path along with any added by /file
+		referenced, err := attach.ExpandReferences(userInput)
+		if err == nil {
+			referenced, err = attach.Append(c.attachments, referenced)
+		}
+		if err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to attach file: %v", err)))
+			continue
+		}
+		question := userInput
+		userInput = attach.Compose(userInput, referenced)
+		c.attachments = nil
+		if c.options.Retriever != nil {
+			c.retrieval = retrieval{userInput, c.retrieve(question)}
+		}
+		break
+	}
+
+	// Create user message
+	userMessage := &client.Message{
+		Role:      "user",
+		Content:   userInput,
+		ToolCalls: nil,
+	}
+	for _, image := range c.images {
+		userMessage.Images = append(userMessage.Images, image.image)
+	}
+	c.images = nil
+
+	// Append new user input into request messages
+	req.Messages = append(req.Messages, *userMessage)
+	c.session.Append(*userMessage, nil, 0)
+
+	return nil
+}
+
+// retrieve finds the chunks of the index relevant to question, returning
+// none when retrieval fails so the message is sent as is
+func (c *Chat) retrieve(question string) []rag.Result {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := c.options.Retriever.Retrieve(ctx, question)
+	if err != nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to retrieve context, sending without it: %v", err)))
+		return nil
+	}
+
+	citations := make([]string, len(results))
+	for i, result := range results {
+		citations[i] = fmt.Sprintf("[%d] %s", i+1, result.Citation())
+	}
+	fmt.Println(ui.SubtleStyle.Render("Retrieved " + strings.Join(citations, ", ")))
+	return results
+}
+
+// augment adds the retrieved chunks to the last user message of messages,
+// if it is the message they were retrieved for
+func (c *Chat) augment(messages []client.Message) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		if len(c.retrieval.results) > 0 && messages[i].Content == c.retrieval.message {
+			messages[i].Content = rag.Augment(messages[i].Content, c.retrieval.results)
+		}
+		return
+	}
+}
+
+func (c *Chat) sendChatReq(ctx context.Context, req *client.ChatRequest) (*client.ChatResponse, error) {
+	// Send a copy so reasoning can be stripped and retrieved chunks added
+	// without altering the history
+	outgoing := *req
+	outgoing.Messages = reasoning.PrepareContext(req.Messages, c.options.ReasoningContext)
+	c.augment(outgoing.Messages)
+
+	if !req.Stream {
+		resp, err := c.client.Chat(ctx, &outgoing, nil)
+		c.recordLogProbs(resp)
+		return resp, err
+	}
+
+	// Print streamed content as it arrives, keeping the plain text to know how much to clear
+	var printed strings.Builder
+	print := func(styled string, plain string) {
+		fmt.Print(styled)
+		printed.WriteString(plain)
+	}
+	prefix := c.client.LLM() + ": "
+	print(ui.AIStyle.Render(prefix), prefix)
+
+	parser := &reasoning.Parser{}
+	announcedThinking := false
+	show := func(thought string, answer string) {
+		if thought != "" {
+			switch c.options.Reasoning {
+			case reasoning.Show:
+				print(ui.RenderLines(ui.ReasoningStyle, thought), thought)
+			case reasoning.Collapse:
+				if !announcedThinking {
+					print(ui.ReasoningStyle.Render("Thinking...")+"\n", "Thinking...\n")
+					announcedThinking = true
+				}
+			}
+		}
+		if answer != "" {
+			print(answer, answer)
+		}
+	}
+	resp, err := c.client.Chat(ctx, &outgoing, func(delta client.Delta) {
+		thought, answer := parser.Feed(delta.Content)
+		// Take back the answer printed before a bare </think>, which was reasoning,
+		// or start the answer on a new line when it no longer fits on the screen
+		if parser.Retracted() != "" && ui.IsTerminal() {
+			if ui.CanClear(printed.String()) {
+				fmt.Print(ui.ClearRows(ui.CountRows(printed.String(), ui.TerminalWidth())))
+			} else {
+				fmt.Print("\n\n")
+			}
+			printed.Reset()
+			print(ui.AIStyle.Render(prefix), prefix)
+		}
+		show(delta.Reasoning+thought, answer)
+	})
+	// Print text held back in case it was the start of a tag
+	show(parser.Flush())
+	c.recordLogProbs(resp)
+	// A response taller than the screen stays as streamed, since rendering it
+	// would leave the rows that scrolled off above the rendered copy
+	if err != nil || c.options.Raw || !ui.CanClear(printed.String()) {
+		fmt.Println()
+		fmt.Println()
+		return resp, err
+	}
+
+	// Replace the raw streamed text with the rendered response
+	fmt.Print(ui.ClearRows(ui.CountRows(printed.String(), ui.TerminalWidth())))
+	if len(resp.Choices) > 0 && hasAnswer(resp.Choices[0].Message) {
+		fmt.Println(c.formatResponse(resp.Choices[0].Message))
+	}
+	return resp, nil
+}
+
+// checkout moves the session to the turn with the given ID and rebuilds the
+// request from the resulting branch
+func (c *Chat) checkout(req *client.ChatRequest, id int) {
+	if err := c.session.Checkout(id); err != nil {
+		fmt.Println(ui.FormatWarning(err.Error()))
+		return
+	}
+	req.Messages = c.session.Messages()
+}
+
+// saveSession persists the session along with the current chat options,
+// warning instead of failing the chat
+func (c *Chat) saveSession() {
+	options := c.chatOptions.Overrides()
+	c.session.Options = &options
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Save(c.session); err != nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to save session: %v", err)))
+		return
+	}
+	if c.options.Index != nil {
+		if err := c.options.Index.Update(c.session); err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to update search index: %v", err)))
+		}
+	}
+}
+
+// StartSession adds the system prompt to a session that has no turns yet
+func StartSession(s *session.Session, options Options) {
+	if options.System != "" && len(s.Turns) == 0 {
+		s.Append(client.Message{Role: "system", Content: options.System}, nil, 0)
+	}
+}
+
+func firstLine(s string) string {
+	line, _, more := strings.Cut(s, "\n")
+	if more {
+		line += " …"
+	}
+	return line
+}
@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+	"github.com/changminbark/golms/pkg/rag"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+func TestFailedExchangeIsRolledBack(t *testing.T) {
+	server := fakeserver.New(t,
+		fakeserver.Reply{Message: client.Message{Content: "Hello!"}},
+		fakeserver.Reply{Status: http.StatusOK, Body: `{"choices": []}`},
+		fakeserver.Reply{Message: client.Message{Content: "Paris"}},
+	)
+	s := session.New(constants.Mlx_lm, "test")
+	c := New(client.NewClient(constants.Mlx_lm, "test", server.Host(), server.Port()), s, nil, client.DefaultChatOptions, Options{Raw: true})
+	req := client.NewChatRequest(client.DefaultChatOptions)
+
+	// say sends a user message the way the chat loop does
+	say := func(content string) error {
+		message := client.Message{Role: "user", Content: content}
+		req.Messages = append(req.Messages, message)
+		s.Append(message, nil, 0)
+		return c.exchange(context.Background(), req)
+	}
+
+	if err := say("Hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := say("What is the capital of France?"); !errors.Is(err, client.ErrMalformedResponse) {
+		t.Fatalf("expected ErrMalformedResponse, got %v", err)
+	}
+	// The unanswered message is dropped so the conversation can continue
+	if got := len(s.Messages()); got != 2 || len(req.Messages) != 2 {
+		t.Fatalf("expected the failed turn to be rolled back, got %d session and %d request messages", got, len(req.Messages))
+	}
+
+	if err := say("What is the capital of France, again?"); err != nil {
+		t.Fatal(err)
+	}
+	messages := s.Messages()
+	if len(messages) != 4 || messages[2].Content != "What is the capital of France, again?" || messages[3].Content != "Paris" {
+		t.Errorf("unexpected conversation: %+v", messages)
+	}
+	if requests := server.Requests(); len(requests[2].Messages) != 3 {
+		t.Errorf("expected the failed message not to be resent, got %+v", requests[2].Messages)
+	}
+}
+
+func TestRetrievedExcerptsAreOnlySent(t *testing.T) {
+	server := fakeserver.New(t,
+		fakeserver.Reply{Message: client.Message{Content: "hunter2"}},
+		fakeserver.Reply{Message: client.Message{Content: "You're welcome"}},
+	)
+	embedder := client.NewClient(constants.Mlx_lm, "embed", server.Host(), server.Port())
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "faq.md"), []byte("The office wifi password is hunter2.\n"), 0o644)
+	index, err := rag.Build(context.Background(), embedder, dir, rag.DefaultOptions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := session.New(constants.Mlx_lm, "test")
+	c := New(client.NewClient(constants.Mlx_lm, "test", server.Host(), server.Port()), s, nil, client.DefaultChatOptions, Options{
+		Raw:       true,
+		Retriever: &rag.Retriever{Index: index, Embedder: embedder},
+	})
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	say := func(content string) {
+		message := client.Message{Role: "user", Content: content}
+		req.Messages = append(req.Messages, message)
+		s.Append(message, nil, 0)
+		c.retrieval = retrieval{content, c.retrieve(content)}
+		if err := c.exchange(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	say("What is the wifi password?")
+	say("Thanks")
+
+	requests := server.Requests()
+	if sent := requests[0].Messages[0].Content; !strings.HasPrefix(sent, "What is the wifi password?") || !strings.Contains(sent, "[1] lines 1-1 of `faq.md`") {
+		t.Errorf("expected the question followed by a cited excerpt, got:\n%s", sent)
+	}
+	// Earlier questions are sent and saved without their excerpts
+	if sent := requests[1].Messages[0].Content; sent != "What is the wifi password?" {
+		t.Errorf("expected the earlier question without excerpts, got:\n%s", sent)
+	}
+	if saved := s.Messages()[0].Content; saved != "What is the wifi password?" {
+		t.Errorf("expected the session to keep the question as written, got:\n%s", saved)
+	}
+
+	// Messages are still sent when retrieval fails
+	server.Close()
+	if results := c.retrieve("Hi"); results != nil {
+		t.Errorf("expected no results, got %+v", results)
+	}
+}
+
+func TestApprovalKeyMatchesExactCall(t *testing.T) {
+	call := func(arguments string) client.ToolCall {
+		return client.ToolCall{Function: client.FunctionCall{Name: "run_command", Arguments: arguments}}
+	}
+	ls := approvalKey(call(`{"command": "ls", "args": ["-l"]}`))
+	if ls != approvalKey(call(`{"command":"ls","args":["-l"]}`)) {
+		t.Error("expected the same call with different spacing to share its approval")
+	}
+	if ls == approvalKey(call(`{"command": "ls", "args": ["-la", "/"]}`)) {
+		t.Error("expected a call with other arguments to need its own approval")
+	}
+}
@@ -0,0 +1,444 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/logprobs"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/transcript"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+// errSend is returned by commands that prepared the request and want it sent
+var errSend = errors.New("send request")
+
+// command is a slash command available during a chat
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(c *Chat, req *client.ChatRequest, args string) error
+}
+
+// commands lists every slash command in the order shown by /help
+var commands []command
+
+// Registered in init since /help refers back to the command list
+func init() {
+	commands = []command{
+		{
+			name:        "/help",
+			description: "Show available commands",
+			run:         helpCommand,
+		},
+		{
+			name:        "/file",
+			usage:       "<path|glob>... | clear",
+			description: "Attach files to your next message (or use @path inline)",
+			run:         fileCommand,
+		},
+		{
+			name:        "/image",
+			usage:       "<path> | clear",
+			description: "Attach an image to your next message (vision models only)",
+			run:         imageCommand,
+		},
+		{
+			name:        "/retry",
+			description: "Generate a new response to your last message",
+			run:         retryCommand,
+		},
+		{
+			name:        "/edit",
+			usage:       "[message]",
+			description: "Change your last message and send it again",
+			run:         editCommand,
+		},
+		{
+			name:        "/branch",
+			usage:       "[number]",
+			description: "List the branches created by /retry and /edit, or switch to one",
+			run:         branchCommand,
+		},
+		{
+			name:        "/set",
+			usage:       "[<option> <value|default>]",
+			description: "Show or change chat options such as temperature, top_p or stop",
+			run:         setCommand,
+		},
+		{
+			name:        "/logprobs",
+			usage:       "[color|table|json]",
+			description: "Show token probabilities of the last response (enable with /set logprobs N)",
+			run:         logProbsCommand,
+		},
+		{
+			name:        "/preview",
+			usage:       "[message]",
+			description: "Show the prompt the model sees, formatted with its chat template",
+			run:         previewCommand,
+		},
+		{
+			name:        "/think",
+			usage:       "[show|collapse|hide] | context [keep|strip]",
+			description: "Change how model reasoning is displayed or sent back",
+			run:         thinkCommand,
+		},
+		{
+			name:        "/export",
+			usage:       "[path] [--think] [--force]",
+			description: "Save a transcript of the conversation as .md, .json or .html",
+			run:         exportCommand,
+		},
+		{
+			name:        "/exit",
+			description: "Quit the chat",
+			run: func(c *Chat, req *client.ChatRequest, args string) error {
+				return ErrExitRequested
+			},
+		},
+	}
+}
+
+// CommandNames returns the names of all slash commands, used for tab completion
+func CommandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// isCommand reports whether input looks like a slash command rather than
+// a message that happens to start with a path such as /usr/bin
+func isCommand(input string) bool {
+	name := strings.Fields(input)[0]
+	return strings.HasPrefix(name, "/") && !strings.Contains(name[1:], "/")
+}
+
+// findCommand splits user input into a slash command and its arguments
+func findCommand(input string) (*command, string, bool) {
+	name, args, _ := strings.Cut(input, " ")
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i], strings.TrimSpace(args), true
+		}
+	}
+	return nil, "", false
+}
+
+func helpCommand(c *Chat, req *client.ChatRequest, args string) error {
+	var b strings.Builder
+	b.WriteString(ui.HeaderStyle.Render("Commands"))
+	for _, cmd := range commands {
+		usage := cmd.name
+		if cmd.usage != "" {
+			usage += " " + cmd.usage
+		}
+		b.WriteString(fmt.Sprintf("\n%-24s %s", usage, ui.SubtleStyle.Render(cmd.description)))
+	}
+	b.WriteString("\n\n" + ui.SubtleStyle.Render(`Start a line with """ to write a multi-line message and end it with """`))
+	b.WriteString("\n" + ui.SubtleStyle.Render(`End a line with \ to continue on the next line`))
+	b.WriteString("\n" + ui.SubtleStyle.Render("Use ↑/↓ to browse history and ctrl+r to search it"))
+	fmt.Println(ui.FormatInfoBox(b.String()))
+	return nil
+}
+
+func thinkCommand(c *Chat, req *client.ChatRequest, args string) error {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Reasoning display: %s, context: %s",
+			c.options.Reasoning, c.options.ReasoningContext)))
+	case fields[0] == "context" && len(fields) == 2:
+		mode, err := reasoning.ParseContextMode(fields[1])
+		if err != nil {
+			fmt.Println(ui.FormatWarning(err.Error()))
+			return nil
+		}
+		c.options.ReasoningContext = mode
+		if mode == reasoning.Keep {
+			fmt.Println(ui.FormatSuccess("Reasoning will be kept in the context"))
+		} else {
+			fmt.Println(ui.FormatSuccess("Reasoning will be stripped from the context"))
+		}
+	case len(fields) == 1:
+		mode, err := reasoning.ParseMode(fields[0])
+		if err != nil {
+			fmt.Println(ui.FormatWarning(err.Error()))
+			return nil
+		}
+		c.options.Reasoning = mode
+		fmt.Println(ui.FormatSuccess(fmt.Sprintf("Reasoning display set to %s", mode)))
+	default:
+		fmt.Println(ui.FormatWarning("Usage: /think [show|collapse|hide] or /think context [keep|strip]"))
+	}
+	return nil
+}
+
+func fileCommand(c *Chat, req *client.ChatRequest, args string) error {
+	patterns := strings.Fields(args)
+
+	switch {
+	case len(patterns) == 0:
+		if len(c.attachments) == 0 {
+			fmt.Println(ui.SubtleStyle.Render("No files attached. Usage: /file <path|glob>..."))
+			return nil
+		}
+		fmt.Println(ui.SubtleStyle.Render("Attached to your next message:"))
+		for _, a := range c.attachments {
+			fmt.Println(ui.FormatListItem(a.Name))
+		}
+	case len(patterns) == 1 && patterns[0] == "clear":
+		c.attachments = nil
+		fmt.Println(ui.FormatSuccess("Cleared attached files"))
+	default:
+		attachments, err := attach.LoadAll(patterns)
+		if err == nil {
+			c.attachments, err = attach.Append(c.attachments, attachments)
+		}
+		if err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to attach files: %v", err)))
+			return nil
+		}
+		for _, a := range attachments {
+			fmt.Println(ui.FormatSuccess(fmt.Sprintf("Attached %s (%d bytes)", a.Name, len(a.Content))))
+		}
+	}
+	return nil
+}
+
+func imageCommand(c *Chat, req *client.ChatRequest, args string) error {
+	switch args {
+	case "":
+		if len(c.images) == 0 {
+			fmt.Println(ui.SubtleStyle.Render("No images attached. Usage: /image <path>"))
+			return nil
+		}
+		fmt.Println(ui.SubtleStyle.Render("Attached to your next message:"))
+		for _, image := range c.images {
+			fmt.Println(ui.FormatListItem(image.name))
+		}
+	case "clear":
+		c.images = nil
+		fmt.Println(ui.FormatSuccess("Cleared attached images"))
+	default:
+		if !c.client.Capabilities().Images {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("%s does not accept images, connect to a vision model served by ollama or mlx_vlm", c.client.LLM())))
+			return nil
+		}
+		image, err := attach.LoadImage(args)
+		if err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to attach image: %v", err)))
+			return nil
+		}
+		c.images = append(c.images, namedImage{name: args, image: *image})
+		fmt.Println(ui.FormatSuccess(fmt.Sprintf("Attached image %s", args)))
+	}
+	return nil
+}
+
+func setCommand(c *Chat, req *client.ChatRequest, args string) error {
+	if args == "" {
+		fmt.Println(ui.FormatInfoBox(strings.Join(c.chatOptions.Describe(), "\n")))
+		fmt.Println(ui.SubtleStyle.Render("Options: " + strings.Join(client.OptionNames(), ", ")))
+		return nil
+	}
+
+	name, value, _ := strings.Cut(args, " ")
+	if strings.TrimSpace(value) == "" {
+		fmt.Println(ui.FormatWarning("Usage: /set <option> <value>, or /set <option> default to use the server default"))
+		return nil
+	}
+	if err := client.SetOption(&c.chatOptions, name, value); err != nil {
+		fmt.Println(ui.FormatWarning(err.Error()))
+		return nil
+	}
+	req.SetOptions(c.chatOptions)
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Set %s to %s", name, strings.TrimSpace(value))))
+	return nil
+}
+
+// previewCommand shows the conversation, followed by message if given, the
+// way the chat template formats it for the model
+func previewCommand(c *Chat, req *client.ChatRequest, args string) error {
+	if c.options.Template == nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("No chat template known for %s, /preview needs a local model that ships one or --mode completion", c.client.LLM())))
+		return nil
+	}
+	messages := req.Messages
+	if args != "" {
+		messages = append(slices.Clone(messages), client.Message{Role: "user", Content: args})
+	}
+	prompt, err := c.options.Template.Prompt(messages)
+	if err != nil {
+		fmt.Println(ui.FormatWarning(err.Error()))
+		return nil
+	}
+
+	fmt.Println(ui.FormatDivider())
+	fmt.Println(prompt)
+	fmt.Println(ui.FormatDivider())
+	// Counting runs a generation, so Ctrl+C falls back to the estimate
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	count := fmt.Sprintf("About %d tokens (estimated)", client.EstimateTokens(prompt))
+	if n, err := client.CountTokens(ctx, c.client, prompt); err == nil {
+		count = fmt.Sprintf("%d tokens", n)
+	}
+	fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("%s with the %s template", count, c.options.Template.Name)))
+	return nil
+}
+
+func logProbsCommand(c *Chat, req *client.ChatRequest, args string) error {
+	if c.chatOptions.LogProbs == 0 {
+		fmt.Println(ui.FormatWarning("Log probabilities are off, turn them on with /set logprobs <alternatives>"))
+		return nil
+	}
+	if len(c.lastLogProbs) == 0 {
+		fmt.Println(ui.SubtleStyle.Render("No log probabilities for the last response"))
+		return nil
+	}
+
+	format := logprobs.Table
+	if args != "" {
+		var err error
+		if format, err = logprobs.ParseFormat(args); err != nil {
+			fmt.Println(ui.FormatWarning(err.Error()))
+			return nil
+		}
+	}
+	rendered, err := logprobs.Write(format, c.lastLogProbs)
+	if err != nil {
+		fmt.Println(ui.FormatWarning(err.Error()))
+		return nil
+	}
+	fmt.Println(rendered)
+	return nil
+}
+
+func retryCommand(c *Chat, req *client.ChatRequest, args string) error {
+	turn, ok := c.session.LastTurn("user")
+	if !ok {
+		fmt.Println(ui.FormatWarning("There is no message to respond to yet"))
+		return nil
+	}
+	// The previous response stays in the session as another branch
+	c.checkout(req, turn.ID)
+	fmt.Println(ui.SubtleStyle.Render("Generating a new response..."))
+	return errSend
+}
+
+func editCommand(c *Chat, req *client.ChatRequest, args string) error {
+	turn, ok := c.session.LastTurn("user")
+	if !ok {
+		fmt.Println(ui.FormatWarning("There is no message to edit yet"))
+		return nil
+	}
+
+	content := args
+	if content == "" {
+		if strings.Contains(turn.Message.Content, "\n") {
+			fmt.Println(ui.FormatWarning("Your last message spans several lines, give the new message with /edit <message>"))
+			return nil
+		}
+		input, err := c.editor.ReadLineWithDefault(ui.UserStyle.Render("Edit: "), turn.Message.Content)
+		if err != nil {
+			return nil
+		}
+		if content = strings.TrimSpace(input); content == "" {
+			fmt.Println(ui.SubtleStyle.Render("Edit cancelled"))
+			return nil
+		}
+	}
+
+	// Send the edited message as a sibling of the original, keeping its images
+	message := turn.Message
+	message.Content = content
+	c.checkout(req, turn.Parent)
+	req.Messages = append(req.Messages, message)
+	c.session.Append(message, nil, 0)
+	if c.options.Retriever != nil {
+		c.retrieval = retrieval{content, c.retrieve(content)}
+	}
+	return errSend
+}
+
+func branchCommand(c *Chat, req *client.ChatRequest, args string) error {
+	branches := c.session.Branches()
+
+	if args == "" {
+		if len(branches) < 2 {
+			fmt.Println(ui.SubtleStyle.Render("The conversation has a single branch, use /retry or /edit to start another"))
+			return nil
+		}
+		fmt.Println(ui.SubtleStyle.Render("Branches:"))
+		for i, b := range branches {
+			line := fmt.Sprintf("%d. %s (%d messages)", i+1, b.Title(), len(b.Turns))
+			if b.Leaf == c.session.Head {
+				line += " " + ui.SubtleStyle.Render("(current)")
+			}
+			fmt.Println(ui.FormatListItem(line))
+		}
+		return nil
+	}
+
+	n, err := strconv.Atoi(args)
+	if err != nil || n < 1 || n > len(branches) {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Invalid branch %q, choose a number from 1 to %d", args, len(branches))))
+		return nil
+	}
+	branch := branches[n-1]
+	c.checkout(req, branch.Leaf)
+	c.saveSession()
+	fmt.Println(ui.FormatSuccess(fmt.Sprintf("Switched to branch %d", n)))
+
+	// Remind the user where the branch left off
+	if last := branch.Turns[len(branch.Turns)-1]; last.Message.Role == "assistant" {
+		fmt.Println(FormatResponse(c.client.LLM(), last.Message, c.options))
+	}
+	return nil
+}
+
+func exportCommand(c *Chat, req *client.ChatRequest, args string) error {
+	fields, err := client.SplitQuoted(args)
+	if err != nil {
+		fmt.Println(ui.FormatWarning(err.Error()))
+		return nil
+	}
+	path := c.session.ID + ".md"
+	options := transcript.Options{}
+	force := false
+	for _, field := range fields {
+		switch field {
+		case "--think":
+			options.Reasoning = true
+		case "--force":
+			force = true
+		default:
+			path = field
+		}
+	}
+
+	file, err := transcript.Create(path, force)
+	if err != nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to export: %v", err)))
+		return nil
+	}
+	defer file.Close()
+	if err := transcript.Write(file, c.session, transcript.FormatFromPath(path), options); err != nil {
+		fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to export: %v", err)))
+		return nil
+	}
+	fmt.Println(ui.FormatSuccess("Exported conversation to " + path))
+	return nil
+}
@@ -0,0 +1,282 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+// minColumnWidth is the narrowest column answers are shown side by side in,
+// narrower terminals show them one below the other
+const minColumnWidth = 30
+
+// Answer is one LLM's response to the conversation
+type Answer struct {
+	LLM     string
+	Message client.Message
+	Usage   client.Usage
+	Latency time.Duration
+	Err     error
+}
+
+// Compare sends every message to several LLMs at once and keeps the answer
+// the user picks in a history shared by all of them
+type Compare struct {
+	clients     []client.ModelServerClient
+	chatOptions client.ChatOptions
+	options     Options
+	editor      *Editor
+	messages    []client.Message
+}
+
+// NewCompare creates a comparison chat between the given clients
+func NewCompare(clients []client.ModelServerClient, chatOptions client.ChatOptions, options Options) *Compare {
+	return &Compare{
+		clients:     clients,
+		chatOptions: chatOptions,
+		options:     options,
+	}
+}
+
+func (c *Compare) Start() error {
+	// Create line editor for user input
+	editor, err := NewEditor()
+	if err != nil {
+		return fmt.Errorf("failed to initialize input: %w", err)
+	}
+	defer editor.Close()
+	c.editor = editor
+
+	// Display chat header with the LLMs being compared
+	llms := make([]string, 0, len(c.clients))
+	for _, modelServerClient := range c.clients {
+		llms = append(llms, modelServerClient.LLM())
+	}
+	fmt.Println(ui.FormatInfoBox("Comparing: " + strings.Join(llms, " vs ")))
+	fmt.Println(ui.SubtleStyle.Render("Every message is sent to all models, then pick the answer to keep. Type '/exit' to quit"))
+	fmt.Println(ui.FormatDivider())
+	fmt.Println()
+
+	if c.options.System != "" {
+		c.messages = append(c.messages, client.Message{Role: "system", Content: c.options.System})
+	}
+
+	for {
+		userMessage, err := c.readUserMessage()
+		if err != nil {
+			if errors.Is(err, ErrExitRequested) {
+				fmt.Println(ui.SubtleStyle.Render("\nExiting chat. Goodbye!"))
+				return nil
+			}
+			return err
+		}
+
+		// Send the conversation to every model, showing a spinner meanwhile
+		chatOptions := c.chatOptions
+		chatOptions.Stream = false
+		req := client.NewChatRequest(chatOptions)
+		req.Messages = reasoning.PrepareContext(append(c.messages, userMessage), c.options.ReasoningContext)
+
+		// Ctrl+C cancels the generations but keeps the chat going
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+		s.Suffix = "  Generating..."
+		s.Start()
+		answers := Ask(ctx, c.clients, req)
+		s.Stop()
+		stop()
+		if ctx.Err() != nil {
+			fmt.Println(ui.FormatWarning("Generation cancelled"))
+			fmt.Println()
+			continue
+		}
+
+		fmt.Println(FormatAnswers(answers, c.options, ui.TerminalWidth()))
+
+		answer, ok := c.pickAnswer(answers)
+		if !ok {
+			fmt.Println(ui.SubtleStyle.Render("Discarded this exchange"))
+			fmt.Println()
+			continue
+		}
+		c.messages = append(c.messages, userMessage, answer.Message)
+		fmt.Println(ui.FormatSuccess(fmt.Sprintf("Kept the answer from %s", answer.LLM)))
+		fmt.Println()
+	}
+}
+
+// readUserMessage prompts until the user enters a message, attaching any @path references
+func (c *Compare) readUserMessage() (client.Message, error) {
+	for {
+		input, err := c.editor.ReadMessage(ui.UserStyle.Render("You: "))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return client.Message{}, ErrExitRequested
+			}
+			return client.Message{}, fmt.Errorf("failed to read user input: %w", err)
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if isCommand(input) {
+			if strings.Fields(input)[0] == "/exit" {
+				return client.Message{}, ErrExitRequested
+			}
+			fmt.Println(ui.FormatWarning("Only /exit is available when comparing models"))
+			continue
+		}
+
+		referenced, err := attach.ExpandReferences(input)
+		if err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("Failed to attach file: %v", err)))
+			continue
+		}
+		return client.Message{Role: "user", Content: attach.Compose(input, referenced)}, nil
+	}
+}
+
+// pickAnswer asks which answer to keep, reporting false when the user skips
+// the exchange or every model failed
+func (c *Compare) pickAnswer(answers []Answer) (Answer, bool) {
+	first := -1
+	for i, answer := range answers {
+		if answer.Err == nil {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		fmt.Println(ui.FormatError("Every model failed to answer"))
+		return Answer{}, false
+	}
+
+	for {
+		prompt := fmt.Sprintf(" (1-%d, enter for %d, s to skip): ", len(answers), first+1)
+		input, err := c.editor.ReadLine(ui.PromptStyle.Render("Keep which answer?") + prompt)
+		if err != nil {
+			return Answer{}, false
+		}
+		input = strings.TrimSpace(strings.ToLower(input))
+		switch input {
+		case "":
+			return answers[first], true
+		case "s", "skip":
+			return Answer{}, false
+		}
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 || n > len(answers) {
+			continue
+		}
+		if answers[n-1].Err != nil {
+			fmt.Println(ui.FormatWarning(fmt.Sprintf("%s failed to answer, pick another", answers[n-1].LLM)))
+			continue
+		}
+		return answers[n-1], true
+	}
+}
+
+// Ask sends req to every client concurrently, returning their answers in the
+// same order as the clients
+func Ask(ctx context.Context, clients []client.ModelServerClient, req *client.ChatRequest) []Answer {
+	answers := make([]Answer, len(clients))
+	var wg sync.WaitGroup
+	for i, modelServerClient := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			resp, err := modelServerClient.Chat(ctx, req, nil)
+			answer := Answer{LLM: modelServerClient.LLM(), Latency: time.Since(start), Err: err}
+			if err == nil && len(resp.Choices) == 0 {
+				answer.Err = errors.New("response has no choices")
+			}
+			if answer.Err == nil {
+				answer.Message = resp.Choices[0].Message
+				answer.Usage = resp.Usage
+			}
+			answers[i] = answer
+		}()
+	}
+	wg.Wait()
+	return answers
+}
+
+// FormatAnswers renders answers in numbered columns that fit width, each headed
+// with the model's latency and token count
+func FormatAnswers(answers []Answer, options Options, width int) string {
+	// Columns are separated by one space and have a border and padding on each side
+	columnWidth := (width - len(answers) + 1) / max(len(answers), 1)
+	sideBySide := columnWidth >= minColumnWidth
+	if !sideBySide {
+		columnWidth = width
+	}
+	contentWidth := max(columnWidth-4, 10)
+
+	columns := make([]string, 0, len(answers))
+	for i, answer := range answers {
+		var b strings.Builder
+		b.WriteString(ui.AIStyle.Render(fmt.Sprintf("[%d] %s", i+1, answer.LLM)) + "\n")
+		stats := fmt.Sprintf("%.1fs", answer.Latency.Seconds())
+		if answer.Usage.CompletionTokens > 0 {
+			stats += fmt.Sprintf(" · %d tokens", answer.Usage.CompletionTokens)
+		}
+		b.WriteString(ui.SubtleStyle.Render(stats) + "\n\n")
+		b.WriteString(formatAnswerBody(answer, options, contentWidth))
+
+		style := ui.PaneStyle.Padding(0, 1).Width(columnWidth - 2)
+		columns = append(columns, style.Render(b.String()))
+	}
+
+	if sideBySide {
+		parts := make([]string, 0, 2*len(columns))
+		for i, column := range columns {
+			if i > 0 {
+				parts = append(parts, " ")
+			}
+			parts = append(parts, column)
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Top, parts...)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, columns...)
+}
+
+// formatAnswerBody renders an answer's reasoning and content to fit width
+func formatAnswerBody(answer Answer, options Options, width int) string {
+	if answer.Err != nil {
+		return ui.ErrorStyle.Render(fmt.Sprintf("Failed: %v", answer.Err))
+	}
+
+	thought, content := reasoning.FromMessage(answer.Message)
+	wrap := lipgloss.NewStyle().Width(width)
+
+	var b strings.Builder
+	if thought != "" && options.Reasoning == reasoning.Show {
+		b.WriteString(wrap.Render(ui.FormatReasoning(thought)) + "\n\n")
+	}
+
+	if !options.Raw {
+		if rendered, err := ui.RenderMarkdown(content, width); err == nil {
+			b.WriteString(rendered)
+			return b.String()
+		}
+	}
+	b.WriteString(wrap.Render(content))
+	return b.String()
+}
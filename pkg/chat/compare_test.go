@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+func TestAskSendsToEveryClient(t *testing.T) {
+	first := fakeserver.New(t, fakeserver.Reply{
+		Message: client.Message{Content: "Paris"},
+		Usage:   client.Usage{CompletionTokens: 3},
+	})
+	second := fakeserver.New(t, fakeserver.Reply{Status: http.StatusInternalServerError, Body: "out of memory"})
+	third := fakeserver.New(t, fakeserver.Reply{Message: client.Message{Content: "It is Paris."}})
+
+	clients := []client.ModelServerClient{
+		client.NewClient(constants.Mlx_lm, "a", first.Host(), first.Port()),
+		client.NewClient(constants.Mlx_lm, "b", second.Host(), second.Port()),
+		client.NewClient(constants.Mlx_lm, "c", third.Host(), third.Port()),
+	}
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	req.Messages = []client.Message{{Role: "user", Content: "What is the capital of France?"}}
+
+	answers := Ask(context.Background(), clients, req)
+	if len(answers) != 3 {
+		t.Fatalf("expected 3 answers, got %d", len(answers))
+	}
+	if answers[0].LLM != "a" || answers[0].Message.Content != "Paris" || answers[0].Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected first answer: %+v", answers[0])
+	}
+	if answers[1].Err == nil {
+		t.Error("expected the failing server to produce an error")
+	}
+	if answers[2].Err != nil || answers[2].Message.Content != "It is Paris." {
+		t.Errorf("unexpected third answer: %+v", answers[2])
+	}
+
+	// Every server receives the same conversation
+	for _, server := range []*fakeserver.Server{first, second, third} {
+		requests := server.Requests()
+		if len(requests) != 1 || requests[0].Messages[0].Content != "What is the capital of France?" {
+			t.Errorf("unexpected requests: %+v", requests)
+		}
+	}
+}
+
+func TestFormatAnswersColumns(t *testing.T) {
+	answers := []Answer{
+		{LLM: "a", Message: client.Message{Role: "assistant", Content: "left"}},
+		{LLM: "b", Message: client.Message{Role: "assistant", Content: "right"}},
+	}
+	options := Options{Raw: true}
+
+	// Wide terminals put the answers next to each other
+	wide := FormatAnswers(answers, options, 100)
+	if !strings.Contains(strings.Split(wide, "\n")[1], "[2] b") {
+		t.Errorf("expected answers side by side:\n%s", wide)
+	}
+
+	// Narrow ones stack them
+	narrow := FormatAnswers(answers, options, 40)
+	if strings.Contains(strings.Split(narrow, "\n")[1], "[2] b") {
+		t.Errorf("expected answers stacked:\n%s", narrow)
+	}
+}
@@ -0,0 +1,183 @@
+package chat
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/changminbark/golms/pkg/config"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+const (
+	historyLimit       = 1000
+	multiLineDelimiter = `"""`
+	lineContinuation   = `\`
+)
+
+// Editor reads chat input with line editing, persistent history, reverse
+// search (ctrl+r), multi-line messages and tab completion of slash commands
+type Editor struct {
+	rl *readline.Instance
+}
+
+// NewEditor creates an editor whose history is saved to ~/.golms/history
+func NewEditor() (*Editor, error) {
+	historyFile := ""
+	if dir, err := config.Dir(); err == nil {
+		historyFile = path.Join(dir, "history")
+	}
+
+	var items []readline.PrefixCompleterInterface
+	for _, name := range CommandNames() {
+		items = append(items, readline.PcItem(name))
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		HistoryFile:            historyFile,
+		HistoryLimit:           historyLimit,
+		HistorySearchFold:      true,
+		DisableAutoSaveHistory: true,
+		AutoComplete:           readline.NewPrefixCompleter(items...),
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "/exit",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Editor{rl: rl}, nil
+}
+
+// ReadLine reads a single line without recording it in history
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	e.rl.SetPrompt(prompt)
+	line, err := e.rl.Readline()
+	if errors.Is(err, readline.ErrInterrupt) {
+		return "", nil
+	}
+	return line, err
+}
+
+// ReadLineWithDefault reads a single line starting from value, which the user can edit
+func (e *Editor) ReadLineWithDefault(prompt string, value string) (string, error) {
+	e.rl.SetPrompt(prompt)
+	line, err := e.rl.ReadlineWithDefault(value)
+	if errors.Is(err, readline.ErrInterrupt) {
+		return "", nil
+	}
+	return line, err
+}
+
+// ReadMessage reads a chat message, which may span several lines when it is
+// wrapped in triple quotes or its lines end with a backslash.
+// Returns io.EOF when the user presses ctrl+d.
+func (e *Editor) ReadMessage(prompt string) (string, error) {
+	for {
+		e.rl.SetPrompt(prompt)
+		line, err := e.rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			// ctrl+c discards the current line and starts over
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		message, err := readContinuation(line, e.readContinuationLine)
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if strings.TrimSpace(message) != "" {
+			e.saveHistory(message)
+		}
+		return message, nil
+	}
+}
+
+// readContinuationLine reads the next line of a multi-line message
+func (e *Editor) readContinuationLine() (string, error) {
+	e.rl.SetPrompt(ui.SubtleStyle.Render("... "))
+	return e.rl.Readline()
+}
+
+// readContinuation reads any further lines belonging to a multi-line message
+// started by first, calling next for each of them
+func readContinuation(first string, next func() (string, error)) (string, error) {
+	trimmed := strings.TrimSpace(first)
+
+	// Triple-quoted block: everything up to the closing delimiter
+	if rest, ok := strings.CutPrefix(trimmed, multiLineDelimiter); ok {
+		if body, closed := strings.CutSuffix(rest, multiLineDelimiter); closed && rest != "" {
+			return body, nil
+		}
+		lines := []string{}
+		if rest != "" {
+			lines = append(lines, rest)
+		}
+		for {
+			line, err := next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return "", err
+			}
+			if body, closed := strings.CutSuffix(strings.TrimRight(line, " \t"), multiLineDelimiter); closed {
+				if body != "" {
+					lines = append(lines, body)
+				}
+				break
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// Backslash continuation: keep reading while lines end with a backslash
+	line := first
+	lines := []string{}
+	for {
+		body, continued := strings.CutSuffix(strings.TrimRight(line, " \t"), lineContinuation)
+		if !continued {
+			lines = append(lines, line)
+			break
+		}
+		lines = append(lines, strings.TrimRight(body, " \t"))
+
+		var err error
+		if line, err = next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// saveHistory records a message so it can be recalled in later sessions
+func (e *Editor) saveHistory(message string) {
+	_ = e.rl.SaveHistory(historyEntry(message))
+}
+
+// historyEntry returns the history line of a message. History is stored one
+// entry per line, so multi-line messages are saved in their triple-quoted
+// form with newlines folded into spaces.
+func historyEntry(message string) string {
+	if strings.Contains(message, "\n") {
+		return multiLineDelimiter + strings.ReplaceAll(message, "\n", " ") + multiLineDelimiter
+	}
+	return message
+}
+
+// Close restores the terminal and flushes history
+func (e *Editor) Close() error {
+	return e.rl.Close()
+}
@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/chzyer/readline"
+)
+
+// lines returns a reader of the given continuation lines, which then reports
+// err, and a function returning how many lines were left unread
+func lines(input []string, err error) (func() (string, error), func() int) {
+	next := func() (string, error) {
+		if len(input) == 0 {
+			return "", err
+		}
+		line := input[0]
+		input = input[1:]
+		return line, nil
+	}
+	return next, func() int { return len(input) }
+}
+
+func TestReadContinuation(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		next    []string
+		end     error
+		want    string
+		wantErr error
+		unread  int
+	}{
+		{name: "single line", first: "hello", next: []string{"unread"}, want: "hello", unread: 1},
+		{name: "one line in triple quotes", first: `"""hello"""`, next: []string{"unread"}, want: "hello", unread: 1},
+		{name: "triple-quoted block", first: `"""`, next: []string{"first", "  indented", `"""`, "unread"}, want: "first\n  indented", unread: 1},
+		{name: "text next to the delimiters", first: `"""first`, next: []string{"second", `last"""  `}, want: "first\nsecond\nlast"},
+		{name: "triple quotes ended by ctrl+d", first: `"""`, next: []string{"a", "b"}, end: io.EOF, want: "a\nb"},
+		{name: "backslash continuation", first: `one \`, next: []string{`two\`, "three", "unread"}, want: "one\ntwo\nthree", unread: 1},
+		{name: "backslash ended by ctrl+d", first: `one \`, end: io.EOF, want: "one"},
+		{name: "backslash inside a line", first: `a\b`, want: `a\b`},
+		{name: "ctrl+c discards the message", first: `"""`, next: []string{"a"}, end: readline.ErrInterrupt, wantErr: readline.ErrInterrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, unread := lines(tt.next, tt.end)
+			got, err := readContinuation(tt.first, next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if unread() != tt.unread {
+				t.Errorf("%d lines left unread, want %d", unread(), tt.unread)
+			}
+		})
+	}
+}
+
+func TestHistoryEntry(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "hello", want: "hello"},
+		{message: "first\nsecond", want: `"""first second"""`},
+	}
+	for _, tt := range tests {
+		got := historyEntry(tt.message)
+		if got != tt.want {
+			t.Errorf("historyEntry(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+		// Recalled entries are read back as a single message
+		if recalled, err := readContinuation(got, nil); err != nil || recalled != strings.ReplaceAll(tt.message, "\n", " ") {
+			t.Errorf("recalling %q gave %q, %v", got, recalled, err)
+		}
+	}
+}
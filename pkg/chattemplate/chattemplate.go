@@ -0,0 +1,218 @@
+// Package chattemplate formats conversations as raw prompts for completion
+// endpoints, applying a chat template on the client instead of the model server
+package chattemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// Template formats a conversation in a model family's prompt format
+type Template struct {
+	Name string
+	// Source is a Go text/template executed with Data, as in Ollama
+	// Modelfiles, or a Jinja chat template when Jinja is set
+	Source string
+	Jinja  bool
+	// BOSToken and EOSToken are passed to Jinja templates as bos_token and eos_token
+	BOSToken string
+	EOSToken string
+	// Stop are sequences the model emits to end its turn
+	Stop []string
+}
+
+// Data is what a template's Source is executed with. It has the fields
+// Ollama passes to Modelfile templates.
+type Data struct {
+	// System joins the conversation's system messages
+	System string
+	// Messages are all messages of the conversation, system messages included
+	Messages []client.Message
+	// Tools are the tools offered to the model, always empty since tools
+	// aren't supported in completion mode
+	Tools []client.Tool
+	// Prompt and Response are a single turn, for templates written before
+	// Ollama added Messages
+	Prompt   string
+	Response string
+}
+
+// Plain is the default template, which joins the messages as plain text so
+// base models continue the conversation as a document. Each user message
+// starts a new line and each answer continues the text it followed.
+var Plain = Template{
+	Name: "plain",
+	Source: `{{- if .System }}{{ .System }}
+
+{{ end }}{{ $first := true }}{{ range .Messages }}{{ if ne .Role "system" }}{{ if and (not $first) (eq .Role "user") }}
+{{ end }}{{ .Content }}{{ $first = false }}{{ end }}{{ end }}`,
+}
+
+// Beginning of sequence tokens are left out since servers add them when
+// tokenizing the prompt
+var builtins = []Template{
+	Plain,
+	{
+		Name: "chatml",
+		Source: `{{- if .System }}<|im_start|>system
+{{ .System }}<|im_end|>
+{{ end }}{{ range .Messages }}{{ if ne .Role "system" }}<|im_start|>{{ .Role }}
+{{ .Content }}<|im_end|>
+{{ end }}{{ end }}<|im_start|>assistant
+`,
+		Stop: []string{"<|im_end|>"},
+	},
+	{
+		Name: "llama3",
+		Source: `{{- if .System }}<|start_header_id|>system<|end_header_id|>
+
+{{ .System }}<|eot_id|>{{ end }}{{ range .Messages }}{{ if ne .Role "system" }}<|start_header_id|>{{ .Role }}<|end_header_id|>
+
+{{ .Content }}<|eot_id|>{{ end }}{{ end }}<|start_header_id|>assistant<|end_header_id|>
+
+`,
+		Stop: []string{"<|eot_id|>"},
+	},
+	{
+		// Mistral has no system role, so the system prompt starts the first user message
+		Name: "mistral",
+		Source: `{{- $system := .System }}{{ range .Messages }}{{ if eq .Role "user" }}[INST] {{ if $system }}{{ $system }}
+
+{{ $system = "" }}{{ end }}{{ .Content }} [/INST]{{ else if eq .Role "assistant" }}{{ .Content }}</s>{{ end }}{{ end }}`,
+		Stop: []string{"</s>", "[INST]"},
+	},
+	{
+		// Gemma has no system role either and calls the assistant "model"
+		Name: "gemma",
+		Source: `{{- $system := .System }}{{ range .Messages }}{{ if ne .Role "system" }}<start_of_turn>{{ if eq .Role "assistant" }}model{{ else }}user{{ end }}
+{{ if and $system (eq .Role "user") }}{{ $system }}
+
+{{ $system = "" }}{{ end }}{{ .Content }}<end_of_turn>
+{{ end }}{{ end }}<start_of_turn>model
+`,
+		Stop: []string{"<end_of_turn>"},
+	},
+}
+
+// Names returns the names of the builtin templates
+func Names() []string {
+	names := make([]string, len(builtins))
+	for i, t := range builtins {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Lookup returns the builtin template called name
+func Lookup(name string) (Template, error) {
+	i := slices.IndexFunc(builtins, func(t Template) bool { return t.Name == name })
+	if i < 0 {
+		return Template{}, fmt.Errorf("unknown chat template %q (expected one of %s)", name, strings.Join(Names(), ", "))
+	}
+	return builtins[i], nil
+}
+
+// Prompt formats messages as a raw prompt, ending where the model should
+// start its answer. It has the signature of a client.PromptFunc.
+func (t Template) Prompt(messages []client.Message) (string, error) {
+	if t.Jinja {
+		return t.jinjaPrompt(messages)
+	}
+
+	tmpl, err := template.New(t.Name).Funcs(funcs).Parse(t.Source)
+	if err != nil {
+		return "", fmt.Errorf("invalid chat template %s: %w", t.Name, err)
+	}
+
+	data := Data{Messages: messages}
+	var system []string
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+		}
+	}
+	data.System = strings.Join(system, "\n\n")
+
+	if !strings.Contains(t.Source, ".Messages") {
+		return t.turnPrompt(tmpl, data)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to apply chat template %s: %w", t.Name, err)
+	}
+	return b.String(), nil
+}
+
+// funcs are the template functions Ollama provides besides the builtin ones
+var funcs = template.FuncMap{
+	"json": func(v any) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	},
+}
+
+// responseMarker stands in for the answer being generated, so the prompt
+// can be cut where it starts
+const responseMarker = "\x00response\x00"
+
+// turnPrompt executes a template that formats one prompt and response at a
+// time for every turn, the way Ollama does for templates without Messages.
+// The system prompt is only given to the first turn.
+func (t Template) turnPrompt(tmpl *template.Template, data Data) (string, error) {
+	var b strings.Builder
+	turn := Data{System: data.System}
+	execute := func() error {
+		if err := tmpl.Execute(&b, turn); err != nil {
+			return fmt.Errorf("failed to apply chat template %s: %w", t.Name, err)
+		}
+		turn = Data{}
+		return nil
+	}
+	for _, m := range data.Messages {
+		switch m.Role {
+		case "user":
+			if turn.Prompt != "" {
+				if err := execute(); err != nil {
+					return "", err
+				}
+			}
+			turn.Prompt = m.Content
+		case "assistant":
+			turn.Response = m.Content
+			if err := execute(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	turn.Response = responseMarker
+	if err := execute(); err != nil {
+		return "", err
+	}
+	prompt, _, _ := strings.Cut(b.String(), responseMarker)
+	return prompt, nil
+}
+
+// jinjaPrompt renders a Hugging Face chat template. The beginning of
+// sequence token is cut from the start of the prompt like in the builtin
+// templates, since servers add it when tokenizing.
+func (t Template) jinjaPrompt(messages []client.Message) (string, error) {
+	prompt, err := renderJinja(t.Source, map[string]any{
+		"messages":              messages,
+		"add_generation_prompt": true,
+		"bos_token":             t.BOSToken,
+		"eos_token":             t.EOSToken,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to apply chat template %s: %w", t.Name, err)
+	}
+	if t.BOSToken != "" {
+		prompt = strings.TrimPrefix(prompt, t.BOSToken)
+	}
+	return prompt, nil
+}
@@ -0,0 +1,74 @@
+package chattemplate
+
+import (
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+func TestPrompt(t *testing.T) {
+	messages := []client.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello!"},
+		{Role: "user", Content: "Bye"},
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{
+			name: "plain",
+			// Answers continue the text they follow
+			want: "Be brief.\n\nHiHello!\nBye",
+		},
+		{
+			name: "chatml",
+			want: "<|im_start|>system\nBe brief.<|im_end|>\n" +
+				"<|im_start|>user\nHi<|im_end|>\n" +
+				"<|im_start|>assistant\nHello!<|im_end|>\n" +
+				"<|im_start|>user\nBye<|im_end|>\n" +
+				"<|im_start|>assistant\n",
+		},
+		{
+			name: "llama3",
+			want: "<|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\nHello!<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nBye<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name: "mistral",
+			want: "[INST] Be brief.\n\nHi [/INST]Hello!</s>[INST] Bye [/INST]",
+		},
+		{
+			name: "gemma",
+			want: "<start_of_turn>user\nBe brief.\n\nHi<end_of_turn>\n" +
+				"<start_of_turn>model\nHello!<end_of_turn>\n" +
+				"<start_of_turn>user\nBye<end_of_turn>\n" +
+				"<start_of_turn>model\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Lookup(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.Prompt(messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	if _, err := Lookup("alpaca"); err == nil {
+		t.Error("expected an unknown template to be rejected")
+	}
+}
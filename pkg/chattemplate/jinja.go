@@ -0,0 +1,363 @@
+package chattemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+const (
+	// maxOutput bounds the size of a rendered chat template
+	maxOutput = 16 << 20
+	// maxRepeat bounds the product of the numbers a template multiplies by,
+	// since gonja repeats strings without a limit
+	maxRepeat = 1 << 20
+)
+
+var errTooLarge = fmt.Errorf("rendered template is larger than %d MiB", maxOutput>>20)
+
+// filters are gonja's filters with tojson formatting JSON like Python and
+// items keeping the order of dicts
+var filters = func() *exec.FilterSet {
+	set := exec.NewFilterSet(map[string]exec.FilterFunction{}).Update(gonja.DefaultEnvironment.Filters)
+	set.Replace("tojson", filterToJSON)
+	set.Replace("items", filterItems)
+	return set
+}()
+
+// renderJinja renders a Hugging Face chat template with vars, like
+// transformers does: with trim_blocks and lstrip_blocks, the raise_exception
+// and strftime_now globals and Python's JSON formatting in tojson. Values are
+// converted through JSON so structs become dicts keyed by their JSON field
+// names, in field order.
+func renderJinja(source string, vars map[string]any) (string, error) {
+	cfg := config.New()
+	cfg.TrimBlocks = true
+	cfg.LeftStripBlocks = true
+	if err := checkRepeats(source, cfg); err != nil {
+		return "", err
+	}
+
+	// Templates can only be rendered through a loader
+	loader, err := loaders.NewFileSystemLoader("")
+	if err != nil {
+		return "", err
+	}
+	loader, err = loaders.NewShiftedLoader("chat_template", strings.NewReader(source), loader)
+	if err != nil {
+		return "", err
+	}
+
+	// raise_exception's message is kept, since gonja wraps errors in its own text
+	var raised error
+	env := *gonja.DefaultEnvironment
+	env.Context = env.Context.Inherit().Update(exec.NewContext(map[string]any{
+		"raise_exception": func(message string) (string, error) {
+			raised = errors.New(message)
+			return "", raised
+		},
+		"strftime_now": func(format string) string {
+			return strftime(time.Now(), format)
+		},
+	}))
+	env.Filters = filters
+
+	tmpl, err := exec.NewTemplate("chat_template", cfg, loader, &env)
+	if err != nil {
+		return "", err
+	}
+	context := exec.NewContext(map[string]any{})
+	for name, value := range vars {
+		converted, err := convert(value)
+		if err != nil {
+			return "", err
+		}
+		context.Set(name, converted)
+	}
+	var out cappedBuffer
+	if err := tmpl.Execute(&out, context); err != nil {
+		switch {
+		case raised != nil:
+			return "", raised
+		case out.full:
+			return "", errTooLarge
+		}
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// checkRepeats only lets templates multiply by integer literals, up to a
+// product of maxRepeat, and rejects powers, so a template cannot repeat a
+// string until memory runs out
+func checkRepeats(source string, cfg *config.Config) error {
+	stream := tokens.LexAll(source, cfg)
+	product := 1
+	var previous *tokens.Token
+	for !stream.End() {
+		token := stream.Next()
+		switch token.Type {
+		case tokens.Power:
+			return fmt.Errorf("line %d: ** is not supported in chat templates", token.Line)
+		case tokens.Multiply:
+			factor := -1
+			for _, operand := range []*tokens.Token{previous, stream.Current()} {
+				if operand != nil && operand.Type == tokens.Integer {
+					if n, err := strconv.Atoi(operand.Val); err == nil {
+						factor = n
+					}
+				}
+			}
+			if factor < 0 {
+				return fmt.Errorf("line %d: chat templates may only multiply by integers", token.Line)
+			}
+			product *= max(factor, 1)
+			if product > maxRepeat {
+				return fmt.Errorf("line %d: chat templates may only multiply by up to %d in total", token.Line, maxRepeat)
+			}
+		}
+		previous = token
+	}
+	return nil
+}
+
+// cappedBuffer fails writes once maxOutput bytes have been written
+type cappedBuffer struct {
+	bytes.Buffer
+	full bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxOutput {
+		b.full = true
+		return 0, errTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// WriteString is capped too, since gonja writes through io.WriteString
+func (b *cappedBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// convert turns a value into a gonja value by encoding it as JSON, keeping
+// the order of object keys
+func convert(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeValue(decoder)
+}
+
+func decodeValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			list := []any{}
+			for decoder.More() {
+				item, err := decodeValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			_, err := decoder.Token()
+			return list, err
+		}
+		dict := exec.NewDict()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			dict.Pairs = append(dict.Pairs, &exec.Pair{Key: exec.AsValue(key), Value: exec.AsValue(value)})
+		}
+		_, err := decoder.Token()
+		return dict, err
+	case json.Number:
+		if n, err := token.Int64(); err == nil {
+			return int(n), nil
+		}
+		return token.Float64()
+	default:
+		return token, nil
+	}
+}
+
+// filterItems returns the key and value pairs of a dict in order
+func filterItems(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	dict, ok := in.Interface().(*exec.Dict)
+	if !ok {
+		items, _ := gonja.DefaultEnvironment.Filters.Get("items")
+		return items(e, in, params)
+	}
+	items := make([]any, len(dict.Pairs))
+	for i, pair := range dict.Pairs {
+		items[i] = []any{pair.Key, pair.Value}
+	}
+	return exec.AsValue(items)
+}
+
+// filterToJSON formats values like Python's json.dumps, as transformers'
+// tojson does: with ", " and ": " separators, or indented by indent spaces
+func filterToJSON(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	indent := 0
+	if value, ok := params.KwArgs["indent"]; ok && value.IsInteger() {
+		indent = value.Integer()
+	}
+	var b strings.Builder
+	if err := writeJSON(&b, in.Interface(), indent, 0); err != nil {
+		return exec.AsValue(err)
+	}
+	return exec.AsSafeValue(b.String())
+}
+
+func writeJSON(b *strings.Builder, v any, indent, depth int) error {
+	if b.Len() > maxOutput {
+		return errTooLarge
+	}
+	// separator starts the next item, on its own line when indenting
+	separator := func(first bool, closing bool) {
+		if indent == 0 {
+			if !first && !closing {
+				b.WriteString(", ")
+			}
+			return
+		}
+		if !first && !closing {
+			b.WriteString(",")
+		}
+		level := depth + 1
+		if closing {
+			level = depth
+		}
+		b.WriteString("\n" + strings.Repeat(" ", indent*level))
+	}
+	switch v := v.(type) {
+	case *exec.Value:
+		return writeJSON(b, v.Interface(), indent, depth)
+	case *exec.Dict:
+		b.WriteString("{")
+		for i, pair := range v.Pairs {
+			separator(i == 0, false)
+			if err := writeJSON(b, pair.Key.String(), indent, depth+1); err != nil {
+				return err
+			}
+			b.WriteString(": ")
+			if err := writeJSON(b, pair.Value, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(v.Pairs) > 0 {
+			separator(false, true)
+		}
+		b.WriteString("}")
+	case map[string]any:
+		dict := exec.NewDict()
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			dict.Pairs = append(dict.Pairs, &exec.Pair{Key: exec.AsValue(key), Value: exec.AsValue(v[key])})
+		}
+		return writeJSON(b, dict, indent, depth)
+	case []any:
+		b.WriteString("[")
+		for i, item := range v {
+			separator(i == 0, false)
+			if err := writeJSON(b, item, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(v) > 0 {
+			separator(false, true)
+		}
+		b.WriteString("]")
+	case exec.ValuesList:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return writeJSON(b, items, indent, depth)
+	default:
+		// Strings and numbers are formatted like Go, with non-ASCII characters
+		// kept as is like ensure_ascii=False
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		b.Write(bytes.TrimRight(out.Bytes(), "\n"))
+	}
+	return nil
+}
+
+// strftime formats t with the Python directives templates use for dates
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'y':
+			b.WriteString(t.Format("06"))
+		case 'Y':
+			b.WriteString(t.Format("2006"))
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'I':
+			b.WriteString(t.Format("03"))
+		case 'M':
+			b.WriteString(t.Format("04"))
+		case 'S':
+			b.WriteString(t.Format("05"))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
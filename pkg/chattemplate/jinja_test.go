@@ -0,0 +1,188 @@
+package chattemplate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChatTemplates(t *testing.T) {
+	conversation := []map[string]any{
+		{"role": "user", "content": "Hi"},
+		{"role": "assistant", "content": "Hello!"},
+		{"role": "user", "content": " What is Go? "},
+	}
+	weather := map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name":        "weather",
+			"description": "Get the weather",
+			"parameters": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"city": map[string]any{"type": "string"},
+					"days": map[string]any{"type": []any{"integer", "number"}},
+					"tags": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+			},
+		},
+	}
+	toolCall := []map[string]any{
+		{"role": "user", "content": "What's the weather in Paris?"},
+		{"role": "assistant", "content": "", "tool_calls": []map[string]any{
+			{"function": map[string]any{"name": "weather", "arguments": map[string]any{"city": "Paris"}}},
+		}},
+		{"role": "tool", "content": "18°C"},
+	}
+
+	tests := []struct {
+		template string
+		messages []map[string]any
+		tools    []any
+		want     []string
+		wantErr  string
+	}{
+		{
+			template: "llama3",
+			messages: conversation,
+			want: []string{
+				"<s><|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>",
+				"<|start_header_id|>assistant<|end_header_id|>\n\nHello!<|eot_id|>",
+				"<|start_header_id|>user<|end_header_id|>\n\nWhat is Go?<|eot_id|>",
+				"<|start_header_id|>assistant<|end_header_id|>\n\n",
+			},
+		},
+		{
+			template: "mistral",
+			messages: conversation,
+			want:     []string{"<s>[INST] Hi [/INST]Hello!</s>[INST]  What is Go?  [/INST]"},
+		},
+		{
+			template: "mistral",
+			messages: []map[string]any{{"role": "assistant", "content": "Hello!"}},
+			wantErr:  "Conversation roles must alternate user/assistant/user/assistant/...",
+		},
+		{
+			template: "gemma",
+			messages: conversation,
+			want: []string{
+				"<s><start_of_turn>user\nHi<end_of_turn>\n",
+				"<start_of_turn>model\nHello!<end_of_turn>\n",
+				"<start_of_turn>user\nWhat is Go?<end_of_turn>\n",
+				"<start_of_turn>model\n",
+			},
+		},
+		{
+			template: "gemma",
+			messages: []map[string]any{{"role": "system", "content": "Be brief."}},
+			wantErr:  "System role not supported",
+		},
+		{
+			template: "phi3",
+			messages: append([]map[string]any{{"role": "system", "content": "Be brief."}}, conversation...),
+			want: []string{
+				"<|system|>\nBe brief.<|end|>\n",
+				"<|user|>\nHi<|end|>\n",
+				"<|assistant|>\nHello!<|end|>\n",
+				"<|user|>\n What is Go? <|end|>\n",
+				"<|assistant|>\n",
+			},
+		},
+		{
+			template: "qwen2.5",
+			messages: toolCall,
+			want: []string{
+				"<|im_start|>system\nYou are Qwen, created by Alibaba Cloud. You are a helpful assistant.<|im_end|>\n",
+				"<|im_start|>user\nWhat's the weather in Paris?<|im_end|>\n",
+				"<|im_start|>assistant\n<tool_call>\n{\"name\": \"weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call><|im_end|>\n",
+				"<|im_start|>user\n<tool_response>\n18°C\n</tool_response><|im_end|>\n",
+				"<|im_start|>assistant\n",
+			},
+		},
+		{
+			// Uses a recursive macro to describe the tool's parameters
+			template: "hermes2-pro-tool-use",
+			messages: toolCall,
+			tools:    []any{weather},
+			want: []string{
+				"<s><|im_start|>system\nYou are a function calling AI model. You are provided with function signatures within <tools></tools> XML tags. Here are the available tools: <tools> ",
+				"{\"type\": \"function\", \"function\": {\"name\": \"weather\", \"description\": \"weather(city: str, days: Union[int,float], tags: list[str]) - Get the weather\n\n",
+				"\"parameters\": {\"properties\": {\"city\": {\"type\": \"string\"}, \"days\": {\"type\": [\"integer\", \"number\"]}, \"tags\": {\"items\": {\"type\": \"string\"}, \"type\": \"array\"}}, \"type\": \"object\"}} </tools><|im_end|>\n",
+				"<|im_start|>user\nWhat's the weather in Paris?<|im_end|>\n",
+				"<|im_start|>assistant\n<tool_call>\n{\"name\": \"weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call><|im_end|>\n",
+				"<|im_start|>tool\n<tool_response>\n18°C\n</tool_response><|im_end|>\n",
+				"<|im_start|>assistant\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			source, err := os.ReadFile("testdata/" + tt.template + ".jinja")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := renderJinja(string(source), map[string]any{
+				"messages":              tt.messages,
+				"tools":                 tt.tools,
+				"bos_token":             "<s>",
+				"eos_token":             "</s>",
+				"add_generation_prompt": true,
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(tt.want, ""); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRenderJinja(t *testing.T) {
+	type tool struct {
+		Name string `json:"name"`
+		Args string `json:"args"`
+	}
+	vars := map[string]any{"tool": tool{Name: "ls", Args: "é"}}
+
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr string
+	}{
+		{name: "tojson", source: "{{ tool|tojson }}", want: `{"name": "ls", "args": "é"}`},
+		{name: "tojson indent", source: "{{ tool|tojson(indent=1) }}", want: "{\n \"name\": \"ls\",\n \"args\": \"é\"\n}"},
+		{name: "items in order", source: "{% for k, v in tool|items %}{{ k }}={{ v }};{% endfor %}", want: "name=ls;args=é;"},
+		{name: "repeat", source: "{{ '-' * 3 }}", want: "---"},
+		{name: "raise_exception", source: "{{ raise_exception('no tools') }}", wantErr: "no tools"},
+		{name: "large repeat", source: "{{ 'x' * 1000000000000 }}", wantErr: "line 1: chat templates may only multiply by up to 1048576 in total"},
+		{name: "nested repeats", source: "{{ ('x' * 1024) * 1024 * 2 }}", wantErr: "line 1: chat templates may only multiply by up to 1048576 in total"},
+		{name: "repeat by variable", source: "{{ 'x' * n }}", wantErr: "line 1: chat templates may only multiply by integers"},
+		{name: "power", source: "{{ 2 ** 64 }}", wantErr: "line 1: ** is not supported in chat templates"},
+		{name: "output limit", source: "{% for i in range(64) %}{{ 'x' * 1048576 }}{% endfor %}", wantErr: errTooLarge.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderJinja(tt.source, vars)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package chattemplate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoTemplate is returned by ForModel when a model directory has no chat template
+var ErrNoTemplate = errors.New("model has no chat template")
+
+// ForModel reads the chat template shipped with the model in dir, from an
+// Ollama Modelfile, a chat_template.jinja file or tokenizer_config.json, in
+// that order. The template is named after the file it was read from.
+func ForModel(dir string) (Template, error) {
+	modelfile := filepath.Join(dir, "Modelfile")
+	if data, err := os.ReadFile(modelfile); err == nil {
+		t, err := FromModelfile(modelfile, data)
+		if !errors.Is(err, ErrNoTemplate) {
+			return t, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Template{}, err
+	}
+
+	configPath := filepath.Join(dir, "tokenizer_config.json")
+	config, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		config, configPath = nil, ""
+	} else if err != nil {
+		return Template{}, err
+	}
+
+	// Newer Hugging Face models keep the template in a file of its own
+	jinjaPath := filepath.Join(dir, "chat_template.jinja")
+	if source, err := os.ReadFile(jinjaPath); err == nil {
+		t := Template{Name: jinjaPath, Source: string(source), Jinja: true}
+		if config != nil {
+			var tc tokenizerConfig
+			if err := json.Unmarshal(config, &tc); err != nil {
+				return Template{}, fmt.Errorf("invalid %s: %w", configPath, err)
+			}
+			t.BOSToken, t.EOSToken = tc.BOSToken.Content, tc.EOSToken.Content
+			if t.EOSToken != "" {
+				t.Stop = []string{t.EOSToken}
+			}
+		}
+		return t, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Template{}, err
+	}
+
+	if config == nil {
+		return Template{}, ErrNoTemplate
+	}
+	return FromTokenizerConfig(configPath, config)
+}
+
+// FromModelfile reads the TEMPLATE and stop parameters of an Ollama Modelfile
+func FromModelfile(name string, data []byte) (Template, error) {
+	t := Template{Name: name}
+	s := string(data)
+	for s != "" {
+		var line string
+		line, s, _ = strings.Cut(s, "\n")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		instruction, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		// Triple-quoted values may span lines
+		var value string
+		if strings.HasPrefix(rest, `"""`) {
+			rest = rest[3:] + "\n" + s
+			end := strings.Index(rest, `"""`)
+			if end < 0 {
+				return Template{}, fmt.Errorf("invalid Modelfile %s: unterminated %s", name, instruction)
+			}
+			value = rest[:end]
+			s = rest[end+3:]
+			// Skip the rest of the closing line
+			_, s, _ = strings.Cut(s, "\n")
+		} else {
+			value = unquote(rest)
+		}
+
+		switch strings.ToUpper(instruction) {
+		case "TEMPLATE":
+			t.Source = value
+		case "PARAMETER":
+			key, param, _ := strings.Cut(value, " ")
+			if key == "stop" {
+				t.Stop = append(t.Stop, unquote(strings.TrimSpace(param)))
+			}
+		}
+	}
+	if t.Source == "" {
+		return Template{}, ErrNoTemplate
+	}
+	return t, nil
+}
+
+func unquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// tokenizerConfig holds the fields of tokenizer_config.json used to render
+// chat templates
+type tokenizerConfig struct {
+	ChatTemplate chatTemplates `json:"chat_template"`
+	BOSToken     specialToken  `json:"bos_token"`
+	EOSToken     specialToken  `json:"eos_token"`
+}
+
+// chatTemplates is either a single template or a list of named ones, of
+// which the one named "default" is used
+type chatTemplates string
+
+func (c *chatTemplates) UnmarshalJSON(data []byte) error {
+	var source string
+	if err := json.Unmarshal(data, &source); err == nil {
+		*c = chatTemplates(source)
+		return nil
+	}
+	var named []struct {
+		Name     string `json:"name"`
+		Template string `json:"template"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	for _, t := range named {
+		if t.Name == "default" {
+			*c = chatTemplates(t.Template)
+		}
+	}
+	return nil
+}
+
+// specialToken is either the token itself or an object with its content
+type specialToken struct {
+	Content string `json:"content"`
+}
+
+func (t *specialToken) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Content); err == nil {
+		return nil
+	}
+	var token struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return err
+	}
+	t.Content = token.Content
+	return nil
+}
+
+// FromTokenizerConfig reads the chat template and special tokens of a
+// Hugging Face tokenizer_config.json
+func FromTokenizerConfig(name string, data []byte) (Template, error) {
+	var config tokenizerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Template{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if config.ChatTemplate == "" {
+		return Template{}, ErrNoTemplate
+	}
+	t := Template{
+		Name:     name,
+		Source:   string(config.ChatTemplate),
+		Jinja:    true,
+		BOSToken: config.BOSToken.Content,
+		EOSToken: config.EOSToken.Content,
+	}
+	if t.EOSToken != "" {
+		t.Stop = []string{t.EOSToken}
+	}
+	return t, nil
+}
@@ -0,0 +1,102 @@
+package chattemplate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+func TestForModel(t *testing.T) {
+	messages := []client.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello!"},
+		{Role: "user", Content: "Bye"},
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+		stop  []string
+	}{
+		{
+			name: "Modelfile with messages",
+			files: map[string]string{"Modelfile": `FROM ./model.gguf
+TEMPLATE """{{- range .Messages }}<|{{ .Role }}|>
+{{ .Content }}<|end|>
+{{ end }}<|assistant|>
+"""
+PARAMETER stop "<|end|>"
+PARAMETER temperature 0.7
+`},
+			want: "<|system|>\nBe brief.<|end|>\n<|user|>\nHi<|end|>\n<|assistant|>\nHello!<|end|>\n<|user|>\nBye<|end|>\n<|assistant|>\n",
+			stop: []string{"<|end|>"},
+		},
+		{
+			name: "Modelfile with a prompt and response per turn",
+			files: map[string]string{"Modelfile": `TEMPLATE """{{ if .System }}<<SYS>>{{ .System }}<</SYS>>
+{{ end }}[INST] {{ .Prompt }} [/INST] {{ .Response }}</s>"""`},
+			want: "<<SYS>>Be brief.<</SYS>>\n[INST] Hi [/INST] Hello!</s>[INST] Bye [/INST] ",
+		},
+		{
+			name: "tokenizer config",
+			files: map[string]string{"tokenizer_config.json": `{
+				"bos_token": {"content": "<s>", "lstrip": false},
+				"eos_token": "</s>",
+				"chat_template": [
+					{"name": "tool_use", "template": "unused"},
+					{"name": "default", "template": "{{ bos_token }}{% for m in messages %}{% if m.role != 'system' %}<{{ m.role }}>{{ m.content }}{{ eos_token }}{% endif %}{% endfor %}{% if add_generation_prompt %}<assistant>{% endif %}"}
+				]
+			}`},
+			want: "<user>Hi</s><assistant>Hello!</s><user>Bye</s><assistant>",
+			stop: []string{"</s>"},
+		},
+		{
+			name: "chat_template.jinja overrides the tokenizer config",
+			files: map[string]string{
+				"tokenizer_config.json": `{"eos_token": "<eos>", "chat_template": "unused"}`,
+				"chat_template.jinja":   "{{ messages | length }} messages",
+			},
+			want: "4 messages",
+			stop: []string{"<eos>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tmpl, err := ForModel(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.Prompt(messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(tmpl.Stop, tt.stop) {
+				t.Errorf("got stop sequences %q, want %q", tmpl.Stop, tt.stop)
+			}
+		})
+	}
+}
+
+func TestForModelWithoutTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Modelfile"), []byte("FROM ./model.gguf\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ForModel(dir); !errors.Is(err, ErrNoTemplate) {
+		t.Errorf("expected ErrNoTemplate, got %v", err)
+	}
+}
@@ -1,22 +1,97 @@
 package client
 
 import (
-	"bufio"
-	"errors"
+	"context"
+	"encoding/json"
 
 	"github.com/changminbark/golms/pkg/constants"
 )
 
 type Message struct {
-	Role      string        `json:"role"`
-	Content   string        `json:"content"`
-	ToolCalls []interface{} `json:"tool_calls"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" role message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Name is the name of the tool that produced a "tool" role message
+	Name string `json:"name,omitempty"`
+	// ReasoningContent holds reasoning returned separately from the content
+	// by servers that parse <think> blocks themselves
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// Images are sent as content parts alongside the text of the message
+	Images []Image `json:"-"`
+}
+
+// Image is a base64-encoded image attached to a message
+type Image struct {
+	MimeType string
+	Data     string
+}
+
+// DataURL returns the image as a data: URL
+func (i Image) DataURL() string {
+	return "data:" + i.MimeType + ";base64," + i.Data
+}
+
+// ContentPart is one part of a multimodal message in the OpenAI format
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	// Index identifies the call a streamed fragment belongs to
+	Index    int          `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name string `json:"name"`
+	// Arguments is a JSON object encoded as a string
+	Arguments string `json:"arguments"`
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the function's arguments
+	Parameters map[string]any `json:"parameters"`
+}
+
+// Capabilities describes what a model server backend accepts
+type Capabilities struct {
+	Images bool
 }
 
 type ChatOptions struct {
 	Temperature float64
 	MaxTokens   int
 	Stream      bool
+	Sampling    Sampling
+	// LogProbs requests the log probabilities of this many top alternatives
+	// for every generated token, 0 disables them
+	LogProbs int
+}
+
+// DefaultChatOptions are used until the user configures a chat
+var DefaultChatOptions = ChatOptions{
+	Temperature: 0.7,
+	MaxTokens:   512,
+	Stream:      false,
 }
 
 type ChatRequest struct {
@@ -24,6 +99,49 @@ type ChatRequest struct {
 	Temperature float64   `json:"temperature"`
 	MaxTokens   int       `json:"max_tokens"`
 	Stream      bool      `json:"stream"`
+	Sampling
+	Tools []Tool `json:"tools,omitempty"`
+	// ResponseFormat constrains the response to JSON, optionally matching a schema
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	LogProbs       bool            `json:"logprobs,omitempty"`
+	TopLogProbs    int             `json:"top_logprobs,omitempty"`
+}
+
+type ResponseFormat struct {
+	// Type is "json_object" or "json_schema"
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+// JSONSchemaFormat requests a response matching the given JSON schema
+func JSONSchemaFormat(schema json.RawMessage) *ResponseFormat {
+	return &ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &JSONSchema{Name: "response", Schema: schema, Strict: true},
+	}
+}
+
+// NewChatRequest creates an empty conversation using the given options
+func NewChatRequest(options ChatOptions) *ChatRequest {
+	req := &ChatRequest{Messages: []Message{}}
+	req.SetOptions(options)
+	return req
+}
+
+// SetOptions applies chat options to the request, keeping its messages
+func (r *ChatRequest) SetOptions(options ChatOptions) {
+	r.Temperature = options.Temperature
+	r.MaxTokens = options.MaxTokens
+	r.Stream = options.Stream
+	r.Sampling = options.Sampling
+	r.LogProbs = options.LogProbs > 0
+	r.TopLogProbs = options.LogProbs
 }
 
 type ChatResponse struct {
@@ -37,16 +155,10 @@ type ChatResponse struct {
 }
 
 type Choice struct {
-	Index        int      `json:"index"`
-	FinishReason string   `json:"finish_reason"`
-	LogProbs     LogProbs `json:"logprobs"`
-	Message      Message  `json:"message"`
-}
-
-type LogProbs struct {
-	TokenLogProbs []float64     `json:"token_logprobs"`
-	TopLogProbs   []interface{} `json:"top_logprobs"` // Empty array in this case
-	Tokens        []int         `json:"tokens"`
+	Index        int       `json:"index"`
+	FinishReason string    `json:"finish_reason"`
+	LogProbs     *LogProbs `json:"logprobs"`
+	Message      Message   `json:"message"`
 }
 
 type Usage struct {
@@ -55,27 +167,57 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-type ModelServerClient interface {
-	StartChat() error
-	setChatOptions()
-	addUserMessage(req *ChatRequest) error
-	sendChatReq(req *ChatRequest) (*ChatResponse, error)
+// ChatChunk is a single server-sent event of a streamed chat response
+type ChatChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Model   string        `json:"model"`
+	Created int64         `json:"created"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage"`
+	// Error is set instead of choices when the server fails mid-stream
+	Error json.RawMessage `json:"error,omitempty"`
 }
 
-var ErrExitRequested = errors.New("user requested exit")
+type ChunkChoice struct {
+	Index        int       `json:"index"`
+	FinishReason string    `json:"finish_reason"`
+	Delta        Message   `json:"delta"`
+	LogProbs     *LogProbs `json:"logprobs"`
+}
 
-func NewClient(model_server string, llm string, host string, port int, reader *bufio.Reader) ModelServerClient {
-	// Set default ChatOptions for initialization
-	defaultChatOptions := ChatOptions{
-		Temperature: 0.7,
-		MaxTokens:   512,
-		Stream:      false,
-	}
+// Delta is a piece of a streamed response
+type Delta struct {
+	Content   string
+	Reasoning string
+}
+
+// DeltaFunc receives each piece of a response as it is streamed from the model server
+type DeltaFunc func(delta Delta)
+
+type ModelServerClient interface {
+	LLM() string
+	Capabilities() Capabilities
+	// Chat sends the conversation in req and returns the model's response.
+	// When req.Stream is set, onDelta is called for every streamed piece of content.
+	// The response is not appended to req.Messages; that is left to the caller.
+	// Cancelling ctx aborts the request, including a response being streamed.
+	Chat(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error)
+	// Embed computes embeddings with the LLM, which must be an embedding model
+	Embedder
+	// Complete continues a raw prompt without any chat template
+	Completer
+}
 
+func NewClient(model_server string, llm string, host string, port int) ModelServerClient {
 	// Create client
 	switch model_server {
 	case constants.Mlx_lm:
-		return &MlxLMClient{llm, host, port, defaultChatOptions, reader}
+		return &MlxLMClient{llm, localConn(host, port), Capabilities{}, false}
+	case constants.Mlx_vlm:
+		return &MlxLMClient{llm, localConn(host, port), Capabilities{Images: true}, false}
+	case constants.Ollama:
+		return &OllamaClient{llm, localConn(host, port)}
 	default:
 		return nil
 	}
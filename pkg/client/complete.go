@@ -0,0 +1,309 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Completer continues raw text prompts, for base models that are not
+// instruction-tuned
+type Completer interface {
+	// Complete sends a raw prompt and returns its continuation. When
+	// req.Stream is set, onDelta is called for every streamed piece of text.
+	Complete(ctx context.Context, req *CompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error)
+}
+
+type CompletionRequest struct {
+	// Model is filled in by clients of servers that serve several models
+	Model       string  `json:"model,omitempty"`
+	Prompt      string  `json:"prompt"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
+	Stream      bool    `json:"stream"`
+	Sampling
+}
+
+// NewCompletionRequest creates a request to continue prompt using the given options
+func NewCompletionRequest(prompt string, options ChatOptions) *CompletionRequest {
+	return &CompletionRequest{
+		Prompt:      prompt,
+		Temperature: options.Temperature,
+		MaxTokens:   options.MaxTokens,
+		Stream:      options.Stream,
+		Sampling:    options.Sampling,
+	}
+}
+
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Model   string             `json:"model"`
+	Created int64              `json:"created"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   Usage              `json:"usage"`
+}
+
+type CompletionChoice struct {
+	Index        int    `json:"index"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// Text returns the generated continuation
+func (r *CompletionResponse) Text() string {
+	if r == nil || len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Text
+}
+
+// completionChunk is a single server-sent event of a streamed completion
+type completionChunk struct {
+	CompletionResponse
+	Usage *Usage `json:"usage"`
+	// Error is set instead of choices when the server fails mid-stream
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// Complete sends the prompt to the OpenAI-compatible /v1/completions endpoint
+func (c *MlxLMClient) Complete(ctx context.Context, req *CompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	// Only remote servers are told the model, like for chat requests
+	if c.remote {
+		named := *req
+		named.Model = c.llm
+		req = &named
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.conn.postJSON(ctx, "/v1/completions", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !req.Stream {
+		var completionResp CompletionResponse
+		if err := json.NewDecoder(resp.Body).Decode(&completionResp); err != nil {
+			return nil, malformed("invalid JSON", err)
+		}
+		if len(completionResp.Choices) == 0 {
+			return nil, malformed("response has no choices", nil)
+		}
+		return &completionResp, nil
+	}
+
+	// Assemble the streamed chunks into a single response
+	completionResp := &CompletionResponse{Object: "text_completion"}
+	var text strings.Builder
+	finishReason := ""
+	err = readEvents(resp.Body, func(data []byte) error {
+		var chunk completionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return malformed("invalid stream chunk", err)
+		}
+		if message := streamErrorMessage(chunk.Error); message != "" {
+			return serverError(0, message)
+		}
+		completionResp.ID = chunk.ID
+		completionResp.Model = chunk.Model
+		completionResp.Created = chunk.Created
+		if chunk.Usage != nil {
+			completionResp.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if choice.Text != "" {
+				text.WriteString(choice.Text)
+				if onDelta != nil {
+					onDelta(Delta{Content: choice.Text})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	completionResp.Choices = []CompletionChoice{{Text: text.String(), FinishReason: finishReason}}
+	return completionResp, nil
+}
+
+type ollamaGenerateRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	// Raw sends the prompt as is instead of through the model's template
+	Raw     bool           `json:"raw"`
+	Stream  bool           `json:"stream"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type ollamaGenerateResponse struct {
+	Model           string    `json:"model"`
+	CreatedAt       time.Time `json:"created_at"`
+	Response        string    `json:"response"`
+	Done            bool      `json:"done"`
+	DoneReason      string    `json:"done_reason"`
+	PromptEvalCount int       `json:"prompt_eval_count"`
+	EvalCount       int       `json:"eval_count"`
+	Error           string    `json:"error"`
+}
+
+// Complete sends the prompt to Ollama's /api/generate endpoint in raw mode
+func (c *OllamaClient) Complete(ctx context.Context, req *CompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	options, err := ollamaOptions(&ChatRequest{Temperature: req.Temperature, MaxTokens: req.MaxTokens, Sampling: req.Sampling})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(ollamaGenerateRequest{
+		Model:   c.llm,
+		Prompt:  req.Prompt,
+		Raw:     true,
+		Stream:  req.Stream,
+		Options: options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.conn.postJSON(ctx, "/api/generate", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Responses are newline-delimited JSON objects, a single one when not streaming
+	var text strings.Builder
+	var last ollamaGenerateResponse
+	received := false
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaGenerateResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, malformed("invalid JSON", err)
+		}
+		if chunk.Error != "" {
+			return nil, serverError(0, chunk.Error)
+		}
+		received = true
+
+		text.WriteString(chunk.Response)
+		if onDelta != nil && chunk.Response != "" {
+			onDelta(Delta{Content: chunk.Response})
+		}
+		last = chunk
+		if chunk.Done {
+			break
+		}
+	}
+	if !received {
+		return nil, malformed("empty response", nil)
+	}
+
+	return &CompletionResponse{
+		Object:  "text_completion",
+		Model:   last.Model,
+		Created: last.CreatedAt.Unix(),
+		Choices: []CompletionChoice{{Text: text.String(), FinishReason: last.DoneReason}},
+		Usage: Usage{
+			PromptTokens:     last.PromptEvalCount,
+			CompletionTokens: last.EvalCount,
+			TotalTokens:      last.PromptEvalCount + last.EvalCount,
+		},
+	}, nil
+}
+
+// CountTokens returns the number of tokens prompt is tokenized into by the
+// model server, by asking for a single token and reading the usage
+func CountTokens(ctx context.Context, c Completer, prompt string) (int, error) {
+	resp, err := c.Complete(ctx, &CompletionRequest{Prompt: prompt, MaxTokens: 1}, nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Usage.PromptTokens == 0 {
+		return 0, malformed("no prompt token count in the usage", nil)
+	}
+	return resp.Usage.PromptTokens, nil
+}
+
+// EstimateTokens guesses the number of tokens of text at about four
+// characters per token, for when no model server can count them
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// PromptFunc formats a conversation as a raw prompt
+type PromptFunc func(messages []Message) (string, error)
+
+// completionChat is a client that answers chat requests with completions
+type completionChat struct {
+	ModelServerClient
+	prompt PromptFunc
+	stop   []string
+}
+
+// WithCompletion returns a client that answers chat requests through the
+// completion endpoint, formatting each conversation with prompt. stop is
+// added to the stop sequences of every request, e.g. a template's end of turn.
+func WithCompletion(c ModelServerClient, prompt PromptFunc, stop []string) ModelServerClient {
+	return &completionChat{c, prompt, stop}
+}
+
+// Capabilities reports no images, since prompts are plain text
+func (c *completionChat) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (c *completionChat) Chat(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	if len(req.Tools) > 0 || req.ResponseFormat != nil {
+		return nil, &Error{Class: ErrBadRequest, Message: "tools and response formats are not supported in completion mode"}
+	}
+	prompt, err := c.prompt(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	completionReq := &CompletionRequest{
+		Prompt:      prompt,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      req.Stream,
+		Sampling:    req.Sampling,
+	}
+	completionReq.Stop = slices.Clone(req.Stop)
+	for _, stop := range c.stop {
+		if !slices.Contains(completionReq.Stop, stop) {
+			completionReq.Stop = append(completionReq.Stop, stop)
+		}
+	}
+	completionResp, err := c.Complete(ctx, completionReq, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatResponse{
+		ID:      completionResp.ID,
+		Object:  "chat.completion",
+		Model:   completionResp.Model,
+		Created: completionResp.Created,
+		Choices: []Choice{{
+			FinishReason: completionResp.Choices[0].FinishReason,
+			Message:      Message{Role: "assistant", Content: completionResp.Text()},
+		}},
+		Usage: completionResp.Usage,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/constants"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+		stream bool
+		path   string
+		body   string
+	}{
+		{
+			name:   "openai",
+			server: constants.Mlx_lm,
+			path:   "/v1/completions",
+			body:   `{"id": "cmpl-1", "choices": [{"index": 0, "text": " upon a time", "finish_reason": "stop"}]}`,
+		},
+		{
+			name:   "openai stream",
+			server: constants.Mlx_lm,
+			stream: true,
+			path:   "/v1/completions",
+			body: "data: {\"choices\": [{\"index\": 0, \"text\": \" upon\"}]}\n\n" +
+				"data: {\"choices\": [{\"index\": 0, \"text\": \" a time\", \"finish_reason\": \"stop\"}]}\n\n" +
+				"data: [DONE]\n\n",
+		},
+		{
+			name:   "ollama",
+			server: constants.Ollama,
+			path:   "/api/generate",
+			body:   `{"response": " upon a time", "done": true, "done_reason": "stop"}`,
+		},
+		{
+			name:   "ollama stream",
+			server: constants.Ollama,
+			stream: true,
+			path:   "/api/generate",
+			body: `{"response": " upon", "done": false}` + "\n" +
+				`{"response": " a time", "done": false}` + "\n" +
+				`{"response": "", "done": true, "done_reason": "stop", "prompt_eval_count": 3, "eval_count": 4}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.server, func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]any
+				json.NewDecoder(r.Body).Decode(&req)
+				if r.URL.Path != tt.path || req["prompt"] != "Once" {
+					http.Error(w, fmt.Sprintf("unexpected request %s %v", r.URL.Path, req), http.StatusBadRequest)
+					return
+				}
+				// Ollama must not apply the model's own template to the prompt
+				if tt.server == constants.Ollama && req["raw"] != true {
+					http.Error(w, "expected a raw prompt", http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, tt.body)
+			})
+
+			options := DefaultChatOptions
+			options.Stream = tt.stream
+			var deltas []string
+			resp, err := c.Complete(context.Background(), NewCompletionRequest("Once", options), func(delta Delta) {
+				deltas = append(deltas, delta.Content)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Text() != " upon a time" || resp.Choices[0].FinishReason != "stop" {
+				t.Errorf("unexpected response %+v", resp)
+			}
+			if tt.stream && strings.Join(deltas, "") != " upon a time" {
+				t.Errorf("unexpected deltas %q", deltas)
+			}
+		})
+	}
+}
+
+func TestCompleteStreamError(t *testing.T) {
+	c := newTestClient(t, constants.Ollama, respond(http.StatusOK, `{"error": "context length exceeded"}`))
+	_, err := c.Complete(context.Background(), &CompletionRequest{Prompt: "Once", Stream: true}, nil)
+	if !errors.Is(err, ErrContextOverflow) {
+		t.Errorf("expected ErrContextOverflow, got %v", err)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	c := newTestClient(t, constants.Mlx_lm, func(w http.ResponseWriter, r *http.Request) {
+		var req CompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.MaxTokens != 1 || req.Stream {
+			http.Error(w, fmt.Sprintf("unexpected request %+v", req), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"choices": [{"text": "!"}], "usage": {"prompt_tokens": 12, "completion_tokens": 1}}`)
+	})
+	n, err := CountTokens(context.Background(), c, "Hello there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 {
+		t.Errorf("got %d tokens, want 12", n)
+	}
+}
+
+func TestWithCompletion(t *testing.T) {
+	var got CompletionRequest
+	c := newTestClient(t, constants.Mlx_lm, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"choices": [{"index": 0, "text": "Hi there", "finish_reason": "stop"}]}`)
+	})
+	prompt := func(messages []Message) (string, error) {
+		var b strings.Builder
+		for _, m := range messages {
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Content)
+		}
+		b.WriteString("assistant:")
+		return b.String(), nil
+	}
+	chat := WithCompletion(c, prompt, []string{"\nuser:"})
+
+	req := NewChatRequest(DefaultChatOptions)
+	req.Messages = []Message{{Role: "user", Content: "Hello"}}
+	req.Stop = []string{"END"}
+	resp, err := chat.Chat(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Prompt != "user: Hello\nassistant:" || !slices.Equal(got.Stop, []string{"END", "\nuser:"}) {
+		t.Errorf("unexpected completion request %+v", got)
+	}
+	if resp.Choices[0].Message.Content != "Hi there" || resp.Choices[0].Message.Role != "assistant" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if !slices.Equal(req.Stop, []string{"END"}) {
+		t.Errorf("request stop sequences were modified: %q", req.Stop)
+	}
+
+	req.Tools = []Tool{{}}
+	if _, err := chat.Chat(context.Background(), req, nil); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected tools to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Embedder computes embeddings. Every ModelServerClient is one, and code that
+// only embeds such as rag depends on this instead.
+type Embedder interface {
+	// Embed returns one vector per input, in the same order
+	Embed(ctx context.Context, inputs []string) ([][]float32, error)
+}
+
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingsResponse is the response of the OpenAI /v1/embeddings endpoint
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// ollamaEmbedResponse is the response of Ollama's /api/embed endpoint
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed computes embeddings through the OpenAI-compatible /v1/embeddings endpoint
+func (c *MlxLMClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	var embeddingsResp embeddingsResponse
+	if err := c.conn.postEmbed(ctx, "/v1/embeddings", embeddingsRequest{c.llm, inputs}, &embeddingsResp); err != nil {
+		return nil, err
+	}
+
+	// Servers may answer out of order, so place vectors by their index
+	vectors := make([][]float32, len(inputs))
+	for _, data := range embeddingsResp.Data {
+		if data.Index < 0 || data.Index >= len(vectors) {
+			return nil, malformed(fmt.Sprintf("embedding index %d out of range", data.Index), nil)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	return vectors, checkEmbeddings(vectors)
+}
+
+// Embed computes embeddings through Ollama's /api/embed endpoint
+func (c *OllamaClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	var embedResp ollamaEmbedResponse
+	if err := c.conn.postEmbed(ctx, "/api/embed", embeddingsRequest{c.llm, inputs}, &embedResp); err != nil {
+		return nil, err
+	}
+	if len(embedResp.Embeddings) != len(inputs) {
+		return nil, malformed(fmt.Sprintf("expected %d embeddings, got %d", len(inputs), len(embedResp.Embeddings)), nil)
+	}
+	return embedResp.Embeddings, checkEmbeddings(embedResp.Embeddings)
+}
+
+// postEmbed posts an embeddings request and decodes the response into v
+func (c conn) postEmbed(ctx context.Context, path string, req embeddingsRequest, v any) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.postJSON(ctx, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return malformed("invalid JSON", err)
+	}
+	return nil
+}
+
+// checkEmbeddings reports a malformed response unless every vector is present
+// and all have the same number of dimensions
+func checkEmbeddings(vectors [][]float32) error {
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return malformed(fmt.Sprintf("missing embedding for input %d", i+1), nil)
+		}
+		if len(vector) != len(vectors[0]) {
+			return malformed("embeddings have different dimensions", nil)
+		}
+	}
+	return nil
+}
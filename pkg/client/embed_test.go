@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/constants"
+)
+
+func TestEmbed(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+		path   string
+		body   string
+	}{
+		{
+			name:   "openai",
+			server: constants.Mlx_lm,
+			path:   "/v1/embeddings",
+			// Out of order on purpose, vectors are placed by index
+			body: `{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`,
+		},
+		{
+			name:   "ollama",
+			server: constants.Ollama,
+			path:   "/api/embed",
+			body:   `{"embeddings": [[1, 0], [0, 1]]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.server, func(w http.ResponseWriter, r *http.Request) {
+				var req embeddingsRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				if r.URL.Path != tt.path || req.Model != "test" || len(req.Input) != 2 {
+					http.Error(w, fmt.Sprintf("unexpected request %s %+v", r.URL.Path, req), http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, tt.body)
+			})
+			vectors, err := c.Embed(context.Background(), []string{"a", "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+				t.Errorf("unexpected vectors %v", vectors)
+			}
+		})
+	}
+}
+
+func TestEmbedMalformed(t *testing.T) {
+	for _, body := range []string{
+		`{"data": [{"index": 0, "embedding": [1, 0]}]}`,
+		`{"data": [{"index": 0, "embedding": [1, 0]}, {"index": 1, "embedding": [1]}]}`,
+		`{"data": [{"index": 5, "embedding": [1, 0]}]}`,
+		`not json`,
+	} {
+		c := newTestClient(t, constants.Mlx_lm, respond(http.StatusOK, body))
+		if _, err := c.Embed(context.Background(), []string{"a", "b"}); !errors.Is(err, ErrMalformedResponse) {
+			t.Errorf("expected ErrMalformedResponse for %s, got %v", body, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Classes of errors returned by clients, to be checked with errors.Is
+var (
+	// ErrServerUnavailable means the server could not be reached or failed to answer
+	ErrServerUnavailable = errors.New("model server unavailable")
+	// ErrModelNotLoaded means the server is up but does not have the model ready
+	ErrModelNotLoaded = errors.New("model not loaded")
+	// ErrContextOverflow means the conversation does not fit in the model's context window
+	ErrContextOverflow = errors.New("conversation exceeds the model's context length")
+	// ErrBadRequest means the server rejected the request
+	ErrBadRequest = errors.New("request rejected by model server")
+	// ErrMalformedResponse means the server answered with something that is not a chat completion
+	ErrMalformedResponse = errors.New("malformed response from model server")
+)
+
+// Error is a failed request to a model server. It matches its Class and
+// the underlying error with errors.Is.
+type Error struct {
+	// Class is one of the Err* error classes
+	Class error
+	// Status is the HTTP status of the response, 0 when there was none
+	Status int
+	// Message describes the failure, usually as reported by the server
+	Message string
+	// Err is the underlying error, if any
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := e.Class.Error()
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Class}
+	}
+	return []error{e.Class, e.Err}
+}
+
+// malformed returns an ErrMalformedResponse error
+func malformed(message string, err error) error {
+	return &Error{Class: ErrMalformedResponse, Message: message, Err: err}
+}
+
+// Phrases used by model servers when a prompt is longer than the context window
+var contextOverflowPhrases = []string{
+	"context length",
+	"context window",
+	"context size",
+	"maximum context",
+	"too many tokens",
+	"prompt is too long",
+}
+
+// Phrases used by model servers when the requested model is missing or still loading
+var modelNotLoadedPhrases = []string{
+	"loading model",
+	"model is loading",
+	"not loaded",
+	"no such model",
+}
+
+// serverError classifies an error reported by a server, either as an HTTP
+// error status or as an error event in a stream (status 0)
+func serverError(status int, message string) *Error {
+	lower := strings.ToLower(message)
+	class := ErrBadRequest
+	switch {
+	case containsAny(lower, contextOverflowPhrases):
+		class = ErrContextOverflow
+	case containsAny(lower, modelNotLoadedPhrases),
+		status == 404 && strings.Contains(lower, "model"):
+		class = ErrModelNotLoaded
+	case status >= 500:
+		class = ErrServerUnavailable
+	}
+	return &Error{Class: class, Status: status, Message: message}
+}
+
+// errorMessage extracts the message from an error response body, which is
+// {"error": {"message": ...}} for OpenAI-compatible servers and
+// {"error": "..."} for Ollama, falling back to the body itself
+func errorMessage(body []byte) string {
+	var resp struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if message := streamErrorMessage(resp.Error); message != "" {
+			return message
+		}
+		if resp.Message != "" {
+			return resp.Message
+		}
+	}
+	message := strings.TrimSpace(string(body))
+	if len(message) > 500 {
+		message = message[:500] + "..."
+	}
+	return message
+}
+
+// streamErrorMessage returns the message of an "error" field, which is either
+// a string or an object with a message
+func streamErrorMessage(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return message
+	}
+	var object struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &object); err == nil && object.Message != "" {
+		return object.Message
+	}
+	return string(raw)
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/constants"
+)
+
+// newTestClient starts a server answering every request with handler and
+// returns a client of the given server type talking to it
+func newTestClient(t *testing.T, server string, handler http.HandlerFunc) ModelServerClient {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	host, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return NewClient(server, "test", host, p)
+}
+
+// respond returns a handler answering with status and body
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestChatErrorClasses(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		stream  bool
+		handler http.HandlerFunc
+		want    error
+	}{
+		{
+			name:    "empty choices",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusOK, `{"id": "x", "choices": []}`),
+			want:    ErrMalformedResponse,
+		},
+		{
+			name:    "invalid JSON",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusOK, `<html>proxy error</html>`),
+			want:    ErrMalformedResponse,
+		},
+		{
+			name:    "empty stream",
+			server:  constants.Mlx_lm,
+			stream:  true,
+			handler: respond(http.StatusOK, ""),
+			want:    ErrMalformedResponse,
+		},
+		{
+			name:    "invalid stream chunk",
+			server:  constants.Mlx_lm,
+			stream:  true,
+			handler: respond(http.StatusOK, "data: {\"choices\": [\n\n"),
+			want:    ErrMalformedResponse,
+		},
+		{
+			name:    "context overflow",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusBadRequest, `{"error": {"message": "This model's maximum context length is 4096 tokens", "type": "invalid_request_error"}}`),
+			want:    ErrContextOverflow,
+		},
+		{
+			name:    "context overflow mid-stream",
+			server:  constants.Mlx_lm,
+			stream:  true,
+			handler: respond(http.StatusOK, "data: {\"error\": {\"message\": \"the request exceeds the available context size\"}}\n\n"),
+			want:    ErrContextOverflow,
+		},
+		{
+			name:    "bad request",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusUnprocessableEntity, `{"error": "temperature must be positive"}`),
+			want:    ErrBadRequest,
+		},
+		{
+			name:    "server error",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusServiceUnavailable, "overloaded"),
+			want:    ErrServerUnavailable,
+		},
+		{
+			name:    "model loading",
+			server:  constants.Mlx_lm,
+			handler: respond(http.StatusServiceUnavailable, `{"error": {"message": "Loading model"}}`),
+			want:    ErrModelNotLoaded,
+		},
+		{
+			name:    "ollama model not found",
+			server:  constants.Ollama,
+			handler: respond(http.StatusNotFound, `{"error": "model \"test\" not found, try pulling it first"}`),
+			want:    ErrModelNotLoaded,
+		},
+		{
+			name:    "ollama empty response",
+			server:  constants.Ollama,
+			handler: respond(http.StatusOK, ""),
+			want:    ErrMalformedResponse,
+		},
+		{
+			name:    "ollama error mid-stream",
+			server:  constants.Ollama,
+			stream:  true,
+			handler: respond(http.StatusOK, "{\"message\": {\"content\": \"Hi\"}}\n{\"error\": \"input length exceeds the context length\"}\n"),
+			want:    ErrContextOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.server, tt.handler)
+			req := NewChatRequest(DefaultChatOptions)
+			req.Stream = tt.stream
+			req.Messages = []Message{{Role: "user", Content: "Hi"}}
+
+			resp, err := c.Chat(context.Background(), req, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if resp != nil {
+				t.Errorf("expected no response, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := serverError(http.StatusBadRequest, errorMessage([]byte(`{"error": {"message": "unknown parameter"}}`)))
+	if got := err.Error(); got != "request rejected by model server (status 400): unknown parameter" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
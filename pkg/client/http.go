@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Timeouts bound how long requests to model servers wait
+type Timeouts struct {
+	// Connect bounds opening a connection to the server
+	Connect time.Duration `yaml:"connect"`
+	// Response bounds waiting for the server to start answering. Servers only
+	// answer non-streamed requests once the whole response is generated.
+	Response time.Duration `yaml:"response"`
+}
+
+var DefaultTimeouts = Timeouts{
+	Connect:  5 * time.Second,
+	Response: 5 * time.Minute,
+}
+
+// A server that was just started refuses connections until its model is
+// loaded, so refused connections are retried with exponential backoff
+var (
+	connectRetries = 5
+	connectBackoff = 500 * time.Millisecond
+)
+
+var (
+	// httpClient is shared by every client so connections are reused
+	httpClient = newHTTPClient(DefaultTimeouts, nil)
+	timeouts   = DefaultTimeouts
+)
+
+// SetTimeouts changes the timeouts of every client. Zero values keep the
+// defaults. It must be called before any client is created.
+func SetTimeouts(t Timeouts) {
+	if t.Connect <= 0 {
+		t.Connect = DefaultTimeouts.Connect
+	}
+	if t.Response <= 0 {
+		t.Response = DefaultTimeouts.Response
+	}
+	timeouts = t
+	httpClient = newHTTPClient(t, nil)
+}
+
+func newHTTPClient(t Timeouts, tlsConfig *tls.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: t.Connect, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		// There is no overall timeout since streamed generations can run for minutes,
+		// they are cancelled through the request context instead
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   t.Connect,
+			ResponseHeaderTimeout: t.Response,
+			MaxIdleConnsPerHost:   8,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// conn is how a client reaches its model server
+type conn struct {
+	baseURL string
+	// header is added to every request
+	header http.Header
+	// client is nil for local servers, which share httpClient
+	client *http.Client
+}
+
+// localConn reaches a model server started by golms on this machine
+func localConn(host string, port int) conn {
+	return conn{baseURL: fmt.Sprintf("http://%s:%d", host, port)}
+}
+
+// postJSON posts payload to the server at path and returns the response when
+// the server answers with 200 OK
+func (c conn) postJSON(ctx context.Context, path string, payload []byte) (*http.Response, error) {
+	return c.do(ctx, "POST", path, payload)
+}
+
+// getJSON gets path from the server and decodes the JSON response into v
+func (c conn) getJSON(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return malformed("invalid JSON", err)
+	}
+	return nil
+}
+
+// do sends a request, retrying while the connection is refused
+func (c conn) do(ctx context.Context, method string, path string, payload []byte) (*http.Response, error) {
+	client := c.client
+	if client == nil {
+		client = httpClient
+	}
+
+	backoff := connectBackoff
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		for name, values := range c.header {
+			httpReq.Header[name] = values
+		}
+		if payload != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := client.Do(httpReq)
+		if err == nil {
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return nil, serverError(resp.StatusCode, errorMessage(body))
+			}
+			return resp, nil
+		}
+
+		var netErr net.Error
+		var opErr *net.OpError
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+			return nil, unavailable(fmt.Sprintf("could not connect within %s", timeouts.Connect), err)
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return nil, unavailable(fmt.Sprintf("server did not respond within %s", timeouts.Response), err)
+		case !errors.Is(err, syscall.ECONNREFUSED) || attempt >= connectRetries:
+			return nil, unavailable("", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// unavailable returns an ErrServerUnavailable error
+func unavailable(message string, err error) error {
+	return &Error{Class: ErrServerUnavailable, Message: message, Err: err}
+}
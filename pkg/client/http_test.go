@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnRetriesRefusedConnections(t *testing.T) {
+	defer func(backoff time.Duration) { connectBackoff = backoff }(connectBackoff)
+	connectBackoff = 50 * time.Millisecond
+
+	// Reserve a port, then only start listening on it after the first attempts are refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	go func() {
+		time.Sleep(120 * time.Millisecond)
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		server.Listener = listener
+		server.Start()
+	}()
+
+	resp, err := conn{baseURL: "http://" + addr}.postJSON(context.Background(), "/", []byte("{}"))
+	if err != nil {
+		t.Fatalf("expected the request to succeed once the server listens: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestConnCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := conn{baseURL: server.URL}.postJSON(ctx, "/", []byte("{}"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestConnResponseTimeout(t *testing.T) {
+	defer SetTimeouts(DefaultTimeouts)
+	SetTimeouts(Timeouts{Response: 50 * time.Millisecond})
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := conn{baseURL: server.URL}.postJSON(context.Background(), "/", []byte("{}"))
+	if !errors.Is(err, ErrServerUnavailable) || !strings.Contains(err.Error(), "did not respond within 50ms") {
+		t.Fatalf("expected a response timeout, got %v", err)
+	}
+}
+
+func TestConnGivesUpOnRefusedConnections(t *testing.T) {
+	defer func(retries int) { connectRetries = retries }(connectRetries)
+	connectRetries = 0
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = conn{baseURL: "http://" + addr}.postJSON(context.Background(), "/", []byte("{}"))
+	if !errors.Is(err, ErrServerUnavailable) {
+		t.Fatalf("expected ErrServerUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"math"
+	"slices"
+	"strconv"
+)
+
+// MaxLogProbs is the largest number of alternatives that can be requested per token
+const MaxLogProbs = 20
+
+// LogProbs holds the log probabilities of a response's tokens. Servers either
+// use the OpenAI format in Content or the legacy completion format, where
+// tokens are IDs and alternatives are maps from token ID to log probability.
+type LogProbs struct {
+	Content []TokenLogProb `json:"content,omitempty"`
+
+	TokenLogProbs []float64            `json:"token_logprobs,omitempty"`
+	TopLogProbs   []map[string]float64 `json:"top_logprobs,omitempty"`
+	Tokens        []int                `json:"tokens,omitempty"`
+}
+
+// TokenLogProb is a generated token with its log probability and the most likely alternatives
+type TokenLogProb struct {
+	Token       string       `json:"token"`
+	LogProb     float64      `json:"logprob"`
+	TopLogProbs []TopLogProb `json:"top_logprobs,omitempty"`
+}
+
+// TopLogProb is an alternative the model considered for a token
+type TopLogProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+}
+
+// Probability converts a log probability to a probability between 0 and 1
+func Probability(logProb float64) float64 {
+	return math.Exp(logProb)
+}
+
+// TokenList returns the tokens in the OpenAI format, converting the legacy format if needed
+func (l *LogProbs) TokenList() []TokenLogProb {
+	if l == nil {
+		return nil
+	}
+	if len(l.Content) > 0 {
+		return l.Content
+	}
+
+	tokens := make([]TokenLogProb, 0, len(l.TokenLogProbs))
+	for i, logProb := range l.TokenLogProbs {
+		token := TokenLogProb{LogProb: logProb}
+		if i < len(l.Tokens) {
+			token.Token = "[" + strconv.Itoa(l.Tokens[i]) + "]"
+		}
+		if i < len(l.TopLogProbs) {
+			for id, alternative := range l.TopLogProbs[i] {
+				token.TopLogProbs = append(token.TopLogProbs, TopLogProb{Token: "[" + id + "]", LogProb: alternative})
+			}
+			// Map order is random, list the most likely alternatives first
+			slices.SortFunc(token.TopLogProbs, func(a, b TopLogProb) int {
+				if a.LogProb == b.LogProb {
+					return 0
+				}
+				if a.LogProb > b.LogProb {
+					return -1
+				}
+				return 1
+			})
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// appendLogProbs adds the log probabilities of a streamed chunk to those received so far
+func appendLogProbs(l *LogProbs, chunk *LogProbs) *LogProbs {
+	if chunk == nil {
+		return l
+	}
+	if l == nil {
+		l = &LogProbs{}
+	}
+	l.Content = append(l.Content, chunk.Content...)
+	l.TokenLogProbs = append(l.TokenLogProbs, chunk.TokenLogProbs...)
+	l.TopLogProbs = append(l.TopLogProbs, chunk.TopLogProbs...)
+	l.Tokens = append(l.Tokens, chunk.Tokens...)
+	return l
+}
+
+// mlxLMChatRequest sends logprobs as the number of alternatives, as mlx_lm.server expects
+type mlxLMChatRequest struct {
+	*ChatRequest
+	LogProbs int `json:"logprobs,omitempty"`
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTokenListConvertsLegacyFormat(t *testing.T) {
+	var logProbs LogProbs
+	data := `{"token_logprobs": [-0.1, -2.3], "tokens": [9906, 1917], "top_logprobs": [{"9906": -0.1}, {"1917": -2.3, "4435": -0.5}]}`
+	if err := json.Unmarshal([]byte(data), &logProbs); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := logProbs.TokenList()
+	if len(tokens) != 2 || tokens[0].Token != "[9906]" || tokens[1].LogProb != -2.3 {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+	if alternatives := tokens[1].TopLogProbs; len(alternatives) != 2 || alternatives[0].Token != "[4435]" {
+		t.Errorf("alternatives not sorted by likelihood: %+v", alternatives)
+	}
+}
+
+func TestTokenListOpenAIFormat(t *testing.T) {
+	var logProbs LogProbs
+	data := `{"content": [{"token": "Hi", "logprob": -0.01, "top_logprobs": [{"token": "Hi", "logprob": -0.01}]}]}`
+	if err := json.Unmarshal([]byte(data), &logProbs); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := logProbs.TokenList(); len(tokens) != 1 || tokens[0].Token != "Hi" {
+		t.Errorf("unexpected tokens: %+v", tokens)
+	}
+
+	var missing *LogProbs
+	if tokens := missing.TokenList(); tokens != nil {
+		t.Errorf("expected no tokens, got %+v", tokens)
+	}
+}
+
+func TestMlxLMRequestSendsLogProbsCount(t *testing.T) {
+	options := DefaultChatOptions
+	options.LogProbs = 5
+	req := NewChatRequest(options)
+
+	data, err := json.Marshal(mlxLMChatRequest{req, req.TopLogProbs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"logprobs":5`) || !strings.Contains(string(data), `"top_logprobs":5`) {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	data, _ = json.Marshal(NewChatRequest(options))
+	if !strings.Contains(string(data), `"logprobs":true`) {
+		t.Errorf("OpenAI request should send logprobs as a boolean: %s", data)
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// messageJSON mirrors Message with content that may be a string or a list of parts
+type messageJSON struct {
+	Role             string          `json:"role"`
+	Content          json.RawMessage `json:"content"`
+	ToolCalls        []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID       string          `json:"tool_call_id,omitempty"`
+	Name             string          `json:"name,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+}
+
+// MarshalJSON encodes messages with images using OpenAI content parts
+// and all other messages with plain string content
+func (m Message) MarshalJSON() ([]byte, error) {
+	var content any = m.Content
+	if len(m.Images) > 0 {
+		parts := []ContentPart{}
+		if m.Content != "" {
+			parts = append(parts, ContentPart{Type: "text", Text: m.Content})
+		}
+		for _, image := range m.Images {
+			parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: image.DataURL()}})
+		}
+		content = parts
+	}
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(messageJSON{
+		Role:             m.Role,
+		Content:          encoded,
+		ToolCalls:        m.ToolCalls,
+		ToolCallID:       m.ToolCallID,
+		Name:             m.Name,
+		ReasoningContent: m.ReasoningContent,
+	})
+}
+
+// UnmarshalJSON accepts both string content and OpenAI content parts
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw messageJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message{
+		Role:             raw.Role,
+		ToolCalls:        raw.ToolCalls,
+		ToolCallID:       raw.ToolCallID,
+		Name:             raw.Name,
+		ReasoningContent: raw.ReasoningContent,
+	}
+
+	if len(raw.Content) == 0 || string(raw.Content) == "null" {
+		return nil
+	}
+	if raw.Content[0] == '"' {
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+
+	var parts []ContentPart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return fmt.Errorf("invalid message content: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL == nil {
+				continue
+			}
+			image, err := parseDataURL(part.ImageURL.URL)
+			if err != nil {
+				return err
+			}
+			m.Images = append(m.Images, image)
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+	return nil
+}
+
+// parseDataURL decodes a data:<mime>;base64,<data> URL
+func parseDataURL(url string) (Image, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mimeType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !ok || !isBase64 || !strings.HasPrefix(url, "data:") {
+		return Image{}, fmt.Errorf("unsupported image URL: only base64 data URLs are supported")
+	}
+	return Image{MimeType: mimeType, Data: data}, nil
+}
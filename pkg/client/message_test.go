@@ -0,0 +1,50 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageJSONPlainContent(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"content":"hello"`) {
+		t.Errorf("expected string content, got %s", data)
+	}
+}
+
+func TestMessageJSONImagesRoundTrip(t *testing.T) {
+	message := Message{
+		Role:    "user",
+		Content: "what is this?",
+		Images:  []Image{{MimeType: "image/png", Data: "aGVsbG8="}},
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"url":"data:image/png;base64,aGVsbG8="`) {
+		t.Errorf("expected image_url content part, got %s", data)
+	}
+
+	var decoded Message
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, message) {
+		t.Errorf("round trip changed message: got %+v, want %+v", decoded, message)
+	}
+}
+
+func TestMessageJSONRejectsRemoteImageURL(t *testing.T) {
+	data := `{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}`
+	var decoded Message
+	if err := json.Unmarshal([]byte(data), &decoded); err == nil {
+		t.Error("expected error for non-data image URL")
+	}
+}
@@ -2,192 +2,188 @@ package client
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"strconv"
+	"slices"
 	"strings"
-
-	"github.com/changminbark/golms/pkg/ui"
-	"github.com/changminbark/golms/pkg/utils"
 )
 
+// MlxLMClient talks to the OpenAI-compatible API of mlx_lm.server and
+// mlx_vlm.server, and of remote OpenAI-compatible servers
 type MlxLMClient struct {
-	llm         string
-	host        string
-	port        int
-	chatOptions ChatOptions
-	reader      *bufio.Reader
+	llm          string
+	conn         conn
+	capabilities Capabilities
+	// remote is set for OpenAI-compatible servers other than the local mlx
+	// servers, which serve several models and expect standard requests
+	remote bool
 }
 
-func (c MlxLMClient) StartChat() error {
-	// Set Chat Options
-	c.setChatOptions()
-
-	// Display chat header with styled box
-	header := fmt.Sprintf("Chat Session: %s", c.llm)
-	fmt.Println(ui.FormatInfoBox(header))
-	fmt.Println(ui.SubtleStyle.Render("Type '/exit' to quit the chat"))
-	fmt.Println(ui.FormatDivider())
-	fmt.Println()
-
-	// Create initial chat request
-	chatReq := &ChatRequest{
-		Messages:    []Message{},
-		Temperature: c.chatOptions.Temperature,
-		MaxTokens:   c.chatOptions.MaxTokens,
-		Stream:      c.chatOptions.Stream,
-	}
-
-	// Create infinite loop for chat
-	for {
-		// Prompt user for message and add to conversation thread
-		err := c.addUserMessage(chatReq)
-		if err != nil {
-			if errors.Is(err, ErrExitRequested) {
-				fmt.Println(ui.SubtleStyle.Render("\nExiting chat. Goodbye!"))
-				return nil
-			}
-			return err
-		}
-
-		// Send chat request to model server
-		resp, err := c.sendChatReq(chatReq)
-		if err != nil {
-			fmt.Println(ui.FormatError(fmt.Sprintf("Failed to get response: %v", err)))
-			return err
-		}
-
-		// Clean and display chat response
-		cleanedContent := utils.RemoveThinkTags(resp.Choices[0].Message.Content)
-		fmt.Println(ui.FormatAIMessage(c.llm, cleanedContent))
-	}
+func (c *MlxLMClient) LLM() string {
+	return c.llm
 }
 
-func (c *MlxLMClient) setChatOptions() {
-	fmt.Println(ui.HeaderStyle.Render("Chat Options Setup"))
-	fmt.Println(ui.SubtleStyle.Render("Configure parameters for the model"))
-	fmt.Println()
-
-	// Set Temperature
-	fmt.Print(ui.PromptStyle.Render("Temperature") + " (0.0-2.0, default 0.7): ")
-	tempInput, _ := c.reader.ReadString('\n')
-	tempInput = strings.TrimSpace(tempInput)
+func (c *MlxLMClient) Capabilities() Capabilities {
+	return c.capabilities
+}
 
-	if tempInput == "" {
-		c.chatOptions.Temperature = 0.7
-	} else {
-		temp, err := strconv.ParseFloat(tempInput, 64)
-		if err != nil || temp < 0 || temp > 2.0 {
-			fmt.Println(ui.FormatWarning("Invalid temperature, using default 0.7"))
-			c.chatOptions.Temperature = 0.7
-		} else {
-			c.chatOptions.Temperature = temp
-		}
+func (c *MlxLMClient) Chat(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	// Create data payload of chat request. The local mlx servers serve a
+	// single model and would try to load any other model named in the request.
+	var body any = mlxLMChatRequest{req, req.TopLogProbs}
+	if c.remote {
+		body = openAIChatRequest{c.llm, req}
 	}
-
-	// Set MaxTokens
-	fmt.Print(ui.PromptStyle.Render("Max Tokens") + " (default 512): ")
-	tokensInput, _ := c.reader.ReadString('\n')
-	tokensInput = strings.TrimSpace(tokensInput)
-
-	if tokensInput == "" {
-		c.chatOptions.MaxTokens = 512
-	} else {
-		tokens, err := strconv.Atoi(tokensInput)
-		if err != nil || tokens < 1 {
-			fmt.Println(ui.FormatWarning("Invalid max tokens, using default 512"))
-			c.chatOptions.MaxTokens = 512
-		} else {
-			c.chatOptions.MaxTokens = tokens
-		}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set Stream
-	fmt.Print(ui.PromptStyle.Render("Enable Streaming?") + " (y/n, default n): ")
-	streamInput, _ := c.reader.ReadString('\n')
-	streamInput = strings.TrimSpace(strings.ToLower(streamInput))
-
-	c.chatOptions.Stream = (streamInput == "y" || streamInput == "yes")
-
-	// Display configured options in a box
-	fmt.Println()
-	optionsInfo := fmt.Sprintf("Temperature: %.2f\nMax Tokens: %d\nStreaming: %v",
-		c.chatOptions.Temperature, c.chatOptions.MaxTokens, c.chatOptions.Stream)
-	fmt.Println(ui.FormatInfoBox(optionsInfo))
-	fmt.Println()
-	fmt.Println(ui.FormatDivider())
-}
-
-func (c *MlxLMClient) addUserMessage(req *ChatRequest) error {
-	// Ask user for input with styled prompt
-	fmt.Print(ui.UserStyle.Render("You: "))
-	userInput, err := c.reader.ReadString('\n')
+	// Send the request, the context cancels it including any stream being read
+	resp, err := c.conn.postJSON(ctx, "/v1/chat/completions", payload)
 	if err != nil {
-		return fmt.Errorf("failed to read user input: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Trim whitespace and check for exit command
-	userInput = strings.TrimSpace(userInput)
-	if userInput == "/exit" {
-		return ErrExitRequested
+	if req.Stream {
+		return readChatStream(resp.Body, onDelta)
 	}
 
-	// Create user message
-	userMessage := &Message{
-		Role:      "user",
-		Content:   userInput,
-		ToolCalls: nil,
+	// Decode the response
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, malformed("invalid JSON", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, malformed("response has no choices", nil)
 	}
 
-	// Append new user input into request messages
-	req.Messages = append(req.Messages, *userMessage)
-
-	return nil
+	return &chatResp, nil
 }
 
-func (c *MlxLMClient) sendChatReq(req *ChatRequest) (*ChatResponse, error) {
-	// Create data payload of chat request
-	payload, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
+// readChatStream reads an OpenAI-style server-sent event stream and
+// assembles the chunks into a single ChatResponse
+func readChatStream(body io.Reader, onDelta DeltaFunc) (*ChatResponse, error) {
+	chatResp := &ChatResponse{Object: "chat.completion"}
+	var content, reasoning strings.Builder
+	var toolCalls []ToolCall
+	var logProbs *LogProbs
+	finishReason := ""
+
+	err := readEvents(body, func(data []byte) error {
+		var chunk ChatChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return malformed("invalid stream chunk", err)
+		}
+		if message := streamErrorMessage(chunk.Error); message != "" {
+			return serverError(0, message)
+		}
 
-	// Create HTTP Post request
-	url := fmt.Sprintf("http://%s:%d/v1/chat/completions", c.host, c.port)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+		chatResp.ID = chunk.ID
+		chatResp.Model = chunk.Model
+		chatResp.Created = chunk.Created
+		if chunk.Usage != nil {
+			chatResp.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			toolCalls = mergeToolCalls(toolCalls, choice.Delta.ToolCalls)
+			logProbs = appendLogProbs(logProbs, choice.LogProbs)
+			delta := Delta{Content: choice.Delta.Content, Reasoning: choice.Delta.ReasoningContent}
+			if delta.Content == "" && delta.Reasoning == "" {
+				continue
+			}
+			content.WriteString(delta.Content)
+			reasoning.WriteString(delta.Reasoning)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
+	chatResp.Choices = []Choice{{
+		FinishReason: finishReason,
+		LogProbs:     logProbs,
+		Message: Message{
+			Role:             "assistant",
+			Content:          content.String(),
+			ReasoningContent: reasoning.String(),
+			ToolCalls:        toolCalls,
+		},
+	}}
+	return chatResp, nil
+}
 
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+// readEvents calls fn with the data of every event of an OpenAI-style
+// server-sent event stream, until the stream ends with [DONE]
+func readEvents(body io.Reader, fn func(data []byte) error) error {
+	received := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Events look like "data: {...}" and the stream ends with "data: [DONE]"
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		if err := fn([]byte(data)); err != nil {
+			return err
+		}
+		received = true
 	}
-	defer resp.Body.Close()
-
-	// Check for non-200 status codes
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
 	}
-
-	// Decode the response
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if !received {
+		return malformed("stream ended without any data", nil)
 	}
+	return nil
+}
 
-	// Add top response to original chat request for conversation context
-	req.Messages = append(req.Messages, chatResp.Choices[0].Message)
-
-	return &chatResp, nil
+// mergeToolCalls adds streamed tool call fragments to the calls received so far.
+// Fragments continue the latest call with their index, unless they carry the
+// ID of another call, since some servers send several complete calls without
+// an index. Calls are kept in index order as fragments may arrive out of order.
+func mergeToolCalls(calls []ToolCall, fragments []ToolCall) []ToolCall {
+	for _, fragment := range fragments {
+		i := len(calls) - 1
+		for i >= 0 && calls[i].Index != fragment.Index {
+			i--
+		}
+		if i < 0 || (fragment.ID != "" && calls[i].ID != "" && fragment.ID != calls[i].ID) {
+			at := len(calls)
+			for at > 0 && calls[at-1].Index > fragment.Index {
+				at--
+			}
+			calls = slices.Insert(calls, at, fragment)
+			continue
+		}
+		call := &calls[i]
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		if fragment.Type != "" {
+			call.Type = fragment.Type
+		}
+		call.Function.Name += fragment.Function.Name
+		call.Function.Arguments += fragment.Function.Arguments
+	}
+	return calls
 }
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeToolCalls(t *testing.T) {
+	call := func(index int, id, name, arguments string) ToolCall {
+		return ToolCall{Index: index, ID: id, Function: FunctionCall{Name: name, Arguments: arguments}}
+	}
+	tests := []struct {
+		name   string
+		deltas [][]ToolCall
+		want   []string // name and arguments of every call
+	}{
+		{
+			name: "one call in fragments",
+			deltas: [][]ToolCall{
+				{call(0, "a", "read_file", "")},
+				{call(0, "", "", `{"path":`)},
+				{call(0, "", "", `"go.mod"}`)},
+			},
+			want: []string{`read_file {"path":"go.mod"}`},
+		},
+		{
+			name: "interleaved calls",
+			deltas: [][]ToolCall{
+				{call(0, "a", "read_file", `{"path":`)},
+				{call(1, "b", "list_dir", `{"path":`)},
+				{call(0, "", "", `"go.mod"}`)},
+				{call(1, "", "", `"pkg"}`)},
+			},
+			want: []string{`read_file {"path":"go.mod"}`, `list_dir {"path":"pkg"}`},
+		},
+		{
+			name: "out of order",
+			deltas: [][]ToolCall{
+				{call(1, "b", "list_dir", `{"path":`)},
+				{call(0, "a", "read_file", `{"path":`)},
+				{call(1, "", "", `"pkg"}`)},
+				{call(0, "", "", `"go.mod"}`)},
+			},
+			want: []string{`read_file {"path":"go.mod"}`, `list_dir {"path":"pkg"}`},
+		},
+		{
+			name: "several calls in one delta without an index",
+			deltas: [][]ToolCall{
+				{call(0, "a", "read_file", `{"path":"go.mod"}`), call(0, "b", "list_dir", `{"path":"pkg"}`)},
+			},
+			want: []string{`read_file {"path":"go.mod"}`, `list_dir {"path":"pkg"}`},
+		},
+		{
+			name: "repeated ID",
+			deltas: [][]ToolCall{
+				{call(0, "a", "read_file", `{"path":`)},
+				{call(0, "a", "", `"go.mod"}`)},
+			},
+			want: []string{`read_file {"path":"go.mod"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []ToolCall
+			for _, delta := range tt.deltas {
+				calls = mergeToolCalls(calls, delta)
+			}
+			var got []string
+			for _, c := range calls {
+				got = append(got, c.Function.Name+" "+c.Function.Arguments)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1 +1,237 @@
 package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OllamaClient talks to Ollama's native /api/chat endpoint, which accepts
+// images and sampling options in Ollama's own format
+type OllamaClient struct {
+	llm  string
+	conn conn
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	// Format is "json" or a JSON schema the response must match
+	Format      json.RawMessage `json:"format,omitempty"`
+	LogProbs    bool            `json:"logprobs,omitempty"`
+	TopLogProbs int             `json:"top_logprobs,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// ollamaToolCall differs from ToolCall in having its arguments as a JSON object
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatResponse struct {
+	Model           string         `json:"model"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Message         ollamaMessage  `json:"message"`
+	Done            bool           `json:"done"`
+	DoneReason      string         `json:"done_reason"`
+	PromptEvalCount int            `json:"prompt_eval_count"`
+	EvalCount       int            `json:"eval_count"`
+	LogProbs        []TokenLogProb `json:"logprobs"`
+	Error           string         `json:"error"`
+}
+
+func (c *OllamaClient) LLM() string {
+	return c.llm
+}
+
+func (c *OllamaClient) Capabilities() Capabilities {
+	return Capabilities{Images: true}
+}
+
+func (c *OllamaClient) Chat(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	options, err := ollamaOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to Ollama's request format
+	ollamaReq := ollamaChatRequest{
+		Model:    c.llm,
+		Messages: make([]ollamaMessage, 0, len(req.Messages)),
+		Stream:   req.Stream,
+		Options:  options,
+		Tools:    req.Tools,
+
+		LogProbs:    req.LogProbs,
+		TopLogProbs: req.TopLogProbs,
+	}
+	if format := req.ResponseFormat; format != nil {
+		if format.JSONSchema != nil {
+			ollamaReq.Format = format.JSONSchema.Schema
+		} else {
+			ollamaReq.Format = json.RawMessage(`"json"`)
+		}
+	}
+	for _, message := range req.Messages {
+		ollamaReq.Messages = append(ollamaReq.Messages, toOllamaMessage(message))
+	}
+
+	// Create data payload of chat request
+	payload, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request, the context cancels it including any stream being read
+	resp, err := c.conn.postJSON(ctx, "/api/chat", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Responses are newline-delimited JSON objects, a single one when not streaming
+	var content, thinking strings.Builder
+	var toolCalls []ToolCall
+	var logProbs *LogProbs
+	var last ollamaChatResponse
+	received := false
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, malformed("invalid JSON", err)
+		}
+		if chunk.Error != "" {
+			return nil, serverError(0, chunk.Error)
+		}
+		received = true
+
+		content.WriteString(chunk.Message.Content)
+		thinking.WriteString(chunk.Message.Thinking)
+		if len(chunk.LogProbs) > 0 {
+			logProbs = appendLogProbs(logProbs, &LogProbs{Content: chunk.LogProbs})
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			toolCalls = append(toolCalls, ToolCall{
+				Index: len(toolCalls),
+				ID:    fmt.Sprintf("call_%d", len(toolCalls)),
+				Type:  "function",
+				Function: FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: string(call.Function.Arguments),
+				},
+			})
+		}
+		if onDelta != nil && (chunk.Message.Content != "" || chunk.Message.Thinking != "") {
+			onDelta(Delta{Content: chunk.Message.Content, Reasoning: chunk.Message.Thinking})
+		}
+
+		last = chunk
+		if chunk.Done {
+			break
+		}
+	}
+	if !received {
+		return nil, malformed("empty response", nil)
+	}
+
+	return &ChatResponse{
+		Object:  "chat.completion",
+		Model:   last.Model,
+		Created: last.CreatedAt.Unix(),
+		Choices: []Choice{{
+			FinishReason: last.DoneReason,
+			LogProbs:     logProbs,
+			Message: Message{
+				Role:             "assistant",
+				Content:          content.String(),
+				ReasoningContent: thinking.String(),
+				ToolCalls:        toolCalls,
+			},
+		}},
+		Usage: Usage{
+			PromptTokens:     last.PromptEvalCount,
+			CompletionTokens: last.EvalCount,
+			TotalTokens:      last.PromptEvalCount + last.EvalCount,
+		},
+	}, nil
+}
+
+// ollamaOptions maps the sampling parameters of a request to Ollama's option names
+func ollamaOptions(req *ChatRequest) (map[string]any, error) {
+	s := req.Sampling
+	if len(s.LogitBias) > 0 {
+		return nil, errors.New("logit_bias is not supported by ollama")
+	}
+
+	options := map[string]any{
+		"temperature": req.Temperature,
+		"num_predict": req.MaxTokens,
+	}
+	set := func(name string, value any, ok bool) {
+		if ok {
+			options[name] = value
+		}
+	}
+	set("top_p", deref(s.TopP), s.TopP != nil)
+	set("top_k", deref(s.TopK), s.TopK != nil)
+	set("min_p", deref(s.MinP), s.MinP != nil)
+	set("repeat_penalty", deref(s.RepetitionPenalty), s.RepetitionPenalty != nil)
+	set("presence_penalty", deref(s.PresencePenalty), s.PresencePenalty != nil)
+	set("frequency_penalty", deref(s.FrequencyPenalty), s.FrequencyPenalty != nil)
+	set("seed", deref(s.Seed), s.Seed != nil)
+	set("stop", s.Stop, len(s.Stop) > 0)
+	return options, nil
+}
+
+func deref[T any](value *T) T {
+	var zero T
+	if value == nil {
+		return zero
+	}
+	return *value
+}
+
+// toOllamaMessage converts a message to Ollama's format, where images are
+// raw base64 strings in a separate field and tool arguments are JSON objects
+func toOllamaMessage(message Message) ollamaMessage {
+	converted := ollamaMessage{
+		Role:     message.Role,
+		Content:  message.Content,
+		ToolName: message.Name,
+	}
+	for _, image := range message.Images {
+		converted.Images = append(converted.Images, image.Data)
+	}
+	for _, call := range message.ToolCalls {
+		var ollamaCall ollamaToolCall
+		ollamaCall.Function.Name = call.Function.Name
+		ollamaCall.Function.Arguments = json.RawMessage(call.Function.Arguments)
+		if !json.Valid(ollamaCall.Function.Arguments) {
+			ollamaCall.Function.Arguments = json.RawMessage("{}")
+		}
+		converted.ToolCalls = append(converted.ToolCalls, ollamaCall)
+	}
+	return converted
+}
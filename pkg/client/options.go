@@ -0,0 +1,372 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sampling holds the optional sampling parameters of a request.
+// Nil fields are left out so the model server's defaults apply.
+type Sampling struct {
+	TopP              *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	TopK              *int     `json:"top_k,omitempty" yaml:"top_k,omitempty"`
+	MinP              *float64 `json:"min_p,omitempty" yaml:"min_p,omitempty"`
+	RepetitionPenalty *float64 `json:"repetition_penalty,omitempty" yaml:"repetition_penalty,omitempty"`
+	PresencePenalty   *float64 `json:"presence_penalty,omitempty" yaml:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64 `json:"frequency_penalty,omitempty" yaml:"frequency_penalty,omitempty"`
+	Seed              *int     `json:"seed,omitempty" yaml:"seed,omitempty"`
+	Stop              []string `json:"stop,omitempty" yaml:"stop,omitempty"`
+	// LogitBias maps token IDs to a bias between -100 and 100
+	LogitBias map[string]float64 `json:"logit_bias,omitempty" yaml:"logit_bias,omitempty"`
+}
+
+// Overrides are chat options read from the config file, a persona or a batch
+// request. Only the options that are set replace the current ones.
+type Overrides struct {
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	// Stream is only read from the config file and personas, since it
+	// doesn't change what is generated
+	Stream   *bool `json:"-" yaml:"stream,omitempty"`
+	Sampling `yaml:",inline"`
+}
+
+// Empty reports whether no option is set
+func (o Overrides) Empty() bool {
+	return reflect.ValueOf(o).IsZero()
+}
+
+// Apply replaces the options in o that are set in overrides
+func (o *ChatOptions) Apply(overrides Overrides) {
+	if overrides.Temperature != nil {
+		o.Temperature = *overrides.Temperature
+	}
+	if overrides.MaxTokens != nil {
+		o.MaxTokens = *overrides.MaxTokens
+	}
+	if overrides.Stream != nil {
+		o.Stream = *overrides.Stream
+	}
+
+	s := overrides.Sampling
+	o.Sampling.TopP = override(s.TopP, o.Sampling.TopP)
+	o.Sampling.TopK = override(s.TopK, o.Sampling.TopK)
+	o.Sampling.MinP = override(s.MinP, o.Sampling.MinP)
+	o.Sampling.RepetitionPenalty = override(s.RepetitionPenalty, o.Sampling.RepetitionPenalty)
+	o.Sampling.PresencePenalty = override(s.PresencePenalty, o.Sampling.PresencePenalty)
+	o.Sampling.FrequencyPenalty = override(s.FrequencyPenalty, o.Sampling.FrequencyPenalty)
+	o.Sampling.Seed = override(s.Seed, o.Sampling.Seed)
+	if s.Stop != nil {
+		o.Sampling.Stop = slices.Clone(s.Stop)
+	}
+	if s.LogitBias != nil {
+		o.Sampling.LogitBias = maps.Clone(s.LogitBias)
+	}
+}
+
+// Overrides returns the options as overrides that reproduce them, for recording
+// the options a conversation was generated with
+func (o ChatOptions) Overrides() Overrides {
+	return Overrides{Temperature: &o.Temperature, MaxTokens: &o.MaxTokens, Sampling: o.Sampling}
+}
+
+// override returns value unless it is nil
+func override[T any](value *T, current *T) *T {
+	if value != nil {
+		return value
+	}
+	return current
+}
+
+// Validate checks that every option is within the range model servers accept
+func (o ChatOptions) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(o.Temperature >= 0 && o.Temperature <= 2, "temperature must be between 0 and 2")
+	check(o.MaxTokens >= 1, "max_tokens must be at least 1")
+	check(o.LogProbs >= 0 && o.LogProbs <= MaxLogProbs, "logprobs must be between 0 and %d", MaxLogProbs)
+
+	s := o.Sampling
+	if s.TopP != nil {
+		check(*s.TopP > 0 && *s.TopP <= 1, "top_p must be greater than 0 and at most 1")
+	}
+	if s.TopK != nil {
+		check(*s.TopK >= 0, "top_k must not be negative")
+	}
+	if s.MinP != nil {
+		check(*s.MinP >= 0 && *s.MinP <= 1, "min_p must be between 0 and 1")
+	}
+	if s.RepetitionPenalty != nil {
+		check(*s.RepetitionPenalty > 0, "repetition_penalty must be greater than 0")
+	}
+	if s.PresencePenalty != nil {
+		check(*s.PresencePenalty >= -2 && *s.PresencePenalty <= 2, "presence_penalty must be between -2 and 2")
+	}
+	if s.FrequencyPenalty != nil {
+		check(*s.FrequencyPenalty >= -2 && *s.FrequencyPenalty <= 2, "frequency_penalty must be between -2 and 2")
+	}
+	for _, stop := range s.Stop {
+		check(stop != "", "stop sequences must not be empty")
+	}
+	for token, bias := range s.LogitBias {
+		_, err := strconv.Atoi(token)
+		check(err == nil, "logit_bias keys must be token IDs, got %q", token)
+		check(bias >= -100 && bias <= 100, "logit_bias for token %s must be between -100 and 100", token)
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// option is a chat option that can be read and set by name, e.g. with /set
+type option struct {
+	name string
+	get  func(o *ChatOptions) string
+	set  func(o *ChatOptions, value string) error
+}
+
+// OptionNames returns the names accepted by SetOption
+func OptionNames() []string {
+	names := make([]string, 0, len(options))
+	for _, opt := range options {
+		names = append(names, opt.name)
+	}
+	return names
+}
+
+// SetOption parses and sets a chat option by name. Optional sampling
+// parameters are reset to the server default with the value "default".
+// The options are left unchanged if the result is invalid.
+func SetOption(o *ChatOptions, name string, value string) error {
+	i := slices.IndexFunc(options, func(opt option) bool { return opt.name == name })
+	if i < 0 {
+		return fmt.Errorf("unknown option %q (options: %s)", name, strings.Join(OptionNames(), ", "))
+	}
+
+	updated := *o
+	if err := options[i].set(&updated, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	*o = updated
+	return nil
+}
+
+// Describe lists the options that are set as "name: value" lines
+func (o ChatOptions) Describe() []string {
+	var lines []string
+	for _, opt := range options {
+		if value := opt.get(&o); value != "" {
+			lines = append(lines, opt.name+": "+value)
+		}
+	}
+	return lines
+}
+
+// Describe lists the sampling parameters that are set as "name: value" lines
+func (s Sampling) Describe() []string {
+	o := ChatOptions{Sampling: s}
+	var lines []string
+	for _, opt := range options {
+		if slices.Contains(baseOptions, opt.name) {
+			continue
+		}
+		if value := opt.get(&o); value != "" {
+			lines = append(lines, opt.name+": "+value)
+		}
+	}
+	return lines
+}
+
+// baseOptions are the options that are always set, as opposed to sampling parameters
+var baseOptions = []string{"temperature", "max_tokens", "stream"}
+
+var options = []option{
+	{"temperature",
+		func(o *ChatOptions) string { return formatFloat(&o.Temperature) },
+		func(o *ChatOptions, value string) error { return parseFloat(value, &o.Temperature) }},
+	{"max_tokens",
+		func(o *ChatOptions) string { return strconv.Itoa(o.MaxTokens) },
+		func(o *ChatOptions, value string) error { return parseInt(value, &o.MaxTokens) }},
+	{"stream",
+		func(o *ChatOptions) string { return strconv.FormatBool(o.Stream) },
+		func(o *ChatOptions, value string) (err error) {
+			o.Stream, err = strconv.ParseBool(value)
+			return err
+		}},
+	{"logprobs",
+		func(o *ChatOptions) string {
+			if o.LogProbs == 0 {
+				return ""
+			}
+			return strconv.Itoa(o.LogProbs)
+		},
+		func(o *ChatOptions, value string) error { return parseInt(value, &o.LogProbs) }},
+	{"top_p",
+		func(o *ChatOptions) string { return formatFloat(o.Sampling.TopP) },
+		func(o *ChatOptions, value string) error { return parseOptional(value, &o.Sampling.TopP, parseFloat) }},
+	{"top_k",
+		func(o *ChatOptions) string { return formatInt(o.Sampling.TopK) },
+		func(o *ChatOptions, value string) error { return parseOptional(value, &o.Sampling.TopK, parseInt) }},
+	{"min_p",
+		func(o *ChatOptions) string { return formatFloat(o.Sampling.MinP) },
+		func(o *ChatOptions, value string) error { return parseOptional(value, &o.Sampling.MinP, parseFloat) }},
+	{"repetition_penalty",
+		func(o *ChatOptions) string { return formatFloat(o.Sampling.RepetitionPenalty) },
+		func(o *ChatOptions, value string) error {
+			return parseOptional(value, &o.Sampling.RepetitionPenalty, parseFloat)
+		}},
+	{"presence_penalty",
+		func(o *ChatOptions) string { return formatFloat(o.Sampling.PresencePenalty) },
+		func(o *ChatOptions, value string) error {
+			return parseOptional(value, &o.Sampling.PresencePenalty, parseFloat)
+		}},
+	{"frequency_penalty",
+		func(o *ChatOptions) string { return formatFloat(o.Sampling.FrequencyPenalty) },
+		func(o *ChatOptions, value string) error {
+			return parseOptional(value, &o.Sampling.FrequencyPenalty, parseFloat)
+		}},
+	{"seed",
+		func(o *ChatOptions) string { return formatInt(o.Sampling.Seed) },
+		func(o *ChatOptions, value string) error { return parseOptional(value, &o.Sampling.Seed, parseInt) }},
+	{"stop",
+		func(o *ChatOptions) string {
+			quoted := make([]string, 0, len(o.Sampling.Stop))
+			for _, stop := range o.Sampling.Stop {
+				quoted = append(quoted, strconv.Quote(stop))
+			}
+			return strings.Join(quoted, " ")
+		},
+		func(o *ChatOptions, value string) error {
+			if value == "default" {
+				o.Sampling.Stop = nil
+				return nil
+			}
+			stops, err := SplitQuoted(value)
+			o.Sampling.Stop = stops
+			return err
+		}},
+	{"logit_bias",
+		func(o *ChatOptions) string {
+			pairs := make([]string, 0, len(o.Sampling.LogitBias))
+			for _, token := range slices.Sorted(maps.Keys(o.Sampling.LogitBias)) {
+				pairs = append(pairs, fmt.Sprintf("%s=%g", token, o.Sampling.LogitBias[token]))
+			}
+			return strings.Join(pairs, ",")
+		},
+		func(o *ChatOptions, value string) error {
+			if value == "default" {
+				o.Sampling.LogitBias = nil
+				return nil
+			}
+			bias, err := ParseLogitBias(strings.Split(value, ","))
+			o.Sampling.LogitBias = bias
+			return err
+		}},
+}
+
+// ParseLogitBias parses token=bias pairs such as 50256=-100
+func ParseLogitBias(pairs []string) (map[string]float64, error) {
+	bias := make(map[string]float64, len(pairs))
+	for _, pair := range pairs {
+		token, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected token=bias, got %q", pair)
+		}
+		var b float64
+		if err := parseFloat(value, &b); err != nil {
+			return nil, err
+		}
+		bias[strings.TrimSpace(token)] = b
+	}
+	return bias, nil
+}
+
+// SplitQuoted splits s on whitespace, keeping double-quoted strings together and
+// decoding their escape sequences, so stop sequences like "\n\n" can be typed
+func SplitQuoted(s string) ([]string, error) {
+	var fields []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '"' {
+			field, rest, _ := strings.Cut(s, " ")
+			fields = append(fields, field)
+			s = rest
+			continue
+		}
+		// Find the closing quote, skipping escaped characters
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return nil, fmt.Errorf("unterminated quote in %s", s)
+		}
+		field, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", s[:end+1])
+		}
+		fields = append(fields, field)
+		s = s[end+1:]
+	}
+	return fields, nil
+}
+
+func parseOptional[T any](value string, target **T, parse func(string, *T) error) error {
+	if value == "default" {
+		*target = nil
+		return nil
+	}
+	var v T
+	if err := parse(value, &v); err != nil {
+		return err
+	}
+	*target = &v
+	return nil
+}
+
+func parseFloat(value string, target *float64) (err error) {
+	*target, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	return nil
+}
+
+func parseInt(value string, target *int) (err error) {
+	*target, err = strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	return nil
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'g', -1, 64)
+}
+
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetOption(t *testing.T) {
+	options := DefaultChatOptions
+	for name, value := range map[string]string{
+		"temperature": "0.2",
+		"top_p":       "0.9",
+		"top_k":       "40",
+		"seed":        "7",
+		"stop":        `"\n\n" ###`,
+		"logit_bias":  "50256=-100,13=5",
+	} {
+		if err := SetOption(&options, name, value); err != nil {
+			t.Fatalf("SetOption(%s, %q): %v", name, value, err)
+		}
+	}
+
+	want := []string{
+		"temperature: 0.2",
+		"max_tokens: 512",
+		"stream: false",
+		"top_p: 0.9",
+		"top_k: 40",
+		"seed: 7",
+		`stop: "\n\n" "###"`,
+		"logit_bias: 13=5,50256=-100",
+	}
+	if got := options.Describe(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+
+	if err := SetOption(&options, "top_p", "default"); err != nil || options.Sampling.TopP != nil {
+		t.Errorf("top_p not reset: %v, %v", options.Sampling.TopP, err)
+	}
+}
+
+func TestSetOptionRejectsInvalidValues(t *testing.T) {
+	tests := map[string]string{
+		"temperature": "3",
+		"top_p":       "0",
+		"min_p":       "1.5",
+		"top_k":       "many",
+		"logit_bias":  "token=1",
+		"nucleus":     "0.9",
+	}
+	for name, value := range tests {
+		options := DefaultChatOptions
+		if err := SetOption(&options, name, value); err == nil {
+			t.Errorf("SetOption(%s, %q) succeeded", name, value)
+		}
+		if !reflect.DeepEqual(options, DefaultChatOptions) {
+			t.Errorf("SetOption(%s, %q) changed the options despite failing", name, value)
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	topP, seed := 0.5, 3
+	options := DefaultChatOptions
+	options.Sampling.Seed = &seed
+	options.Apply(Overrides{Sampling: Sampling{TopP: &topP, Stop: []string{"END"}}})
+
+	if *options.Sampling.TopP != 0.5 || *options.Sampling.Seed != 3 || options.Sampling.Stop[0] != "END" {
+		t.Errorf("unexpected options after Apply: %+v", options.Sampling)
+	}
+	if options.Temperature != DefaultChatOptions.Temperature {
+		t.Errorf("unset temperature was overridden")
+	}
+
+	stream := true
+	options.Apply(Overrides{Stream: &stream})
+	if !options.Stream {
+		t.Error("expected stream to be set from the overrides")
+	}
+	if !(Overrides{}).Empty() || (Overrides{Stream: &stream}).Empty() || (Overrides{Sampling: Sampling{Stop: []string{}}}).Empty() {
+		t.Error("Empty() should only hold when no option is set")
+	}
+}
+
+func TestSamplingRequestFields(t *testing.T) {
+	options := DefaultChatOptions
+	SetOption(&options, "repetition_penalty", "1.1")
+	SetOption(&options, "stop", "###")
+
+	data, err := json.Marshal(NewChatRequest(options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"repetition_penalty":1.1`, `"stop":["###"]`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("request %s does not contain %s", data, field)
+		}
+	}
+	if strings.Contains(string(data), "top_p") {
+		t.Errorf("unset top_p was sent: %s", data)
+	}
+
+	ollama, err := ollamaOptions(NewChatRequest(options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ollama["repeat_penalty"] != 1.1 || !reflect.DeepEqual(ollama["stop"], []string{"###"}) {
+		t.Errorf("unexpected ollama options: %v", ollama)
+	}
+
+	SetOption(&options, "logit_bias", "1=1")
+	if _, err := ollamaOptions(NewChatRequest(options)); err == nil {
+		t.Error("expected ollama to reject logit_bias")
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// APIs spoken by remote model servers
+const (
+	// OpenAIAPI is the chat completions API of mlx_lm, vLLM, llama.cpp and others
+	OpenAIAPI = "openai"
+	// OllamaAPI is Ollama's native API
+	OllamaAPI = "ollama"
+)
+
+// Endpoint is a model server running elsewhere, such as a shared workstation.
+// golms only sends it requests and never starts or stops it.
+type Endpoint struct {
+	// URL is the server's base URL, without the /v1 or /api path
+	URL string `yaml:"url"`
+	// API is "openai" (the default) or "ollama"
+	API string `yaml:"api"`
+	// APIKey is sent as a bearer token
+	APIKey string `yaml:"api_key"`
+	// Headers are added to every request
+	Headers map[string]string `yaml:"headers"`
+	// CACert is a PEM bundle of certificate authorities to trust besides the system ones
+	CACert string `yaml:"ca_cert"`
+	// Insecure skips verifying the server's TLS certificate
+	Insecure bool `yaml:"insecure"`
+	// Images reports whether the server's models accept images
+	Images bool `yaml:"images"`
+}
+
+// NewRemoteClient creates a client for an LLM served by a remote endpoint
+func NewRemoteClient(e Endpoint, llm string) (ModelServerClient, error) {
+	c, err := e.conn()
+	if err != nil {
+		return nil, err
+	}
+	if e.API == OllamaAPI {
+		return &OllamaClient{llm, c}, nil
+	}
+	return &MlxLMClient{llm, c, Capabilities{Images: e.Images}, true}, nil
+}
+
+// openAIChatRequest names the model and sends logprobs as a boolean with the
+// number of alternatives in top_logprobs, as the OpenAI API specifies
+type openAIChatRequest struct {
+	Model string `json:"model"`
+	*ChatRequest
+}
+
+// ListModels asks a remote endpoint which models it serves, through
+// /api/tags for Ollama and /v1/models otherwise
+func ListModels(ctx context.Context, e Endpoint) ([]string, error) {
+	c, err := e.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	var models []string
+	if e.API == OllamaAPI {
+		var tags struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := c.getJSON(ctx, "/api/tags", &tags); err != nil {
+			return nil, err
+		}
+		for _, model := range tags.Models {
+			models = append(models, model.Name)
+		}
+		return models, nil
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, "/v1/models", &list); err != nil {
+		return nil, err
+	}
+	for _, model := range list.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
+
+// Validate checks that the endpoint can be connected to
+func (e Endpoint) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, expected http(s)://host[:port]", e.URL)
+	}
+	if e.API != "" && e.API != OpenAIAPI && e.API != OllamaAPI {
+		return fmt.Errorf("invalid api %q, expected %s or %s", e.API, OpenAIAPI, OllamaAPI)
+	}
+	return nil
+}
+
+// conn creates the connection settings of the endpoint. Environment variables
+// such as ${TOKEN} are expanded in the API key, headers and CA bundle path so
+// secrets can be kept out of the config file.
+func (e Endpoint) conn() (conn, error) {
+	if err := e.Validate(); err != nil {
+		return conn{}, err
+	}
+
+	header := make(http.Header)
+	for name, value := range e.Headers {
+		header.Set(name, os.ExpandEnv(value))
+	}
+	if e.APIKey != "" {
+		header.Set("Authorization", "Bearer "+os.ExpandEnv(e.APIKey))
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: e.Insecure}
+	if e.CACert != "" {
+		pem, err := os.ReadFile(os.ExpandEnv(e.CACert))
+		if err != nil {
+			return conn{}, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return conn{}, fmt.Errorf("no certificates found in %s", e.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return conn{
+		baseURL: strings.TrimSuffix(e.URL, "/"),
+		header:  header,
+		client:  newHTTPClient(timeouts, tlsConfig),
+	}, nil
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newRemoteServer starts a TLS server that requires the given bearer token and
+// answers like an OpenAI-compatible and an Ollama server at once
+func newRemoteServer(t *testing.T, token string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object": "list", "data": [{"id": "qwen3-8b"}, {"id": "llama-3.1-70b"}]}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models": [{"name": "gemma3:27b"}]}`)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": "team %s"}}]}`, r.Header.Get("X-Team"))
+	})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"error": {"message": "invalid api key"}}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeCACert writes the certificate of a TLS test server to a PEM file
+func writeCACert(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestListModels(t *testing.T) {
+	server := newRemoteServer(t, "secret")
+	endpoint := Endpoint{URL: server.URL, APIKey: "secret", CACert: writeCACert(t, server)}
+
+	models, err := ListModels(context.Background(), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0] != "qwen3-8b" || models[1] != "llama-3.1-70b" {
+		t.Errorf("unexpected models %v", models)
+	}
+
+	endpoint.API = OllamaAPI
+	models, err = ListModels(context.Background(), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0] != "gemma3:27b" {
+		t.Errorf("unexpected models %v", models)
+	}
+}
+
+func TestRemoteClient(t *testing.T) {
+	server := newRemoteServer(t, "secret")
+	t.Setenv("GOLMS_TEST_TOKEN", "secret")
+	req := NewChatRequest(DefaultChatOptions)
+	req.Messages = []Message{{Role: "user", Content: "Hi"}}
+
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		want     error
+	}{
+		{
+			name:     "untrusted certificate",
+			endpoint: Endpoint{URL: server.URL, APIKey: "secret"},
+			want:     ErrServerUnavailable,
+		},
+		{
+			name:     "insecure",
+			endpoint: Endpoint{URL: server.URL, APIKey: "secret", Insecure: true, Headers: map[string]string{"X-Team": "ml"}},
+		},
+		{
+			name:     "CA bundle and key from the environment",
+			endpoint: Endpoint{URL: server.URL, APIKey: "${GOLMS_TEST_TOKEN}", CACert: writeCACert(t, server), Headers: map[string]string{"X-Team": "ml"}},
+		},
+		{
+			name:     "wrong key",
+			endpoint: Endpoint{URL: server.URL, APIKey: "guess", Insecure: true},
+			want:     ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRemoteClient(tt.endpoint, "qwen3-8b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Chat(context.Background(), req, nil)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("expected %v, got %v", tt.want, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content := resp.Choices[0].Message.Content; content != "team ml" {
+				t.Errorf("expected the custom header to be sent, got %q", content)
+			}
+		})
+	}
+}
+
+func TestRemoteClientRequest(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"choices": [{"text": "Hi", "message": {"role": "assistant", "content": "Hi"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := NewRemoteClient(Endpoint{URL: server.URL}, "llama-3.1-70b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultChatOptions
+	options.LogProbs = 3
+	req := NewChatRequest(options)
+	req.Messages = []Message{{Role: "user", Content: "Hi"}}
+	if _, err := c.Chat(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Standard OpenAI-compatible servers need the model and a boolean logprobs
+	if body["model"] != "llama-3.1-70b" || body["logprobs"] != true || body["top_logprobs"] != float64(3) {
+		t.Errorf("unexpected request %v", body)
+	}
+
+	if _, err := c.Complete(context.Background(), NewCompletionRequest("Once", DefaultChatOptions), nil); err != nil {
+		t.Fatal(err)
+	}
+	if body["model"] != "llama-3.1-70b" || body["prompt"] != "Once" {
+		t.Errorf("unexpected completion request %v", body)
+	}
+}
+
+func TestEndpointValidate(t *testing.T) {
+	for _, endpoint := range []Endpoint{
+		{URL: "gpu-box:11434"},
+		{URL: "ftp://gpu-box"},
+		{URL: "http://gpu-box", API: "anthropic"},
+	} {
+		if err := endpoint.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", endpoint)
+		}
+	}
+	if err := (Endpoint{URL: "https://gpu-box:8000/", API: OpenAIAPI}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// Dir returns the golms state directory (~/.golms), creating it if needed.
+// This is kept separate from ~/golms/ which only holds model server directories.
+func Dir() (string, error) {
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := path.Join(homePath, ".golms")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// SubDir returns a named directory under the golms state directory, creating it if needed
+func SubDir(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	subDir := path.Join(dir, name)
+	if err := os.MkdirAll(subDir, 0o755); err != nil {
+		return "", err
+	}
+	return subDir, nil
+}
+
+// Config holds user settings read from ~/.golms/config.yaml
+type Config struct {
+	// Chat holds default chat options, which personas and flags override
+	Chat client.Overrides `yaml:"chat"`
+	// Timeouts bound requests to model servers, unset ones keep the defaults
+	Timeouts client.Timeouts `yaml:"timeouts"`
+	// Endpoints are remote model servers by name
+	Endpoints map[string]client.Endpoint `yaml:"endpoints"`
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "config.yaml"), nil
+}
+
+// Load reads the config file. A missing file is an empty config.
+func Load() (*Config, error) {
+	configPath, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	return &cfg, nil
+}
+
+// Endpoint returns the remote endpoint with the given name
+func (c *Config) Endpoint(name string) (client.Endpoint, error) {
+	endpoint, ok := c.Endpoints[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(c.Endpoints))
+		if len(names) == 0 {
+			return endpoint, fmt.Errorf("no endpoint named %s, endpoints are configured in ~/.golms/config.yaml", name)
+		}
+		return endpoint, fmt.Errorf("no endpoint named %s (configured: %s)", name, strings.Join(names, ", "))
+	}
+	if err := endpoint.Validate(); err != nil {
+		return endpoint, fmt.Errorf("endpoint %s: %w", name, err)
+	}
+	return endpoint, nil
+}
@@ -1,9 +1,10 @@
 package constants
 
 const (
-	Ollama = "ollama"
-	Mlx_lm = "mlx_lm"
+	Ollama  = "ollama"
+	Mlx_lm  = "mlx_lm"
+	Mlx_vlm = "mlx_vlm"
 )
 
-var AvailableModelServers = []string{Ollama, Mlx_lm}
+var AvailableModelServers = []string{Ollama, Mlx_lm, Mlx_vlm}
 var Localhost = "127.0.0.1"
@@ -3,10 +3,13 @@ package discovery
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/changminbark/golms/pkg/constants"
 )
@@ -63,6 +66,10 @@ func ListAllModelServers() ([]string, error) {
 	if isPythonModuleAvailable(constants.Mlx_lm) {
 		modelServerStringList = append(modelServerStringList, constants.Mlx_lm)
 	}
+	// Check if mlx_vlm exists
+	if isPythonModuleAvailable(constants.Mlx_vlm) {
+		modelServerStringList = append(modelServerStringList, constants.Mlx_vlm)
+	}
 
 	return modelServerStringList, nil
 }
@@ -80,3 +87,70 @@ func isPythonModuleAvailable(module string) bool {
 	cmd := exec.Command("python3", "-c", fmt.Sprintf("import %s", module))
 	return cmd.Run() == nil
 }
+
+// Model describes a single LLM directory found under ~/golms/<model_server>/
+type Model struct {
+	Server string
+	Name   string
+	Path   string
+	Size   int64
+}
+
+// ListAllModels returns every LLM under ~/golms/ sorted by server and model name
+func ListAllModels() ([]Model, error) {
+	llmListMap, err := ListAllLLMs()
+	if err != nil {
+		return nil, err
+	}
+
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var models []Model
+	for modelServer, llmList := range llmListMap {
+		for _, llm := range llmList {
+			modelPath := path.Join(homePath, "golms", modelServer, llm)
+			size, err := dirSize(modelPath)
+			if err != nil {
+				return nil, err
+			}
+			models = append(models, Model{
+				Server: modelServer,
+				Name:   llm,
+				Path:   modelPath,
+				Size:   size,
+			})
+		}
+	}
+
+	// Map iteration order is random, so sort for stable output
+	slices.SortFunc(models, func(a, b Model) int {
+		if a.Server != b.Server {
+			return strings.Compare(a.Server, b.Server)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return models, nil
+}
+
+// dirSize returns the total size in bytes of all regular files under root
+func dirSize(root string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
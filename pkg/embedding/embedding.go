@@ -0,0 +1,161 @@
+// Package embedding embeds lines of text in batches and writes the vectors
+// as JSONL or NumPy .npy files
+package embedding
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// DefaultBatchSize is the number of lines embedded per request
+const DefaultBatchSize = 32
+
+type Format string
+
+const (
+	JSONL Format = "jsonl"
+	NPY   Format = "npy"
+)
+
+// ParseFormat validates the value of a --format flag
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case JSONL, NPY:
+		return Format(value), nil
+	}
+	return "", fmt.Errorf("invalid embedding format %q (expected jsonl or npy)", value)
+}
+
+// FormatFromPath picks the format matching a file's extension, JSONL by default
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".npy") {
+		return NPY
+	}
+	return JSONL
+}
+
+// Input is a line of text to embed
+type Input struct {
+	// Line is the line's number in the input, counting from 1
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// Result is an embedded line
+type Result struct {
+	Input
+	Embedding []float32 `json:"embedding"`
+}
+
+// ReadInputs reads the lines of r, skipping blank ones since embedding
+// servers reject empty input
+func ReadInputs(r io.Reader) ([]Input, error) {
+	var inputs []Input
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) != "" {
+			inputs = append(inputs, Input{Line: line, Text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("no lines to embed")
+	}
+	return inputs, nil
+}
+
+// Embed embeds inputs in batches, reporting progress as the number of
+// lines embedded so far
+func Embed(ctx context.Context, embedder client.Embedder, inputs []Input, batchSize int, progress func(done, total int)) ([]Result, error) {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	results := make([]Result, 0, len(inputs))
+	for start := 0; start < len(inputs); start += batchSize {
+		batch := inputs[start:min(start+batchSize, len(inputs))]
+		texts := make([]string, len(batch))
+		for i, input := range batch {
+			texts[i] = input.Text
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed line %d: %w", batch[0].Line, err)
+		}
+		for i, vector := range vectors {
+			if len(results) > 0 && len(vector) != len(results[0].Embedding) {
+				return nil, fmt.Errorf("line %d has %d dimensions but earlier lines have %d", batch[i].Line, len(vector), len(results[0].Embedding))
+			}
+			results = append(results, Result{batch[i], vector})
+		}
+		if progress != nil {
+			progress(len(results), len(inputs))
+		}
+	}
+	return results, nil
+}
+
+// Write writes results in the given format
+func Write(w io.Writer, results []Result, format Format) error {
+	switch format {
+	case JSONL:
+		return WriteJSONL(w, results)
+	case NPY:
+		return WriteNPY(w, results)
+	default:
+		return fmt.Errorf("unsupported embedding format: %s", format)
+	}
+}
+
+// WriteJSONL writes a JSON object per result with its line number, text and embedding
+func WriteJSONL(w io.Writer, results []Result) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteNPY writes the embeddings as a float32 matrix in NumPy's .npy format,
+// one row per result
+func WriteNPY(w io.Writer, results []Result) error {
+	dimensions := 0
+	if len(results) > 0 {
+		dimensions = len(results[0].Embedding)
+	}
+
+	// The header is a Python dict literal padded so the data starts at a multiple of 64 bytes
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", len(results), dimensions)
+	const preamble = 10 // magic, version and header length
+	padding := 64 - (preamble+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	bw.WriteString(header)
+	for _, result := range results {
+		if err := binary.Write(bw, binary.LittleEndian, result.Embedding); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
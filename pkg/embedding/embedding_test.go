@@ -0,0 +1,79 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+func TestReadInputs(t *testing.T) {
+	inputs, err := ReadInputs(strings.NewReader("first\n\n  \nsecond\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 2 || inputs[0] != (Input{1, "first"}) || inputs[1] != (Input{4, "second"}) {
+		t.Errorf("unexpected inputs %+v", inputs)
+	}
+	if _, err := ReadInputs(strings.NewReader("\n\n")); err == nil {
+		t.Error("expected blank input to be rejected")
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	server := fakeserver.New(t)
+	embedder := client.NewClient(constants.Mlx_lm, "embed", server.Host(), server.Port())
+	inputs := []Input{{1, "a"}, {2, "b"}, {3, "c"}}
+
+	batches := 0
+	results, err := Embed(context.Background(), embedder, inputs, 2, func(done, total int) { batches++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || batches != 2 || results[2].Line != 3 || len(results[2].Embedding) != fakeserver.EmbeddingDimensions {
+		t.Fatalf("unexpected results %+v in %d batches", results, batches)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, results, JSONL); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first Result
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || len(lines) != 3 || first.Text != "a" {
+		t.Errorf("unexpected JSONL %s (%v)", buf.String(), err)
+	}
+}
+
+func TestWriteNPY(t *testing.T) {
+	results := []Result{
+		{Input{1, "a"}, []float32{1, 2, 3}},
+		{Input{2, "b"}, []float32{4, 5, 6}},
+	}
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("\x93NUMPY\x01\x00")) {
+		t.Fatalf("missing magic in %q", data[:10])
+	}
+	headerLength := int(binary.LittleEndian.Uint16(data[8:10]))
+	header := string(data[10 : 10+headerLength])
+	if (10+headerLength)%64 != 0 || !strings.HasSuffix(header, "\n") || !strings.Contains(header, "'shape': (2, 3)") || !strings.Contains(header, "'<f4'") {
+		t.Errorf("unexpected header %q", header)
+	}
+	matrix := make([]float32, 6)
+	if err := binary.Read(bytes.NewReader(data[10+headerLength:]), binary.LittleEndian, matrix); err != nil {
+		t.Fatal(err)
+	}
+	if matrix[3] != 4 || matrix[5] != 6 {
+		t.Errorf("unexpected data %v", matrix)
+	}
+}
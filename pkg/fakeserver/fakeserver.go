@@ -0,0 +1,243 @@
+// Package fakeserver provides a scripted OpenAI-compatible server for tests
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// Reply is one scripted response. A non-zero Status makes the server
+// answer with that status code and Body instead of Message.
+type Reply struct {
+	Message  client.Message
+	Usage    client.Usage
+	LogProbs *client.LogProbs
+	Status   int
+	Body     string
+}
+
+// Server answers /v1/chat/completions and /v1/completions requests with its
+// scripted replies in order, recording every request it receives. It answers
+// /v1/embeddings requests with the vectors of Embedding.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	replies     []Reply
+	requests    []client.ChatRequest
+	completions []client.CompletionRequest
+}
+
+// New starts a server that answers with replies and is closed when the test ends
+func New(t testing.TB, replies ...Reply) *Server {
+	s := &Server{replies: replies}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+	mux.HandleFunc("POST /v1/completions", s.handleCompletion)
+	mux.HandleFunc("POST /v1/embeddings", handleEmbeddings)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Host returns the host the server listens on
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []client.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.ChatRequest(nil), s.requests...)
+}
+
+// CompletionRequests returns the completion requests received so far
+func (s *Server) CompletionRequests() []client.CompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.CompletionRequest(nil), s.completions...)
+}
+
+// nextReply pops the next scripted reply, answering with an error instead
+// when there is none left or the reply is one
+func (s *Server) nextReply(w http.ResponseWriter) (Reply, bool) {
+	s.mu.Lock()
+	if len(s.replies) == 0 {
+		s.mu.Unlock()
+		http.Error(w, "fakeserver: no scripted replies left", http.StatusInternalServerError)
+		return Reply{}, false
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	s.mu.Unlock()
+
+	if reply.Status != 0 {
+		http.Error(w, reply.Body, reply.Status)
+		return Reply{}, false
+	}
+	return reply, true
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req client.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+	reply, ok := s.nextReply(w)
+	if !ok {
+		return
+	}
+
+	message := reply.Message
+	if message.Role == "" {
+		message.Role = "assistant"
+	}
+	finishReason := "stop"
+	if len(message.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(client.ChatResponse{
+			ID:      "chatcmpl-fake",
+			Object:  "chat.completion",
+			Model:   "fake",
+			Choices: []client.Choice{{FinishReason: finishReason, Message: message, LogProbs: reply.LogProbs}},
+			Usage:   reply.Usage,
+		})
+		return
+	}
+
+	// Stream the whole message as a single delta followed by the finish reason
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i := range message.ToolCalls {
+		message.ToolCalls[i].Index = i
+	}
+	usage := reply.Usage
+	chunks := []client.ChatChunk{
+		{ID: "chatcmpl-fake", Object: "chat.completion.chunk", Model: "fake", Choices: []client.ChunkChoice{{Delta: message, LogProbs: reply.LogProbs}}},
+		{ID: "chatcmpl-fake", Object: "chat.completion.chunk", Model: "fake", Choices: []client.ChunkChoice{{FinishReason: finishReason}}, Usage: &usage},
+	}
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// handleCompletion answers with the content of the next reply's message as the text
+func (s *Server) handleCompletion(w http.ResponseWriter, r *http.Request) {
+	var req client.CompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.completions = append(s.completions, req)
+	s.mu.Unlock()
+	reply, ok := s.nextReply(w)
+	if !ok {
+		return
+	}
+
+	resp := client.CompletionResponse{
+		ID:      "cmpl-fake",
+		Object:  "text_completion",
+		Model:   "fake",
+		Choices: []client.CompletionChoice{{Text: reply.Message.Content, FinishReason: "stop"}},
+		Usage:   reply.Usage,
+	}
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Stream the whole text as a single event
+	w.Header().Set("Content-Type", "text/event-stream")
+	data, _ := json.Marshal(resp)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// EmbeddingDimensions is the length of the vectors returned by Embedding
+const EmbeddingDimensions = 64
+
+// Embedding returns a deterministic vector for text, made by hashing its
+// words into buckets, so texts sharing words are similar
+func Embedding(text string) []float32 {
+	vector := make([]float32, EmbeddingDimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%EmbeddingDimensions]++
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		for i := range vector {
+			vector[i] /= float32(math.Sqrt(norm))
+		}
+	}
+	return vector
+}
+
+func handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type data struct {
+		Object    string    `json:"object"`
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	}
+	resp := struct {
+		Object string `json:"object"`
+		Model  string `json:"model"`
+		Data   []data `json:"data"`
+	}{Object: "list", Model: req.Model, Data: []data{}}
+	for i, input := range req.Input {
+		resp.Data = append(resp.Data, data{"embedding", i, Embedding(input)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
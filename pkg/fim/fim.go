@@ -0,0 +1,231 @@
+// Package fim completes code between a prefix and a suffix with code models,
+// formatting fill-in-the-middle prompts with each model family's tokens
+package fim
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/config"
+)
+
+// DefaultOptions keep infills short and predictable, as editors ask for one
+// on every pause in typing
+var DefaultOptions = client.ChatOptions{
+	Temperature: 0.2,
+	MaxTokens:   128,
+}
+
+//go:embed templates.yaml
+var builtinTemplates []byte
+
+// Template is the fill-in-the-middle prompt format of a model family
+type Template struct {
+	Family string `yaml:"family"`
+	// Models are parts of the names of the family's models
+	Models []string `yaml:"models"`
+	// Format is a Go text/template executed with .Prefix and .Suffix
+	Format string   `yaml:"format"`
+	Stop   []string `yaml:"stop"`
+}
+
+// Parse reads a list of templates in the layout of templates.yaml
+func Parse(data []byte) ([]Template, error) {
+	var templates []Template
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if t.Family == "" || t.Format == "" {
+			return nil, errors.New("every FIM template needs a family and a format")
+		}
+		if _, err := template.New(t.Family).Parse(t.Format); err != nil {
+			return nil, fmt.Errorf("invalid format for FIM family %s: %w", t.Family, err)
+		}
+	}
+	return templates, nil
+}
+
+// Templates returns the templates from ~/.golms/fim.yaml followed by the builtin ones
+func Templates() ([]Template, error) {
+	templates, err := Parse(builtinTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path.Join(dir, "fim.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return templates, nil
+		}
+		return nil, err
+	}
+	user, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ~/.golms/fim.yaml: %w", err)
+	}
+	return append(user, templates...), nil
+}
+
+// Find returns the template of family, or the first one matching model when
+// family is empty
+func Find(templates []Template, model string, family string) (Template, error) {
+	model = strings.ToLower(model)
+	for _, t := range templates {
+		if family != "" {
+			if t.Family == family {
+				return t, nil
+			}
+			continue
+		}
+		if slices.ContainsFunc(t.Models, func(name string) bool { return strings.Contains(model, strings.ToLower(name)) }) {
+			return t, nil
+		}
+	}
+
+	var families []string
+	for _, t := range templates {
+		if !slices.Contains(families, t.Family) {
+			families = append(families, t.Family)
+		}
+	}
+	if family != "" {
+		return Template{}, fmt.Errorf("unknown FIM family %q (expected one of %s)", family, strings.Join(families, ", "))
+	}
+	return Template{}, fmt.Errorf("no FIM template matches %s, choose one of %s with --family", model, strings.Join(families, ", "))
+}
+
+// Prompt formats prefix and suffix as a fill-in-the-middle prompt
+func (t Template) Prompt(prefix string, suffix string) (string, error) {
+	tmpl, err := template.New(t.Family).Parse(t.Format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, struct{ Prefix, Suffix string }{prefix, suffix})
+	return b.String(), err
+}
+
+// Request asks for the code between Prefix and Suffix
+type Request struct {
+	Prefix      string   `json:"prefix"`
+	Suffix      string   `json:"suffix"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type Response struct {
+	Text         string `json:"text"`
+	Model        string `json:"model"`
+	Family       string `json:"family"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// Infiller completes code with a model through its completion endpoint
+type Infiller struct {
+	Client   client.ModelServerClient
+	Template Template
+	// Options are used for the sampling settings a request leaves unset
+	Options client.ChatOptions
+}
+
+// Infill returns the code between the request's prefix and suffix
+func (f *Infiller) Infill(ctx context.Context, req Request) (*Response, error) {
+	if req.Prefix == "" && req.Suffix == "" {
+		return nil, errors.New("prefix and suffix are both empty")
+	}
+	prompt, err := f.Template.Prompt(req.Prefix, req.Suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	options := f.Options
+	options.Stream = false
+	if req.MaxTokens > 0 {
+		options.MaxTokens = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		options.Temperature = *req.Temperature
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	completionReq := client.NewCompletionRequest(prompt, options)
+	completionReq.Stop = nil
+	for _, stop := range slices.Concat(options.Sampling.Stop, req.Stop, f.Template.Stop) {
+		if !slices.Contains(completionReq.Stop, stop) {
+			completionReq.Stop = append(completionReq.Stop, stop)
+		}
+	}
+
+	completionResp, err := f.Client.Complete(ctx, completionReq, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not every server leaves the stop sequence out of the text
+	text := completionResp.Text()
+	for _, stop := range completionReq.Stop {
+		if i := strings.Index(text, stop); i >= 0 {
+			text = text[:i]
+		}
+	}
+	return &Response{
+		Text:         text,
+		Model:        f.Client.LLM(),
+		Family:       f.Template.Family,
+		FinishReason: completionResp.Choices[0].FinishReason,
+	}, nil
+}
+
+// Split splits content at a line:column position, both counting from 1
+// and columns counting characters. An empty position splits at the end.
+func Split(content string, position string) (prefix string, suffix string, err error) {
+	if position == "" {
+		return content, "", nil
+	}
+	lineText, columnText, ok := strings.Cut(position, ":")
+	line, lineErr := strconv.Atoi(lineText)
+	column, columnErr := strconv.Atoi(columnText)
+	if !ok || lineErr != nil || columnErr != nil || line < 1 || column < 1 {
+		return "", "", fmt.Errorf("invalid position %q (expected line:column)", position)
+	}
+
+	offset := 0
+	for range line - 1 {
+		i := strings.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return "", "", fmt.Errorf("position %s is past the end of the file", position)
+		}
+		offset += i + 1
+	}
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[offset:], '\n'); i >= 0 {
+		lineEnd = offset + i
+	}
+	for range column - 1 {
+		if offset >= lineEnd {
+			return "", "", fmt.Errorf("position %s is past the end of line %d", position, line)
+		}
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+	}
+	return content[:offset], content[offset:], nil
+}
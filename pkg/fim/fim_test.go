@@ -0,0 +1,151 @@
+package fim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+func TestFind(t *testing.T) {
+	templates, err := Parse(builtinTemplates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		model  string
+		family string
+		want   string
+		prompt string
+	}{
+		{"CodeLlama-7b-hf", "", "codellama", "<PRE> a <SUF>b <MID>"},
+		{"starcoder2-3b", "", "starcoder", "<fim_prefix>a<fim_suffix>b<fim_middle>"},
+		{"Qwen2.5-Coder-1.5B-4bit", "", "qwen-coder", "<|fim_prefix|>a<|fim_suffix|>b<|fim_middle|>"},
+		{"deepseek-coder:6.7b-base", "", "deepseek-coder", "<｜fim▁begin｜>a<｜fim▁hole｜>b<｜fim▁end｜>"},
+		{"my-finetune", "starcoder", "starcoder", "<fim_prefix>a<fim_suffix>b<fim_middle>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			tmpl, err := Find(templates, tt.model, tt.family)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompt, err := tmpl.Prompt("a", "b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tmpl.Family != tt.want || prompt != tt.prompt {
+				t.Errorf("got %s with prompt %q, want %s with %q", tmpl.Family, prompt, tt.want, tt.prompt)
+			}
+		})
+	}
+
+	if _, err := Find(templates, "llama-3.2-1b", ""); err == nil || !strings.Contains(err.Error(), "--family") {
+		t.Errorf("expected an unmatched model to suggest --family, got %v", err)
+	}
+	if _, err := Find(templates, "starcoder2", "alpaca"); err == nil {
+		t.Error("expected an unknown family to be rejected")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	content := "func main() {\n\tfmt.Println(\"héllo\")\n}\n"
+	tests := []struct {
+		position string
+		prefix   string
+		wantErr  bool
+	}{
+		{"", content, false},
+		{"1:1", "", false},
+		{"2:2", "func main() {\n\t", false},
+		// Columns count characters, not bytes
+		{"2:17", "func main() {\n\tfmt.Println(\"hé", false},
+		{"4:1", content, false},
+		{"2:40", "", true},
+		{"9:1", "", true},
+		{"2", "", true},
+	}
+	for _, tt := range tests {
+		prefix, suffix, err := Split(content, tt.position)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.position)
+			}
+			continue
+		}
+		if err != nil || prefix != tt.prefix || prefix+suffix != content {
+			t.Errorf("%s: got %q, %q (%v), want prefix %q", tt.position, prefix, suffix, err, tt.prefix)
+		}
+	}
+}
+
+func TestInfill(t *testing.T) {
+	server := fakeserver.New(t,
+		fakeserver.Reply{Message: client.Message{Content: "return a + b<|endoftext|>"}},
+		fakeserver.Reply{Message: client.Message{Content: "return a - b"}},
+	)
+	templates, _ := Parse(builtinTemplates)
+	tmpl, _ := Find(templates, "qwen2.5-coder", "")
+	f := &Infiller{
+		Client:   client.NewClient(constants.Mlx_lm, "qwen2.5-coder", server.Host(), server.Port()),
+		Template: tmpl,
+		Options:  DefaultOptions,
+	}
+
+	resp, err := f.Infill(context.Background(), Request{Prefix: "def add(a, b):\n    ", Suffix: "\n", Stop: []string{"\n\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "return a + b" || resp.Family != "qwen-coder" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	req := server.CompletionRequests()[0]
+	if req.Prompt != "<|fim_prefix|>def add(a, b):\n    <|fim_suffix|>\n<|fim_middle|>" || req.MaxTokens != DefaultOptions.MaxTokens {
+		t.Errorf("unexpected completion request %+v", req)
+	}
+	if req.Stop[0] != "\n\n" || !slices.Contains(req.Stop, "<|fim_pad|>") {
+		t.Errorf("unexpected stop sequences %q", req.Stop)
+	}
+
+	// The same infill over HTTP
+	ts := httptest.NewServer(NewHandler(f))
+	defer ts.Close()
+	httpResp, err := http.Post(ts.URL+"/fim", "application/json", strings.NewReader(`{"prefix": "def sub(a, b):\n    ", "max_tokens": 16}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	var body Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&body); err != nil || httpResp.StatusCode != http.StatusOK || body.Text != "return a - b" {
+		t.Errorf("unexpected HTTP response %d %+v (%v)", httpResp.StatusCode, body, err)
+	}
+	if req := server.CompletionRequests()[1]; req.MaxTokens != 16 {
+		t.Errorf("max_tokens was not applied: %+v", req)
+	}
+
+	httpResp, err = http.Post(ts.URL+"/fim", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an empty request to be rejected, got %d", httpResp.StatusCode)
+	}
+
+	large := `{"prefix": "` + strings.Repeat("x", MaxRequestSize) + `"}`
+	httpResp, err = http.Post(ts.URL+"/fim", "application/json", strings.NewReader(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a request over %d bytes to be rejected, got %d", MaxRequestSize, httpResp.StatusCode)
+	}
+}
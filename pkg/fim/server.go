@@ -0,0 +1,50 @@
+package fim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// MaxRequestSize is the largest request body NewHandler accepts
+const MaxRequestSize = 4 << 20
+
+// NewHandler returns an HTTP handler answering POST /fim requests, which
+// take a JSON Request and return a JSON Response, for editor plugins
+func NewHandler(f *Infiller) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /fim", func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize)).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, "request body is larger than 4 MiB")
+				return
+			}
+			writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		resp, err := f.Infill(r.Context(), req)
+		if err != nil {
+			// Errors from the model server are ours, anything else is the request's
+			status := http.StatusBadRequest
+			var clientErr *client.Error
+			if errors.As(err, &clientErr) {
+				status = http.StatusBadGateway
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
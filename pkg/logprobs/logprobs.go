@@ -0,0 +1,161 @@
+// Package logprobs renders the log probabilities of generated tokens for
+// inspecting how confident a model was in its response
+package logprobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+// Format selects how token log probabilities are displayed
+type Format string
+
+const (
+	// Color prints the response with each token colored by confidence
+	Color Format = "color"
+	// Table lists every token with its probability and alternatives
+	Table Format = "table"
+	// JSON dumps the tokens with their probabilities and alternatives
+	JSON Format = "json"
+)
+
+var AvailableFormats = []Format{Color, Table, JSON}
+
+// confidence is a range of probabilities rendered in the same style
+type confidence struct {
+	// min is the lowest probability in the range
+	min   float64
+	label string
+	style lipgloss.Style
+}
+
+// levels go from most to least confident
+var levels = []confidence{
+	{0.9, "≥90%", lipgloss.NewStyle()},
+	{0.6, "≥60%", lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#2E8B57", Dark: "#73D788"})},
+	{0.3, "≥30%", lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#C98A00", Dark: "#FFB84D"})},
+	{0, "<30%", lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#FF4545", Dark: "#FF6B6B"}).Underline(true)},
+}
+
+// ParseFormat validates a format name
+func ParseFormat(value string) (Format, error) {
+	format := Format(value)
+	if !slices.Contains(AvailableFormats, format) {
+		return "", fmt.Errorf("invalid logprobs format %q (available: color, table, json)", value)
+	}
+	return format, nil
+}
+
+// Write renders tokens in the given format
+func Write(format Format, tokens []client.TokenLogProb) (string, error) {
+	switch format {
+	case Table:
+		return RenderTable(tokens), nil
+	case JSON:
+		return RenderJSON(tokens)
+	default:
+		return RenderColor(tokens) + "\n\n" + Legend(), nil
+	}
+}
+
+// RenderColor reproduces the response text with each token colored by the
+// probability the model assigned to it
+func RenderColor(tokens []client.TokenLogProb) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		style := level(client.Probability(token.LogProb)).style
+		// Style each line separately so newlines inside a token are kept
+		b.WriteString(ui.RenderLines(style, token.Token))
+	}
+	return b.String()
+}
+
+// Legend explains the colors used by RenderColor
+func Legend() string {
+	parts := make([]string, 0, len(levels))
+	for _, l := range levels {
+		parts = append(parts, l.style.Render(l.label))
+	}
+	return "Confidence: " + strings.Join(parts, "  ")
+}
+
+// RenderTable lists each token with its probability and the alternatives the model considered
+func RenderTable(tokens []client.TokenLogProb) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTOKEN\tPROB\tLOGPROB\tALTERNATIVES")
+	for i, token := range tokens {
+		alternatives := make([]string, 0, len(token.TopLogProbs))
+		for _, alternative := range token.TopLogProbs {
+			if alternative.Token == token.Token {
+				continue
+			}
+			alternatives = append(alternatives, fmt.Sprintf("%s %s", strconv.Quote(alternative.Token), percent(alternative.LogProb)))
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.4f\t%s\n", i, strconv.Quote(token.Token), percent(token.LogProb), token.LogProb, strings.Join(alternatives, ", "))
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// tokenJSON adds the probability to a token for easier reading of dumps
+type tokenJSON struct {
+	Token       string            `json:"token"`
+	LogProb     float64           `json:"logprob"`
+	Probability float64           `json:"probability"`
+	TopLogProbs []alternativeJSON `json:"top_logprobs,omitempty"`
+}
+
+type alternativeJSON struct {
+	Token       string  `json:"token"`
+	LogProb     float64 `json:"logprob"`
+	Probability float64 `json:"probability"`
+}
+
+// RenderJSON dumps the tokens as a JSON array
+func RenderJSON(tokens []client.TokenLogProb) (string, error) {
+	dump := make([]tokenJSON, 0, len(tokens))
+	for _, token := range tokens {
+		t := tokenJSON{
+			Token:       token.Token,
+			LogProb:     token.LogProb,
+			Probability: client.Probability(token.LogProb),
+		}
+		for _, alternative := range token.TopLogProbs {
+			t.TopLogProbs = append(t.TopLogProbs, alternativeJSON{
+				Token:       alternative.Token,
+				LogProb:     alternative.LogProb,
+				Probability: client.Probability(alternative.LogProb),
+			})
+		}
+		dump = append(dump, t)
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// level returns the confidence level a probability falls into
+func level(probability float64) confidence {
+	for _, l := range levels {
+		if probability >= l.min {
+			return l
+		}
+	}
+	return levels[len(levels)-1]
+}
+
+func percent(logProb float64) string {
+	return fmt.Sprintf("%.1f%%", client.Probability(logProb)*100)
+}
@@ -0,0 +1,53 @@
+package logprobs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+var tokens = []client.TokenLogProb{
+	{Token: "The", LogProb: -0.01},
+	{Token: " cat", LogProb: -1.2, TopLogProbs: []client.TopLogProb{
+		{Token: " cat", LogProb: -1.2},
+		{Token: " dog", LogProb: -0.5},
+	}},
+}
+
+func TestRenderTable(t *testing.T) {
+	table := RenderTable(tokens)
+	lines := strings.Split(table, "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 rows, got:\n%s", table)
+	}
+	if !strings.Contains(lines[2], `" cat"`) || !strings.Contains(lines[2], "30.1%") || !strings.Contains(lines[2], `" dog" 60.7%`) {
+		t.Errorf("unexpected row: %q", lines[2])
+	}
+	if strings.Contains(lines[2], `" cat" 30.1%`) {
+		t.Errorf("the chosen token should not be listed as its own alternative: %q", lines[2])
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	out, err := RenderJSON(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump []tokenJSON
+	if err := json.Unmarshal([]byte(out), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if len(dump) != 2 || dump[0].Probability < 0.98 || len(dump[1].TopLogProbs) != 2 {
+		t.Errorf("unexpected dump: %+v", dump)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	for probability, label := range map[float64]string{0.95: "≥90%", 0.7: "≥60%", 0.3: "≥30%", 0.01: "<30%"} {
+		if got := level(probability).label; got != label {
+			t.Errorf("level(%g) = %s, want %s", probability, got, label)
+		}
+	}
+}
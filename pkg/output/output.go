@@ -0,0 +1,104 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	Text  Format = "text"
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+)
+
+var AvailableFormats = []Format{Text, Table, JSON, YAML}
+
+// Row is the stable schema shared by every listing command
+type Row struct {
+	Server    string `json:"server" yaml:"server"`
+	Model     string `json:"model" yaml:"model"`
+	Path      string `json:"path" yaml:"path"`
+	Size      int64  `json:"size" yaml:"size"`
+	Available bool   `json:"available" yaml:"available"`
+	Running   bool   `json:"running" yaml:"running"`
+}
+
+// ParseFormat validates the value of an --output flag
+func ParseFormat(value string) (Format, error) {
+	for _, format := range AvailableFormats {
+		if string(format) == value {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q (expected one of text, table, json, yaml)", value)
+}
+
+// Write renders rows in the given machine-readable format.
+// Text output is styled and left to the individual command handlers.
+func Write(w io.Writer, format Format, rows []Row) error {
+	// Always emit a list so consumers never have to handle null
+	if rows == nil {
+		rows = []Row{}
+	}
+
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(rows); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case Table:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SERVER\tMODEL\tPATH\tSIZE\tAVAILABLE\tRUNNING")
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				row.Server, dash(row.Model), dash(row.Path), formatSize(row.Size),
+				strconv.FormatBool(row.Available), strconv.FormatBool(row.Running))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// humanSize formats a byte count using binary units
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func formatSize(size int64) string {
+	if size == 0 {
+		return "-"
+	}
+	return humanSize(size)
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
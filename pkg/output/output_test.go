@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var rows = []Row{
+	{Server: "mlx_lm", Model: "qwen", Path: "/home/me/golms/mlx_lm/qwen", Size: 1536 * 1024 * 1024, Available: true, Running: true},
+	{Server: "ollama", Available: false},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range AvailableFormats {
+		if got, err := ParseFormat(string(format)); err != nil || got != format {
+			t.Errorf("ParseFormat(%q) = %q, %v", format, got, err)
+		}
+	}
+	if _, err := ParseFormat("csv"); err == nil {
+		t.Error("expected csv to be rejected")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, JSON, rows); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Row
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[0] != rows[0] {
+		t.Errorf("JSON did not round trip: %v\n%s", err, b.String())
+	}
+
+	b.Reset()
+	if err := Write(&b, YAML, rows); err != nil {
+		t.Fatal(err)
+	}
+	decoded = nil
+	if err := yaml.Unmarshal(b.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1] != rows[1] {
+		t.Errorf("YAML did not round trip: %v\n%s", err, b.String())
+	}
+
+	b.Reset()
+	if err := Write(&b, Table, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "SERVER") ||
+		strings.Join(strings.Fields(lines[1]), " ") != "mlx_lm qwen /home/me/golms/mlx_lm/qwen 1.5 GiB true true" ||
+		strings.Join(strings.Fields(lines[2]), " ") != "ollama - - - false false" {
+		t.Errorf("unexpected table:\n%s", b.String())
+	}
+
+	if err := Write(&b, Text, rows); err == nil {
+		t.Error("expected text output to be left to the commands")
+	}
+}
+
+func TestWriteEmptyList(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, JSON, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(b.String()) != "[]" {
+		t.Errorf("expected an empty list, got %q", b.String())
+	}
+}
@@ -0,0 +1,172 @@
+package persona
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/config"
+)
+
+const fileExtension = ".yaml"
+
+var ErrNotFound = errors.New("persona not found")
+
+// Persona is a reusable system prompt with default chat options,
+// stored as ~/.golms/personas/<name>.yaml
+type Persona struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	System      string `yaml:"system"`
+	// Model is the preferred LLM, selected by default when connecting
+	Model string `yaml:"model,omitempty"`
+	// Temperature, max_tokens and sampling parameters
+	client.Overrides `yaml:",inline"`
+}
+
+// Vars are the values available to system prompt templates
+type Vars struct {
+	Date      string
+	Time      string
+	Cwd       string
+	GitBranch string
+	User      string
+	OS        string
+	Model     string
+}
+
+// Dir returns the persona directory, creating it if needed
+func Dir() (string, error) {
+	return config.SubDir("personas")
+}
+
+// Path returns the file a persona is stored in
+func Path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid persona name: %q", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, name+fileExtension), nil
+}
+
+// Load reads the persona with the given name
+func Load(name string) (*Persona, error) {
+	personaPath, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(personaPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, err
+	}
+
+	var p Persona
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse persona %s: %w", name, err)
+	}
+	// The file name is the canonical name
+	p.Name = name
+
+	// Catch template mistakes when loading rather than mid-chat
+	if _, err := template.New(name).Parse(p.System); err != nil {
+		return nil, fmt.Errorf("invalid system prompt template in persona %s: %w", name, err)
+	}
+	return &p, nil
+}
+
+// List returns every persona sorted by name. Personas that fail to load are reported in errs.
+func List() (personas []*Persona, errs []error, err error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), fileExtension)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		p, err := Load(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		personas = append(personas, p)
+	}
+
+	slices.SortFunc(personas, func(a, b *Persona) int { return strings.Compare(a.Name, b.Name) })
+	return personas, errs, nil
+}
+
+// Skeleton returns the initial contents of a new persona file
+func Skeleton(name string) string {
+	return fmt.Sprintf(`# golms persona
+# The system prompt is a Go text/template. Available variables:
+#   {{.Date}} {{.Time}} {{.Cwd}} {{.GitBranch}} {{.User}} {{.OS}} {{.Model}}
+name: %s
+description: ""
+system: |
+  You are a helpful assistant. Today is {{.Date}}.
+# temperature: 0.7
+# max_tokens: 512
+# top_p: 0.9
+# stop: ["###"]
+# model: ""
+`, name)
+}
+
+// CurrentVars collects template variables from the environment
+func CurrentVars(model string) Vars {
+	now := time.Now()
+	vars := Vars{
+		Date:  now.Format("2006-01-02"),
+		Time:  now.Format("15:04"),
+		OS:    runtime.GOOS,
+		Model: model,
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		vars.Cwd = cwd
+	}
+	if u, err := user.Current(); err == nil {
+		vars.User = u.Username
+	}
+	if output, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+		vars.GitBranch = strings.TrimSpace(string(output))
+	}
+	return vars
+}
+
+// Render executes a system prompt template with the given variables
+func Render(system string, vars Vars) (string, error) {
+	tmpl, err := template.New("system").Option("missingkey=error").Parse(system)
+	if err != nil {
+		return "", fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,126 @@
+package persona
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// writePersonas saves persona files under a temporary home directory
+func writePersonas(t *testing.T, files map[string]string) {
+	t.Setenv("HOME", t.TempDir())
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	writePersonas(t, map[string]string{
+		"reviewer.yaml": `name: something else
+description: Reviews code
+system: You review {{.Model}} output.
+model: qwen2.5-7b
+temperature: 0.2
+max_tokens: 100
+top_p: 0.9
+stop: ["###"]
+`,
+		"plain.yaml":    "system: Be brief.\n",
+		"broken.yaml":   "system: [\n",
+		"template.yaml": "system: Today is {{.Date\n",
+	})
+
+	p, err := Load("reviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "reviewer" || p.Description != "Reviews code" || p.Model != "qwen2.5-7b" {
+		t.Errorf("unexpected persona: %+v", p)
+	}
+	// Options set in the file override the defaults, the rest are kept
+	options := client.DefaultChatOptions
+	options.Apply(p.Overrides)
+	if options.Temperature != 0.2 || options.MaxTokens != 100 || *options.Sampling.TopP != 0.9 || !slices.Equal(options.Sampling.Stop, []string{"###"}) {
+		t.Errorf("unexpected options: %+v", options)
+	}
+	plain, err := Load("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = client.DefaultChatOptions
+	options.Apply(plain.Overrides)
+	if options.Temperature != client.DefaultChatOptions.Temperature || options.Sampling.TopP != nil {
+		t.Errorf("expected the default options, got %+v", options)
+	}
+
+	for _, name := range []string{"missing", "broken", "template", "", "../reviewer"} {
+		if _, err := Load(name); err == nil {
+			t.Errorf("expected Load(%q) to fail", name)
+		}
+	}
+	if _, err := Load("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	writePersonas(t, map[string]string{
+		"writer.yaml":   "system: Write well.\n",
+		"coder.yaml":    Skeleton("coder"),
+		"broken.yaml":   "system: [\n",
+		"notes.txt":     "not a persona",
+		"reviewer.yaml": "system: Review.\n",
+	})
+	personas, errs, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range personas {
+		names = append(names, p.Name)
+	}
+	if !slices.Equal(names, []string{"coder", "reviewer", "writer"}) {
+		t.Errorf("got personas %v", names)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected the broken persona to be reported, got %v", errs)
+	}
+}
+
+func TestRender(t *testing.T) {
+	vars := Vars{Date: "2025-01-02", Time: "15:04", Cwd: "/src", GitBranch: "main", User: "ada", OS: "linux", Model: "qwen"}
+	tests := []struct {
+		name    string
+		system  string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", system: "Be brief.", want: "Be brief."},
+		{name: "variables", system: "{{.User}} on {{.OS}} in {{.Cwd}} ({{.GitBranch}}), {{.Date}} {{.Time}}, talking to {{.Model}}", want: "ada on linux in /src (main), 2025-01-02 15:04, talking to qwen"},
+		{name: "conditional", system: "Hi.{{if .GitBranch}} On branch {{.GitBranch}}.{{end}}", want: "Hi. On branch main."},
+		{name: "trimmed", system: "\n  Be brief.\n\n", want: "Be brief."},
+		{name: "unknown variable", system: "{{.Weather}}", wantErr: true},
+		{name: "invalid template", system: "{{.Date", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.system, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
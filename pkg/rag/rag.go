@@ -0,0 +1,406 @@
+// Package rag indexes directories of source files and documents as embedded
+// chunks and retrieves the chunks relevant to a question
+package rag
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/config"
+)
+
+const (
+	// DefaultTopK is the number of chunks retrieved for a question
+	DefaultTopK = 4
+	// maxFileSize skips files too large to be documentation or source
+	maxFileSize = 1024 * 1024
+	// batchSize is the number of chunks embedded per request
+	batchSize = 32
+
+	metadataFile = "index.json"
+	vectorsFile  = "vectors.bin"
+)
+
+// skippedDirs hold dependencies and build output rather than the project itself
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+}
+
+// Options control how files are split into chunks
+type Options struct {
+	// ChunkLines is the number of lines in a chunk
+	ChunkLines int `json:"chunk_lines"`
+	// Overlap is the number of lines consecutive chunks share, so text
+	// around a boundary is found with its context
+	Overlap int `json:"overlap"`
+}
+
+var DefaultOptions = Options{ChunkLines: 40, Overlap: 10}
+
+// Validate checks that chunks make progress through a file
+func (o Options) Validate() error {
+	if o.ChunkLines < 1 {
+		return errors.New("chunks must have at least one line")
+	}
+	if o.Overlap < 0 || o.Overlap >= o.ChunkLines {
+		return fmt.Errorf("overlap must be between 0 and %d lines", o.ChunkLines-1)
+	}
+	return nil
+}
+
+// Chunk is a range of lines of an indexed file
+type Chunk struct {
+	// Source is the file's path relative to the indexed directory
+	Source    string `json:"source"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Text      string `json:"text"`
+}
+
+// Citation names the chunk's file and lines
+func (c Chunk) Citation() string {
+	return fmt.Sprintf("%s:%d-%d", c.Source, c.StartLine, c.EndLine)
+}
+
+// Index holds the chunks of a directory and their embeddings. Chunks and
+// metadata are stored as JSON next to a file of the vectors as float32s.
+type Index struct {
+	// Dir is the absolute path of the indexed directory
+	Dir string `json:"dir"`
+	// Server, Endpoint and Model identify the embedding model, which must
+	// also embed the questions. Endpoint is set for remote model servers.
+	Server     string    `json:"server,omitempty"`
+	Endpoint   string    `json:"endpoint,omitempty"`
+	Model      string    `json:"model"`
+	Options    Options   `json:"options"`
+	Dimensions int       `json:"dimensions"`
+	CreatedAt  time.Time `json:"created_at"`
+	Files      int       `json:"files"`
+	Chunks     []Chunk   `json:"chunks"`
+
+	// vectors are normalized so their dot product is the cosine similarity
+	vectors [][]float32
+}
+
+// Build splits the text files under dir into chunks and embeds them,
+// reporting progress as the number of chunks embedded so far
+func Build(ctx context.Context, embedder client.Embedder, dir string, options Options, progress func(done, total int)) (*Index, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{Dir: dir, Options: options, CreatedAt: time.Now()}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Hidden files and directories such as .git are left out
+		if path != dir && (strings.HasPrefix(entry.Name(), ".") || entry.IsDir() && skippedDirs[entry.Name()]) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		content, ok, err := readText(path)
+		if err != nil || !ok {
+			return err
+		}
+		source, _ := filepath.Rel(dir, path)
+		chunks := Split(filepath.ToSlash(source), content, options)
+		if len(chunks) > 0 {
+			ix.Files++
+			ix.Chunks = append(ix.Chunks, chunks...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ix.Chunks) == 0 {
+		return nil, fmt.Errorf("no text files found in %s", dir)
+	}
+
+	// Embed the chunks along with their file names, which often say what they are about
+	for start := 0; start < len(ix.Chunks); start += batchSize {
+		batch := ix.Chunks[start:min(start+batchSize, len(ix.Chunks))]
+		inputs := make([]string, len(batch))
+		for i, chunk := range batch {
+			inputs[i] = chunk.Source + "\n" + chunk.Text
+		}
+		vectors, err := embedder.Embed(ctx, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed %s: %w", batch[0].Source, err)
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("failed to embed %s: got %d embeddings for %d chunks", batch[0].Source, len(vectors), len(batch))
+		}
+		// Vectors are stored back to back, so they must all have the same length
+		for i, vector := range vectors {
+			if ix.Dimensions == 0 {
+				ix.Dimensions = len(vector)
+			}
+			if len(vector) != ix.Dimensions {
+				return nil, fmt.Errorf("%s has %d dimensions but earlier chunks have %d", batch[i].Citation(), len(vector), ix.Dimensions)
+			}
+			ix.vectors = append(ix.vectors, normalize(vector))
+		}
+		if progress != nil {
+			progress(len(ix.vectors), len(ix.Chunks))
+		}
+	}
+	return ix, nil
+}
+
+// readText reads a file, reporting false for binary and oversized files
+func readText(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxFileSize {
+		return "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false, nil
+	}
+	return string(data), true, nil
+}
+
+// Split divides a file into chunks of lines, skipping chunks of only whitespace
+func Split(source string, content string, options Options) []Chunk {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []Chunk
+	step := options.ChunkLines - options.Overlap
+	for start := 0; start < len(lines); start += step {
+		end := min(start+options.ChunkLines, len(lines))
+		text := strings.Join(lines[start:end], "")
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{Source: source, StartLine: start + 1, EndLine: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// Result is a chunk relevant to a question
+type Result struct {
+	Chunk
+	// Score is the cosine similarity of the chunk and the question
+	Score float64
+}
+
+// Search returns the k chunks most similar to an embedded question
+func (ix *Index) Search(vector []float32, k int) []Result {
+	vector = normalize(vector)
+	results := make([]Result, 0, len(ix.Chunks))
+	for i, chunk := range ix.Chunks {
+		var score float64
+		for j := range min(len(vector), len(ix.vectors[i])) {
+			score += float64(vector[j]) * float64(ix.vectors[i][j])
+		}
+		results = append(results, Result{chunk, score})
+	}
+	slices.SortStableFunc(results, func(a, b Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return results[:min(k, len(results))]
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized
+}
+
+// Retriever finds the chunks of an index relevant to each question
+type Retriever struct {
+	Index *Index
+	// Embedder embeds questions with the model the index was built with
+	Embedder client.Embedder
+	// TopK is the number of chunks retrieved per question
+	TopK int
+}
+
+// Retrieve returns the chunks most relevant to a question
+func (r *Retriever) Retrieve(ctx context.Context, question string) ([]Result, error) {
+	vectors, err := r.Embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors[0]) != r.Index.Dimensions {
+		return nil, fmt.Errorf("question embedding has %d dimensions but the index has %d, was it built with another model?", len(vectors[0]), r.Index.Dimensions)
+	}
+	k := r.TopK
+	if k <= 0 {
+		k = DefaultTopK
+	}
+	return r.Index.Search(vectors[0], k), nil
+}
+
+// Augment adds retrieved chunks to a question, numbered so the model can cite them
+func Augment(question string, results []Result) string {
+	if len(results) == 0 {
+		return question
+	}
+
+	var b strings.Builder
+	b.WriteString(question)
+	b.WriteString("\n\nAnswer using these excerpts where they are relevant and cite them by number, e.g. [1]. Ignore excerpts that are not relevant.")
+	for i, result := range results {
+		excerpt := attach.Attachment{Name: result.Source, Content: result.Text}
+		fmt.Fprintf(&b, "\n\n[%d] lines %d-%d of %s", i+1, result.StartLine, result.EndLine, excerpt.Fenced())
+	}
+	return b.String()
+}
+
+// ==================== Storage ====================
+
+// Path returns the directory holding the index with the given name under ~/.golms/rag/
+func Path(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid index name %q", name)
+	}
+	dir, err := config.SubDir("rag")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Save writes the index to a directory, replacing any index already there.
+// The files are written to a temporary directory that then replaces dir, so
+// an interrupted save never pairs new vectors with old metadata.
+func (ix *Index) Save(dir string) error {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := ix.write(tmp); err != nil {
+		return err
+	}
+
+	// Directories can't be renamed over, so move the old index aside first
+	old := tmp + ".old"
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// write writes the metadata and vectors files of the index to dir
+func (ix *Index) write(dir string) error {
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(dir, vectorsFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	for _, vector := range ix.vectors {
+		if err := binary.Write(w, binary.LittleEndian, vector); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, metadataFile), data, 0o644)
+}
+
+// Load reads an index saved in a directory
+func Load(dir string) (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no index found at %s, create one with golms index <dir>", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{}
+	if err := json.Unmarshal(data, ix); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", dir, err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, vectorsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	ix.vectors = make([][]float32, len(ix.Chunks))
+	for i := range ix.vectors {
+		ix.vectors[i] = make([]float32, ix.Dimensions)
+		if err := binary.Read(r, binary.LittleEndian, ix.vectors[i]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("invalid index %s: missing vectors", dir)
+			}
+			return nil, err
+		}
+	}
+	return ix, nil
+}
@@ -0,0 +1,185 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+// writeFiles creates files under a temporary directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newEmbedder(t *testing.T) client.Embedder {
+	server := fakeserver.New(t)
+	return client.NewClient(constants.Mlx_lm, "embed", server.Host(), server.Port())
+}
+
+func TestSplit(t *testing.T) {
+	content := "1\n2\n3\n4\n5\n6\n7\n"
+	chunks := Split("a.txt", content, Options{ChunkLines: 3, Overlap: 1})
+	var got []string
+	for _, chunk := range chunks {
+		got = append(got, chunk.Citation())
+	}
+	if want := "a.txt:1-3 a.txt:3-5 a.txt:5-7"; strings.Join(got, " ") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, " "))
+	}
+	if chunks[1].Text != "3\n4\n5\n" {
+		t.Errorf("unexpected chunk text %q", chunks[1].Text)
+	}
+	if chunks := Split("empty.txt", "\n\n", DefaultOptions); len(chunks) != 0 {
+		t.Errorf("expected blank files to have no chunks, got %+v", chunks)
+	}
+	if err := (Options{ChunkLines: 3, Overlap: 3}).Validate(); err == nil {
+		t.Error("expected an overlap as long as a chunk to be rejected")
+	}
+}
+
+func TestBuildAndRetrieve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"docs/deploy.md":       "# Deploying\n\nRun make release to build the docker image and push it to the registry.\n",
+		"docs/auth.md":         "# Authentication\n\nTokens are signed with the key in AUTH_SECRET and expire after an hour.\n",
+		"main.go":              "package main\n\nfunc main() {}\n",
+		".git/config":          "[core]\n",
+		"node_modules/x/a.js":  "module.exports = {}\n",
+		"assets/logo.png":      "\x89PNG\x00\x00",
+		"docs/empty/blank.txt": "   \n",
+	})
+	embedder := newEmbedder(t)
+
+	calls := 0
+	ix, err := Build(context.Background(), embedder, dir, DefaultOptions, func(done, total int) {
+		calls++
+		if done != total {
+			t.Errorf("expected a single batch, got %d of %d", done, total)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Files != 3 || len(ix.Chunks) != 3 || ix.Dimensions != fakeserver.EmbeddingDimensions || calls != 1 {
+		t.Fatalf("unexpected index of %d files, %d chunks and %d dimensions", ix.Files, len(ix.Chunks), ix.Dimensions)
+	}
+
+	// The index survives a round trip to disk
+	indexDir := filepath.Join(t.TempDir(), "docs")
+	if err := ix.Save(indexDir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(indexDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retriever := &Retriever{Index: loaded, Embedder: embedder, TopK: 1}
+	results, err := retriever.Retrieve(context.Background(), "When do auth tokens expire?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Source != "docs/auth.md" || results[0].Score <= 0 {
+		t.Errorf("expected docs/auth.md to be retrieved, got %+v", results)
+	}
+
+	prompt := Augment("When do auth tokens expire?", results)
+	for _, want := range []string{"When do auth tokens expire?", "cite them by number", "[1] lines 1-3 of `docs/auth.md`:\n```markdown", "AUTH_SECRET"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected %q in:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestBuildRejectsChangingDimensions(t *testing.T) {
+	files := map[string]string{}
+	for i := range batchSize + 1 {
+		files[fmt.Sprintf("doc%02d.txt", i)] = fmt.Sprintf("note %d\n", i)
+	}
+	dir := writeFiles(t, files)
+
+	// The second batch comes back with fewer dimensions
+	batches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		batches++
+		vector := []float32{1, 0, 0}
+		if batches > 1 {
+			vector = vector[:2]
+		}
+		resp := map[string]any{"data": []map[string]any{}}
+		for i := range req.Input {
+			resp["data"] = append(resp["data"].([]map[string]any), map[string]any{"index": i, "embedding": vector})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	embedder, err := client.NewRemoteClient(client.Endpoint{URL: server.URL}, "embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Build(context.Background(), embedder, dir, DefaultOptions, nil); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Errorf("expected the changed dimensions to be rejected, got %v", err)
+	}
+}
+
+func TestSaveReplacesIndex(t *testing.T) {
+	embedder := newEmbedder(t)
+	indexDir := filepath.Join(t.TempDir(), "notes")
+	for _, files := range []map[string]string{
+		{"a.txt": "first\n", "b.txt": "second\n"},
+		{"c.txt": "third\n"},
+	} {
+		ix, err := Build(context.Background(), embedder, writeFiles(t, files), DefaultOptions, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ix.Save(indexDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := Load(indexDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Chunks) != 1 || loaded.Chunks[0].Source != "c.txt" {
+		t.Errorf("expected only the new index, got %+v", loaded.Chunks)
+	}
+	// No temporary directories are left next to the index
+	if entries, _ := os.ReadDir(filepath.Dir(indexDir)); len(entries) != 1 {
+		t.Errorf("expected only the index directory, got %v", entries)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	if _, err := Load(t.TempDir()); err == nil || !strings.Contains(err.Error(), "golms index") {
+		t.Errorf("expected a hint to create the index, got %v", err)
+	}
+	if _, err := Path("../escape"); err == nil {
+		t.Error("expected names with separators to be rejected")
+	}
+}
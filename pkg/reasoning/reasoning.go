@@ -0,0 +1,222 @@
+package reasoning
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+const (
+	openTag  = "<think>"
+	closeTag = "</think>"
+)
+
+// Mode controls how reasoning is displayed
+type Mode string
+
+const (
+	Show     Mode = "show"
+	Collapse Mode = "collapse"
+	Hide     Mode = "hide"
+)
+
+// ContextMode controls whether reasoning is sent back to the model in later turns
+type ContextMode string
+
+const (
+	Keep  ContextMode = "keep"
+	Strip ContextMode = "strip"
+)
+
+// ParseMode validates a display mode
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case Show, Collapse, Hide:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid reasoning display mode %q (expected show, collapse or hide)", value)
+}
+
+// ParseContextMode validates a context mode
+func ParseContextMode(value string) (ContextMode, error) {
+	switch mode := ContextMode(value); mode {
+	case Keep, Strip:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid reasoning context mode %q (expected keep or strip)", value)
+}
+
+// Split separates <think> blocks from the answer in a model response.
+// An unterminated <think> block is treated as reasoning up to the end of the
+// content, and a </think> without an opening tag (emitted by models whose chat
+// template opens the block in the prompt) marks everything before it as reasoning.
+func Split(content string) (reasoning string, answer string) {
+	var thoughts []string
+	var answerBuilder strings.Builder
+
+	rest := content
+	// Closing tag with no opening tag before it
+	if closeIndex := strings.Index(rest, closeTag); closeIndex >= 0 && !strings.Contains(rest[:closeIndex], openTag) {
+		thoughts = append(thoughts, rest[:closeIndex])
+		rest = rest[closeIndex+len(closeTag):]
+	}
+
+	for {
+		openIndex := strings.Index(rest, openTag)
+		if openIndex < 0 {
+			answerBuilder.WriteString(rest)
+			break
+		}
+		answerBuilder.WriteString(rest[:openIndex])
+		rest = rest[openIndex+len(openTag):]
+
+		closeIndex := strings.Index(rest, closeTag)
+		if closeIndex < 0 {
+			// Unterminated block, e.g. generation stopped while thinking
+			thoughts = append(thoughts, rest)
+			break
+		}
+		thoughts = append(thoughts, rest[:closeIndex])
+		rest = rest[closeIndex+len(closeTag):]
+	}
+
+	return joinThoughts(thoughts), strings.TrimSpace(answerBuilder.String())
+}
+
+// FromMessage returns the reasoning and answer of an assistant message,
+// combining a separate reasoning_content field with any inline <think> blocks
+func FromMessage(message client.Message) (reasoning string, answer string) {
+	reasoning, answer = Split(message.Content)
+	return joinThoughts([]string{message.ReasoningContent, reasoning}), answer
+}
+
+// PrepareContext returns a copy of messages ready to be sent back to the model.
+// With Strip, reasoning is removed from assistant messages. With Keep, it is
+// kept inline as <think> blocks, since servers do not accept reasoning_content as input.
+func PrepareContext(messages []client.Message, mode ContextMode) []client.Message {
+	prepared := make([]client.Message, len(messages))
+	for i, message := range messages {
+		if message.Role == "assistant" {
+			switch mode {
+			case Strip:
+				_, message.Content = FromMessage(message)
+			case Keep:
+				if message.ReasoningContent != "" {
+					message.Content = openTag + message.ReasoningContent + closeTag + message.Content
+				}
+			}
+			message.ReasoningContent = ""
+		}
+		prepared[i] = message
+	}
+	return prepared
+}
+
+// Parser splits streamed content into reasoning and answer as it arrives,
+// holding back text that may be the start of a tag split across chunks. Like
+// Split, it treats a </think> without an opening tag as the end of reasoning,
+// retracting the answer streamed before it.
+type Parser struct {
+	inThink bool
+	pending string
+	// seenTag is set once a tag was found, after which a </think> can no
+	// longer end reasoning that started in the prompt
+	seenTag bool
+	// answered is the answer streamed before any tag
+	answered  strings.Builder
+	retracted string
+}
+
+// Feed consumes the next streamed delta and returns the reasoning and answer text it completes
+func (p *Parser) Feed(delta string) (reasoning string, answer string) {
+	p.pending += delta
+
+	var reasoningBuilder, answerBuilder strings.Builder
+	if !p.seenTag && !p.inThink {
+		closeIndex := strings.Index(p.pending, closeTag)
+		if closeIndex >= 0 && !strings.Contains(p.pending[:closeIndex], openTag) {
+			// A bare </think>, everything up to it was reasoning
+			p.retracted = p.answered.String()
+			p.answered.Reset()
+			reasoningBuilder.WriteString(p.retracted + p.pending[:closeIndex])
+			p.pending = p.pending[closeIndex+len(closeTag):]
+			p.seenTag = true
+		}
+	}
+	for {
+		tag := openTag
+		out := &answerBuilder
+		if p.inThink {
+			tag = closeTag
+			out = &reasoningBuilder
+		}
+
+		index := strings.Index(p.pending, tag)
+		if index < 0 {
+			// Emit everything except a possible partial tag at the end,
+			// which may also be the start of a bare </think>
+			keep := partialSuffix(p.pending, tag)
+			if !p.seenTag && !p.inThink {
+				keep = max(keep, partialSuffix(p.pending, closeTag))
+			}
+			out.WriteString(p.pending[:len(p.pending)-keep])
+			p.pending = p.pending[len(p.pending)-keep:]
+			break
+		}
+
+		out.WriteString(p.pending[:index])
+		p.pending = p.pending[index+len(tag):]
+		p.inThink = !p.inThink
+		p.seenTag = true
+	}
+
+	if !p.seenTag {
+		p.answered.WriteString(answerBuilder.String())
+	}
+	return reasoningBuilder.String(), answerBuilder.String()
+}
+
+// Retracted returns the answer text streamed so far that the last Feed found
+// to be reasoning, because of a </think> without an opening tag. The text was
+// also returned as reasoning by that Feed.
+func (p *Parser) Retracted() string {
+	retracted := p.retracted
+	p.retracted = ""
+	return retracted
+}
+
+// Flush returns any text held back at the end of the stream
+func (p *Parser) Flush() (reasoning string, answer string) {
+	pending := p.pending
+	p.pending = ""
+	if p.inThink {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// InThink reports whether the stream is currently inside a <think> block
+func (p *Parser) InThink() bool {
+	return p.inThink
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a prefix of tag
+func partialSuffix(s string, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func joinThoughts(thoughts []string) string {
+	var parts []string
+	for _, thought := range thoughts {
+		if thought = strings.TrimSpace(thought); thought != "" {
+			parts = append(parts, thought)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
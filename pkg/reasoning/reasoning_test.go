@@ -0,0 +1,160 @@
+package reasoning
+
+import (
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		wantReasoning string
+		wantAnswer    string
+	}{
+		{
+			name:          "Single think block",
+			input:         "<think>\nLet me think.\n</think>\n\nHello!",
+			wantReasoning: "Let me think.",
+			wantAnswer:    "Hello!",
+		},
+		{
+			name:          "Multiple think blocks",
+			input:         "<think>First</think>Answer<think>Second</think>",
+			wantReasoning: "First\n\nSecond",
+			wantAnswer:    "Answer",
+		},
+		{
+			name:          "No think blocks",
+			input:         "Just a normal response",
+			wantReasoning: "",
+			wantAnswer:    "Just a normal response",
+		},
+		{
+			name:          "Unterminated think block",
+			input:         "<think>Still thinking about",
+			wantReasoning: "Still thinking about",
+			wantAnswer:    "",
+		},
+		{
+			name:          "Unterminated think block after answer",
+			input:         "Answer<think>more",
+			wantReasoning: "more",
+			wantAnswer:    "Answer",
+		},
+		{
+			name:          "Closing tag without opening tag",
+			input:         "Reasoning from the template</think>\n\nAnswer",
+			wantReasoning: "Reasoning from the template",
+			wantAnswer:    "Answer",
+		},
+		{
+			name:          "Empty string",
+			input:         "",
+			wantReasoning: "",
+			wantAnswer:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasoning, answer := Split(tt.input)
+			if reasoning != tt.wantReasoning {
+				t.Errorf("Split() reasoning = %q, want %q", reasoning, tt.wantReasoning)
+			}
+			if answer != tt.wantAnswer {
+				t.Errorf("Split() answer = %q, want %q", answer, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestFromMessage_ReasoningContent(t *testing.T) {
+	message := client.Message{
+		Role:             "assistant",
+		Content:          "Answer",
+		ReasoningContent: "Separate reasoning",
+	}
+
+	reasoning, answer := FromMessage(message)
+	if reasoning != "Separate reasoning" || answer != "Answer" {
+		t.Errorf("FromMessage() = (%q, %q), want (%q, %q)", reasoning, answer, "Separate reasoning", "Answer")
+	}
+}
+
+func TestParser_SplitTags(t *testing.T) {
+	// Tags split across chunks must not leak into the answer
+	chunks := []string{"<th", "ink>reas", "oning</thi", "nk>Ans", "wer<", "3"}
+
+	parser := &Parser{}
+	var gotReasoning, gotAnswer string
+	for _, chunk := range chunks {
+		reasoning, answer := parser.Feed(chunk)
+		gotReasoning += reasoning
+		gotAnswer += answer
+	}
+	reasoning, answer := parser.Flush()
+	gotReasoning += reasoning
+	gotAnswer += answer
+
+	if gotReasoning != "reasoning" {
+		t.Errorf("Parser reasoning = %q, want %q", gotReasoning, "reasoning")
+	}
+	if gotAnswer != "Answer<3" {
+		t.Errorf("Parser answer = %q, want %q", gotAnswer, "Answer<3")
+	}
+}
+
+func TestParser_BareCloseTag(t *testing.T) {
+	// The chat template opened the block in the prompt
+	chunks := []string{"Let me ", "think", "</th", "ink>\n\nAnswer", "</"}
+
+	parser := &Parser{}
+	var gotReasoning, gotAnswer, retracted string
+	for _, chunk := range chunks {
+		reasoning, answer := parser.Feed(chunk)
+		retracted += parser.Retracted()
+		gotReasoning += reasoning
+		gotAnswer += answer
+	}
+	reasoning, answer := parser.Flush()
+	gotReasoning += reasoning
+	gotAnswer += answer
+
+	if retracted != "Let me think" {
+		t.Errorf("Parser retracted = %q, want %q", retracted, "Let me think")
+	}
+	if gotReasoning != "Let me think" {
+		t.Errorf("Parser reasoning = %q, want %q", gotReasoning, "Let me think")
+	}
+	// The retracted text was streamed as answer before the tag arrived
+	if want := "Let me think\n\nAnswer</"; gotAnswer != want {
+		t.Errorf("Parser answer = %q, want %q", gotAnswer, want)
+	}
+}
+
+func TestPrepareContext(t *testing.T) {
+	messages := []client.Message{
+		{Role: "user", Content: "<think>user text is untouched</think>Hi"},
+		{Role: "assistant", Content: "<think>inline</think>Hello", ReasoningContent: "separate"},
+	}
+
+	stripped := PrepareContext(messages, Strip)
+	if stripped[0].Content != messages[0].Content {
+		t.Errorf("Strip changed user message to %q", stripped[0].Content)
+	}
+	if stripped[1].Content != "Hello" || stripped[1].ReasoningContent != "" {
+		t.Errorf("Strip assistant message = %+v, want content %q and no reasoning", stripped[1], "Hello")
+	}
+
+	kept := PrepareContext(messages, Keep)
+	if want := "<think>separate</think><think>inline</think>Hello"; kept[1].Content != want || kept[1].ReasoningContent != "" {
+		t.Errorf("Keep assistant message = %+v, want content %q and no reasoning", kept[1], want)
+	}
+
+	// The original history must not be modified
+	if messages[1].ReasoningContent != "separate" {
+		t.Errorf("PrepareContext modified its input")
+	}
+}
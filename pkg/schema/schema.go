@@ -0,0 +1,401 @@
+// Package schema validates JSON values against a JSON Schema.
+//
+// The commonly used subset of the specification is supported: type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not and local
+// $ref pointers such as #/$defs/name. Other keywords are ignored.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrNoJSON = errors.New("response does not contain JSON")
+
+// Schema is a parsed JSON Schema document
+type Schema struct {
+	// Raw is the schema as it was loaded, for sending to model servers
+	Raw  json.RawMessage
+	root any
+}
+
+// ValidationError lists every way a value fails to match a schema
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// Load reads a schema from a file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a schema document
+func Parse(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, errors.New("schema must be a JSON object")
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, err
+	}
+	return &Schema{Raw: compact.Bytes(), root: root}, nil
+}
+
+// Validate checks data, a JSON document, against the schema
+func (s *Schema) Validate(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	v := &validator{root: s.root}
+	v.validate(s.root, value, "$")
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// Extract finds the JSON document in a model response, which may be wrapped
+// in a markdown code fence or surrounded by prose
+func Extract(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+
+	// Prefer the contents of a fenced block
+	if start := strings.Index(text, "```"); start >= 0 {
+		rest := text[start+3:]
+		if newline := strings.IndexByte(rest, '\n'); newline >= 0 {
+			rest = rest[newline+1:]
+			if end := strings.Index(rest, "```"); end >= 0 {
+				text = strings.TrimSpace(rest[:end])
+			}
+		}
+	}
+	if json.Valid([]byte(text)) {
+		return []byte(text), nil
+	}
+
+	// Otherwise decode from the first opening brace or bracket
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return nil, ErrNoJSON
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(text[start:])).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoJSON, err)
+	}
+	return raw, nil
+}
+
+type validator struct {
+	root     any
+	problems []string
+	depth    int
+}
+
+func (v *validator) fail(path string, format string, args ...any) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validate records every problem with value at path
+func (v *validator) validate(schema any, value any, path string) {
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]any:
+		v.validateObject(schema, value, path)
+	}
+}
+
+// matches reports whether value is valid against schema without recording problems
+func (v *validator) matches(schema any, value any) bool {
+	sub := &validator{root: v.root, depth: v.depth}
+	sub.validate(schema, value, "")
+	return len(sub.problems) == 0
+}
+
+func (v *validator) validateObject(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		// Guard against schemas that refer to themselves without consuming input
+		if v.depth > 64 {
+			v.fail(path, "schema nesting too deep")
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+	}
+
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		v.fail(path, "expected %s, got %s", describeTypes(types), typeName(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+			v.fail(path, "must be one of %s", encode(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		v.fail(path, "must be %s", encode(constant))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateProperties(schema, value, path)
+	case []any:
+		v.validateItems(schema, value, path)
+	case string:
+		v.validateString(schema, value, path)
+	case float64:
+		v.validateNumber(schema, value, path)
+	}
+
+	v.validateCombinators(schema, value, path)
+}
+
+func (v *validator) validateProperties(schema map[string]any, value map[string]any, path string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := value[name]; !present {
+					v.fail(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// Visit properties in a stable order so errors are reproducible
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		propertyPath := path + "." + name
+		if propertySchema, ok := properties[name]; ok {
+			v.validate(propertySchema, value[name], propertyPath)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			v.fail(path, "unexpected property %q", name)
+			continue
+		}
+		v.validate(additional, value[name], propertyPath)
+	}
+}
+
+func (v *validator) validateItems(schema map[string]any, value []any, path string) {
+	if minItems, ok := number(schema["minItems"]); ok && float64(len(value)) < minItems {
+		v.fail(path, "must have at least %g items, has %d", minItems, len(value))
+	}
+	if maxItems, ok := number(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		v.fail(path, "must have at most %g items, has %d", maxItems, len(value))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := range i {
+				if reflect.DeepEqual(value[i], value[j]) {
+					v.fail(path, "items %d and %d are equal", j, i)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) validateString(schema map[string]any, value string, path string) {
+	length := float64(utf8.RuneCountInString(value))
+	if minLength, ok := number(schema["minLength"]); ok && length < minLength {
+		v.fail(path, "must be at least %g characters", minLength)
+	}
+	if maxLength, ok := number(schema["maxLength"]); ok && length > maxLength {
+		v.fail(path, "must be at most %g characters", maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q in schema", pattern)
+		} else if !re.MatchString(value) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]any, value float64, path string) {
+	if minimum, ok := number(schema["minimum"]); ok && value < minimum {
+		v.fail(path, "must be at least %g", minimum)
+	}
+	if maximum, ok := number(schema["maximum"]); ok && value > maximum {
+		v.fail(path, "must be at most %g", maximum)
+	}
+	if minimum, ok := number(schema["exclusiveMinimum"]); ok && value <= minimum {
+		v.fail(path, "must be greater than %g", minimum)
+	}
+	if maximum, ok := number(schema["exclusiveMaximum"]); ok && value >= maximum {
+		v.fail(path, "must be less than %g", maximum)
+	}
+}
+
+func (v *validator) validateCombinators(schema map[string]any, value any, path string) {
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		if !slices.ContainsFunc(anyOf, func(sub any) bool { return v.matches(sub, value) }) {
+			v.fail(path, "does not match any of the allowed schemas")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if v.matches(sub, value) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matches %d", matched)
+		}
+	}
+	if not, ok := schema["not"]; ok && v.matches(not, value) {
+		v.fail(path, "matches a schema it must not match")
+	}
+}
+
+// resolve follows a local JSON pointer such as #/$defs/item
+func (v *validator) resolve(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q, only local references are supported", ref)
+	}
+	current := v.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return current, nil
+}
+
+// matchesType checks value against a type keyword, a name or a list of names
+func matchesType(types any, value any) bool {
+	switch types := types.(type) {
+	case string:
+		return isType(types, value)
+	case []any:
+		return slices.ContainsFunc(types, func(t any) bool {
+			name, _ := t.(string)
+			return isType(name, value)
+		})
+	}
+	return true
+}
+
+func isType(name string, value any) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeName(value) == name
+	}
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+func describeTypes(types any) string {
+	if list, ok := types.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, t := range list {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+func number(value any) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func encode(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}, "maxItems": 2}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"tag": {"type": "string", "pattern": "^[a-z]+$"}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		problem  string
+	}{
+		{"valid", `{"name": "Ada", "age": 36, "role": "admin", "tags": ["math"]}`, ""},
+		{"missing required", `{"name": "Ada"}`, `missing required property "age"`},
+		{"wrong type", `{"name": "Ada", "age": "36"}`, "$.age: expected integer, got string"},
+		{"not an integer", `{"name": "Ada", "age": 36.5}`, "expected integer"},
+		{"below minimum", `{"name": "Ada", "age": -1}`, "must be at least 0"},
+		{"not in enum", `{"name": "Ada", "age": 1, "role": "root"}`, "must be one of"},
+		{"extra property", `{"name": "Ada", "age": 1, "email": "a@b.c"}`, `unexpected property "email"`},
+		{"ref pattern", `{"name": "Ada", "age": 1, "tags": ["Math"]}`, "$.tags[0]: must match pattern"},
+		{"too many items", `{"name": "Ada", "age": 1, "tags": ["a", "b", "c"]}`, "at most 2 items"},
+		{"root type", `[1, 2]`, "$: expected object, got array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.document))
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("error %q does not mention %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s, err := Parse([]byte(`{"oneOf": [{"type": "string"}, {"type": "integer"}], "not": {"const": 0}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for document, valid := range map[string]bool{`"a"`: true, `3`: true, `0`: false, `true`: false} {
+		if err := s.Validate([]byte(document)); (err == nil) != valid {
+			t.Errorf("Validate(%s) = %v, want valid %v", document, err, valid)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"Here you go:\n{\"a\": [1, 2]} Hope this helps!", `{"a": [1, 2]}`},
+		{"The list is [1, 2, 3].", `[1, 2, 3]`},
+	}
+	for _, tt := range tests {
+		got, err := Extract(tt.text)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Extract(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	if _, err := Extract("no json here"); !errors.Is(err, ErrNoJSON) {
+		t.Errorf("expected ErrNoJSON, got %v", err)
+	}
+}
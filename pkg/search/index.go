@@ -0,0 +1,390 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/changminbark/golms/pkg/config"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+const (
+	// maxEntrySize bounds a line of the message log, messages may hold whole files
+	maxEntrySize = 64 * 1024 * 1024
+	// checkpointInterval is the number of messages added to the log before
+	// the postings are saved again
+	checkpointInterval = 64
+)
+
+// Entry is an indexed message
+type Entry struct {
+	Session string    `json:"session"`
+	Turn    int       `json:"turn"`
+	Model   string    `json:"model"`
+	Role    string    `json:"role"`
+	Time    time.Time `json:"time"`
+	Content string    `json:"content"`
+}
+
+// doc is an indexed message without its content, which stays in the log
+type doc struct {
+	Session string
+	Turn    int
+	Model   string
+	Role    string
+	Time    time.Time
+	// Offset and Size locate the message's line in the log
+	Offset int64
+	Size   int
+	// Length is the number of words in the message
+	Length int
+}
+
+// posting records how often a word occurs in a message
+type posting struct {
+	Doc   int
+	Count int
+}
+
+// postings is the inverted index of the log up to Size bytes
+type postings struct {
+	Size  int64
+	Docs  []doc
+	Words map[string][]posting
+}
+
+// Index is an inverted index of every message written to a session, kept in
+// ~/.golms/search/. Messages are appended to a log holding their content and
+// the postings of their words are kept in memory, saved next to the log every
+// checkpointInterval messages. Opening the index loads the saved postings and
+// indexes the messages logged since. Sessions only ever gain turns, so each
+// session is indexed up to its highest turn ID and later saves add the turns
+// after it.
+type Index struct {
+	dir string
+
+	mu sync.Mutex
+	// index covers the log up to index.Size, loaded on first use
+	index *postings
+	// log is the log file the index was read from, to notice when it is replaced
+	log os.FileInfo
+	// saved is the number of messages in the saved postings
+	saved int
+	// indexed maps session IDs to their highest indexed turn ID
+	indexed map[string]int
+}
+
+// NewIndex opens the default index under ~/.golms/search/
+func NewIndex() (*Index, error) {
+	dir, err := config.SubDir("search")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open search index: %w", err)
+	}
+	return &Index{dir: dir}, nil
+}
+
+func (ix *Index) logPath() string      { return path.Join(ix.dir, "messages.jsonl") }
+func (ix *Index) postingsPath() string { return path.Join(ix.dir, "postings.gob") }
+func (ix *Index) lockPath() string     { return path.Join(ix.dir, "lock") }
+
+// Update adds the turns of a session that are not indexed yet
+func (ix *Index) Update(s *session.Session) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	unlock, err := lockFile(ix.lockPath())
+	if err != nil {
+		return fmt.Errorf("failed to lock search index: %w", err)
+	}
+	defer unlock()
+
+	if err := ix.refresh(); err != nil {
+		return err
+	}
+	var entries []Entry
+	for _, turn := range s.Turns {
+		if turn.ID > ix.indexed[s.ID] {
+			entries = append(entries, newEntry(s, turn))
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return ix.append(entries)
+}
+
+// Rebuild replaces the index with the turns of the sessions returned by list,
+// which is called with the index locked so no chat can save a turn in between
+func (ix *Index) Rebuild(list func() ([]*session.Session, error)) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	unlock, err := lockFile(ix.lockPath())
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock search index: %w", err)
+	}
+	defer unlock()
+
+	sessions, err := list()
+	if err != nil {
+		return 0, err
+	}
+
+	tmpPath := ix.logPath() + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	index := &postings{Words: make(map[string][]posting)}
+	w := bufio.NewWriter(file)
+	for _, s := range sessions {
+		for _, turn := range s.Turns {
+			entry := newEntry(s, turn)
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return 0, err
+			}
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return 0, err
+			}
+			index.add(entry, index.Size, len(line))
+			index.Size += int64(len(line))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	// Without saved postings the log is indexed from the start, so removing
+	// them first keeps an interrupted rebuild from pairing them with the new log
+	if err := os.Remove(ix.postingsPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, ix.logPath()); err != nil {
+		return 0, err
+	}
+	if ix.log, err = os.Stat(ix.logPath()); err != nil {
+		return 0, err
+	}
+	ix.setIndex(index)
+	if err := ix.checkpoint(); err != nil {
+		return 0, err
+	}
+	return len(index.Docs), nil
+}
+
+// newEntry converts a turn to an index entry, leaving out model reasoning
+func newEntry(s *session.Session, turn session.Turn) Entry {
+	_, content := reasoning.FromMessage(turn.Message)
+	for _, call := range turn.Message.ToolCalls {
+		content += "\n" + call.Function.Name + " " + call.Function.Arguments
+	}
+	return Entry{
+		Session: s.ID,
+		Turn:    turn.ID,
+		Model:   s.Model,
+		Role:    turn.Message.Role,
+		Time:    turn.CreatedAt,
+		Content: strings.TrimSpace(content),
+	}
+}
+
+// append writes entries to the end of the log in a single write, so
+// concurrent chats never interleave their lines, and indexes them
+func (ix *Index) append(entries []Entry) error {
+	var buf bytes.Buffer
+	if ix.log != nil && ix.log.Size() > ix.index.Size {
+		// Finish a line left incomplete by an interrupted write
+		buf.WriteByte('\n')
+	}
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(ix.logPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+
+	if err := ix.refresh(); err != nil {
+		return err
+	}
+	if len(ix.index.Docs)-ix.saved >= checkpointInterval {
+		return ix.checkpoint()
+	}
+	return nil
+}
+
+// refresh brings the index up to date with the log, loading it again when
+// another process has replaced the log
+func (ix *Index) refresh() error {
+	info, err := os.Stat(ix.logPath())
+	if errors.Is(err, os.ErrNotExist) {
+		ix.log = nil
+		ix.setIndex(&postings{Words: make(map[string][]posting)})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ix.index == nil || ix.log == nil || !os.SameFile(ix.log, info) || info.Size() < ix.index.Size {
+		if err := ix.load(); err != nil {
+			return err
+		}
+	}
+	ix.log = info
+	return ix.indexLog()
+}
+
+// load reads the saved postings, if they still match the log
+func (ix *Index) load() error {
+	index := &postings{Words: make(map[string][]posting)}
+	ix.saved = 0
+	file, err := os.Open(ix.postingsPath())
+	if err == nil {
+		var saved postings
+		decodeErr := gob.NewDecoder(bufio.NewReader(file)).Decode(&saved)
+		file.Close()
+		// Postings beyond the end of the log belong to a log that was replaced
+		if info, err := os.Stat(ix.logPath()); decodeErr == nil && err == nil && saved.Size <= info.Size() {
+			if saved.Words == nil {
+				saved.Words = make(map[string][]posting)
+			}
+			index = &saved
+			ix.saved = len(saved.Docs)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	ix.setIndex(index)
+	return nil
+}
+
+// indexLog indexes the complete lines of the log after the indexed part,
+// skipping lines that cannot be decoded
+func (ix *Index) indexLog() error {
+	file, err := os.Open(ix.logPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(ix.index.Size, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// Read long lines in full
+			rest, restErr := r.ReadBytes('\n')
+			line, err = append(bytes.Clone(line), rest...), restErr
+		}
+		if errors.Is(err, io.EOF) {
+			// An incomplete line is still being written or was interrupted
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entry Entry
+		if len(line) <= maxEntrySize && json.Unmarshal(line, &entry) == nil {
+			ix.index.add(entry, ix.index.Size, len(line))
+			ix.indexed[entry.Session] = max(ix.indexed[entry.Session], entry.Turn)
+		}
+		ix.index.Size += int64(len(line))
+	}
+}
+
+// checkpoint saves the postings next to the log
+func (ix *Index) checkpoint() error {
+	tmpPath := ix.postingsPath() + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	w := bufio.NewWriter(file)
+	err = gob.NewEncoder(w).Encode(ix.index)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, ix.postingsPath())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save search index: %w", err)
+	}
+	ix.saved = len(ix.index.Docs)
+	return nil
+}
+
+// setIndex replaces the index and the highest indexed turn of every session
+func (ix *Index) setIndex(index *postings) {
+	ix.index = index
+	ix.indexed = make(map[string]int)
+	for _, d := range index.Docs {
+		ix.indexed[d.Session] = max(ix.indexed[d.Session], d.Turn)
+	}
+}
+
+// add indexes an entry found at offset in the log
+func (p *postings) add(entry Entry, offset int64, size int) {
+	words := Tokenize(entry.Content)
+	id := len(p.Docs)
+	p.Docs = append(p.Docs, doc{
+		Session: entry.Session,
+		Turn:    entry.Turn,
+		Model:   entry.Model,
+		Role:    entry.Role,
+		Time:    entry.Time,
+		Offset:  offset,
+		Size:    size,
+		Length:  len(words),
+	})
+	counts := make(map[string]int)
+	for _, word := range words {
+		counts[word]++
+	}
+	for word, count := range counts {
+		p.Words[word] = append(p.Words[word], posting{id, count})
+	}
+}
+
+// readContent reads the content of an indexed message from the log
+func readContent(log io.ReaderAt, d doc) (string, error) {
+	var entry Entry
+	if err := json.NewDecoder(io.NewSectionReader(log, d.Offset, int64(d.Size))).Decode(&entry); err != nil {
+		return "", fmt.Errorf("failed to read message from search index: %w", err)
+	}
+	return entry.Content, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package search
+
+// lockFile does nothing where flock is not available, leaving only the
+// Index's own mutex
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,301 @@
+// Package search keeps an inverted index of the messages of saved sessions
+// for full-text search
+package search
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+const (
+	// DefaultLimit is the number of results returned when a query sets none
+	DefaultLimit  = 20
+	snippetLength = 160
+)
+
+// Query is a search with optional filters
+type Query struct {
+	// Text holds the words every match must contain. Quoted phrases must
+	// appear as written and words ending in * match any word they start.
+	Text string
+	// Model only matches messages of sessions with this LLM
+	Model string
+	// Role only matches messages with this role
+	Role string
+	// Since and Until bound when messages were written, when non-zero
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of results, DefaultLimit when 0
+	Limit int
+}
+
+// Result is a message matching a query
+type Result struct {
+	Session string    `json:"session"`
+	Turn    int       `json:"turn"`
+	Model   string    `json:"model"`
+	Role    string    `json:"role"`
+	Time    time.Time `json:"time"`
+	Snippet string    `json:"snippet"`
+	Score   float64   `json:"score"`
+}
+
+// Search returns the messages matching a query, best matches first
+func (ix *Index) Search(q Query) ([]Result, error) {
+	terms, err := parseQuery(q.Text)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("empty search query")
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	unlock, err := lockFile(ix.lockPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock search index: %w", err)
+	}
+	defer unlock()
+	if err := ix.refresh(); err != nil {
+		return nil, err
+	}
+	if len(ix.index.Docs) == 0 {
+		return nil, nil
+	}
+	log, err := os.Open(ix.logPath())
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+
+	// Rank with BM25 over the messages passing the filters
+	docs := ix.index.Docs
+	filtered := make([]bool, len(docs))
+	count, totalLength := 0, 0
+	for i, d := range docs {
+		if filtered[i] = q.matches(d); filtered[i] {
+			count++
+			totalLength += d.Length
+		}
+	}
+	// counts holds the occurrences of each term in the messages containing it
+	counts := make([]map[int]int, len(terms))
+	for i, term := range terms {
+		if counts[i], err = ix.termCounts(log, term, filtered); err != nil {
+			return nil, err
+		}
+	}
+
+	const k1, b = 1.2, 0.75
+	avgLength := float64(totalLength) / float64(max(count, 1))
+	type match struct {
+		doc   doc
+		score float64
+	}
+	var matches []match
+	for id := range counts[0] {
+		// Every term must occur in a match
+		if slices.ContainsFunc(counts, func(termCounts map[int]int) bool { return termCounts[id] == 0 }) {
+			continue
+		}
+		score := 0.0
+		for _, termCounts := range counts {
+			frequency := float64(len(termCounts))
+			idf := math.Log(1 + (float64(count)-frequency+0.5)/(frequency+0.5))
+			tf := float64(termCounts[id])
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(docs[id].Length)/avgLength))
+		}
+		matches = append(matches, match{docs[id], score})
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.score != b.score {
+			return cmp.Compare(b.score, a.score)
+		}
+		if c := b.doc.Time.Compare(a.doc.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.doc.Offset, b.doc.Offset)
+	})
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	results := make([]Result, 0, min(limit, len(matches)))
+	for _, m := range matches[:min(limit, len(matches))] {
+		// Only the messages shown are read from the log
+		content, err := readContent(log, m.doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Result{
+			Session: m.doc.Session,
+			Turn:    m.doc.Turn,
+			Model:   m.doc.Model,
+			Role:    m.doc.Role,
+			Time:    m.doc.Time,
+			Snippet: Snippet(content, terms),
+			Score:   m.score,
+		})
+	}
+	return results, nil
+}
+
+// termCounts counts the occurrences of a term in every message passing the
+// filters that contains it. Words are looked up in the postings, while phrases
+// are checked in the messages containing all of their words.
+func (ix *Index) termCounts(log io.ReaderAt, term []string, filtered []bool) (map[int]int, error) {
+	counts := ix.wordCounts(term[0], filtered)
+	if len(term) == 1 {
+		return counts, nil
+	}
+	for _, word := range term[1:] {
+		next := ix.wordCounts(word, filtered)
+		for id := range counts {
+			if _, ok := next[id]; !ok {
+				delete(counts, id)
+			}
+		}
+	}
+	for id := range counts {
+		content, err := readContent(log, ix.index.Docs[id])
+		if err != nil {
+			return nil, err
+		}
+		words := Tokenize(content)
+		n := 0
+		for start := range words {
+			if matchesAt(term, words, start) {
+				n++
+			}
+		}
+		if n > 0 {
+			counts[id] = n
+		} else {
+			delete(counts, id)
+		}
+	}
+	return counts, nil
+}
+
+// wordCounts returns the occurrences of a word, or of every word starting
+// with a prefix ending in *, in the messages passing the filters
+func (ix *Index) wordCounts(word string, filtered []bool) map[int]int {
+	counts := make(map[int]int)
+	add := func(postings []posting) {
+		for _, p := range postings {
+			if filtered[p.Doc] {
+				counts[p.Doc] += p.Count
+			}
+		}
+	}
+	prefix, ok := strings.CutSuffix(word, "*")
+	if !ok {
+		add(ix.index.Words[word])
+		return counts
+	}
+	for w, postings := range ix.index.Words {
+		if strings.HasPrefix(w, prefix) {
+			add(postings)
+		}
+	}
+	return counts
+}
+
+// matches reports whether a message passes the query's filters
+func (q Query) matches(d doc) bool {
+	return (q.Model == "" || d.Model == q.Model) &&
+		(q.Role == "" || d.Role == q.Role) &&
+		(q.Since.IsZero() || !d.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || d.Time.Before(q.Until))
+}
+
+// parseQuery splits query text into terms, each a single word, a word
+// prefix ending in * or a phrase of several words
+func parseQuery(text string) ([][]string, error) {
+	fields, err := client.SplitQuoted(text)
+	if err != nil {
+		return nil, err
+	}
+	var terms [][]string
+	for _, field := range fields {
+		words := Tokenize(field)
+		if len(words) == 0 {
+			continue
+		}
+		if strings.HasSuffix(field, "*") {
+			words[len(words)-1] += "*"
+		}
+		terms = append(terms, words)
+	}
+	return terms, nil
+}
+
+// matchesAt reports whether the term's words occur at words[start:]
+func matchesAt(term []string, words []string, start int) bool {
+	if start+len(term) > len(words) {
+		return false
+	}
+	for i, want := range term {
+		word := words[start+i]
+		if prefix, ok := strings.CutSuffix(want, "*"); ok {
+			if !strings.HasPrefix(word, prefix) {
+				return false
+			}
+		} else if word != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Tokenize splits text into lowercase words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Snippet returns the part of content around the first match of any term,
+// on a single line
+func Snippet(content string, terms [][]string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	lower := strings.ToLower(content)
+
+	first := -1
+	for _, term := range terms {
+		word := strings.TrimSuffix(term[0], "*")
+		if i := strings.Index(lower, word); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	// Lowercasing maps runes one to one, so rune offsets are the same in both
+	runes := []rune(content)
+	start := 0
+	if first > 0 {
+		// Start a little before the match
+		start = max(utf8.RuneCountInString(lower[:first])-snippetLength/4, 0)
+	}
+	end := min(start+snippetLength, len(runes))
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
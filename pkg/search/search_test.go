@@ -0,0 +1,236 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+func newTestIndex(t *testing.T) *Index {
+	return &Index{dir: t.TempDir()}
+}
+
+func newSession(llm string, messages ...client.Message) *session.Session {
+	s := session.New("mlx_lm", llm)
+	for _, message := range messages {
+		s.Append(message, nil, 0)
+	}
+	return s
+}
+
+func count(t *testing.T, ix *Index) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if err := ix.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	return len(ix.index.Docs)
+}
+
+func TestUpdateIsIncremental(t *testing.T) {
+	ix := newTestIndex(t)
+	s := newSession("qwen3-8b",
+		client.Message{Role: "user", Content: "How do I restart docker?"},
+		client.Message{Role: "assistant", Content: "<think>Easy one.</think>Run systemctl restart docker."},
+	)
+	if err := ix.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	s.Append(client.Message{Role: "user", Content: "Thanks"}, nil, 0)
+	if err := ix.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, ix); n != 3 {
+		t.Fatalf("expected 3 entries, got %d", n)
+	}
+
+	// A fresh index picks up where the file left off
+	reopened := &Index{dir: ix.dir}
+	if err := reopened.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, reopened); n != 3 {
+		t.Fatalf("expected 3 entries after reopening, got %d", n)
+	}
+
+	// Reasoning is not indexed
+	if results, _ := ix.Search(Query{Text: "easy"}); len(results) != 0 {
+		t.Errorf("expected reasoning to be left out, got %+v", results)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := newTestIndex(t)
+	first := newSession("qwen3-8b",
+		client.Message{Role: "user", Content: "The docker daemon says connection refused"},
+		client.Message{Role: "assistant", Content: "Check that the docker service is running."},
+	)
+	second := newSession("llama3",
+		client.Message{Role: "user", Content: "Refused connection from my database"},
+	)
+	for _, s := range []*session.Session{first, second} {
+		if err := ix.Update(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all words", Query{Text: "connection refused"}, []string{first.ID + "/1", second.ID + "/1"}},
+		{"phrase", Query{Text: `"connection refused"`}, []string{first.ID + "/1"}},
+		{"prefix", Query{Text: "dock*"}, []string{first.ID + "/1", first.ID + "/2"}},
+		{"model", Query{Text: "connection", Model: "llama3"}, []string{second.ID + "/1"}},
+		{"role", Query{Text: "docker", Role: "assistant"}, []string{first.ID + "/2"}},
+		{"since", Query{Text: "docker", Since: time.Now().Add(time.Hour)}, nil},
+		{"until", Query{Text: "docker", Until: time.Now().Add(-time.Hour)}, nil},
+		{"no match", Query{Text: "kubernetes"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := ix.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, fmt.Sprintf("%s/%d", result.Session, result.Turn))
+			}
+			// Ranking is covered separately
+			slices.Sort(got)
+			slices.Sort(tt.want)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if results, _ := ix.Search(Query{Text: "docker", Limit: 1}); len(results) != 1 {
+		t.Errorf("expected a single result, got %+v", results)
+	}
+	if _, err := ix.Search(Query{Text: "  "}); err == nil {
+		t.Error("expected an empty query to be rejected")
+	}
+}
+
+func TestSearchRanksFrequentTermsFirst(t *testing.T) {
+	ix := newTestIndex(t)
+	s := newSession("qwen3-8b",
+		client.Message{Role: "user", Content: "Tell me about goroutines in a long question about many other things entirely"},
+		client.Message{Role: "assistant", Content: "Goroutines are cheap. Start goroutines with go."},
+	)
+	if err := ix.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ix.Search(Query{Text: "goroutines"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Turn != 2 || results[0].Score <= results[1].Score {
+		t.Errorf("expected the answer to rank first, got %+v", results)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	content := strings.Repeat("filler ", 100) + "the needle\nis here " + strings.Repeat("more ", 100)
+	snippet := Snippet(content, [][]string{{"needle"}})
+	if !strings.Contains(snippet, "the needle is here") || !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("unexpected snippet %q", snippet)
+	}
+	if snippet := Snippet("Short ünïcode needle", [][]string{{"needle"}}); snippet != "Short ünïcode needle" {
+		t.Errorf("unexpected snippet %q", snippet)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	ix := newTestIndex(t)
+	s := newSession("qwen3-8b", client.Message{Role: "user", Content: "hello"})
+	ix.Update(s)
+	ix.Update(s)
+
+	n, err := ix.Rebuild(func() ([]*session.Session, error) {
+		return []*session.Session{s, newSession("llama3", client.Message{Role: "user", Content: "hi"})}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || count(t, ix) != 2 {
+		t.Errorf("expected 2 entries, got %d and %d", n, count(t, ix))
+	}
+	// Later updates still skip indexed turns
+	ix.Update(s)
+	if count(t, ix) != 2 {
+		t.Errorf("expected no new entries after rebuilding, got %d", count(t, ix))
+	}
+}
+
+func TestRebuildByAnotherProcess(t *testing.T) {
+	chat := newTestIndex(t)
+	s := newSession("qwen3-8b", client.Message{Role: "user", Content: "first question"})
+	if err := chat.Update(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sessions were listed before the chat saved its second turn
+	reindex := &Index{dir: chat.dir}
+	listed := newSession("qwen3-8b")
+	listed.ID = s.ID
+	listed.Turns = slices.Clone(s.Turns)
+	s.Append(client.Message{Role: "assistant", Content: "first answer"}, nil, 0)
+	if _, err := reindex.Rebuild(func() ([]*session.Session, error) { return []*session.Session{listed}, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The chat notices the replaced index and adds the turn it is missing
+	if err := chat.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := reindex.Search(Query{Text: "answer"}); err != nil || len(results) != 1 {
+		t.Errorf("expected the answer to be indexed, got %+v (%v)", results, err)
+	}
+}
+
+func TestSavedPostings(t *testing.T) {
+	ix := newTestIndex(t)
+	for i := range checkpointInterval + 3 {
+		s := newSession("qwen3-8b", client.Message{Role: "user", Content: fmt.Sprintf("question number%d", i)})
+		if err := ix.Update(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(ix.postingsPath()); err != nil {
+		t.Fatalf("expected the postings to be saved: %v", err)
+	}
+
+	// An interrupted write leaves an incomplete line, which later appends finish
+	file, err := os.OpenFile(ix.logPath(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"session":"broken","content":"quest`)
+	file.Close()
+
+	// A fresh index loads the saved postings and indexes the rest of the log
+	reopened := &Index{dir: ix.dir}
+	if err := reopened.Update(newSession("llama3", client.Message{Role: "user", Content: "last question"})); err != nil {
+		t.Fatal(err)
+	}
+	if reopened.saved != checkpointInterval {
+		t.Errorf("expected %d saved messages to be loaded, got %d", checkpointInterval, reopened.saved)
+	}
+	results, err := reopened.Search(Query{Text: "question", Limit: 100})
+	if err != nil || len(results) != checkpointInterval+4 {
+		t.Fatalf("expected %d results, got %d (%v)", checkpointInterval+4, len(results), err)
+	}
+	if results, _ := reopened.Search(Query{Text: "number1*"}); len(results) != 11 {
+		t.Errorf("expected the prefix to match 11 messages, got %d", len(results))
+	}
+}
@@ -3,15 +3,25 @@ package server
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"os/exec"
 	"regexp"
 	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/changminbark/golms/pkg/constants"
 	"github.com/changminbark/golms/pkg/discovery"
 )
 
+// defaultPort is the first port tried when starting a model server
+const defaultPort = 8080
+
+// Output receives progress messages printed while starting model servers
+var Output io.Writer = os.Stdout
+
 type ModelServerManager interface {
 	IsAvailable() bool
 	IsRunning() (bool, int)
@@ -41,6 +51,14 @@ func NewServerManager(model_server string, llm string) ModelServerManager {
 				port:        0,
 			},
 		}
+	case constants.Mlx_vlm:
+		return &MlxLMServerManager{
+			BaseModelServerManager: BaseModelServerManager{
+				modelServer: constants.Mlx_vlm,
+				llm:         llm,
+				port:        0,
+			},
+		}
 	case constants.Ollama:
 		return nil
 	default:
@@ -83,3 +101,64 @@ func getPortHelper(mgr ModelServerManager, knownPort int) (int, error) {
 	return port, nil
 }
 
+// freePort returns the first port from start that nothing is listening on
+func freePort(start int) (int, error) {
+	for port := start; port < start+100; port++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(constants.Localhost, strconv.Itoa(port)))
+		if err == nil {
+			listener.Close()
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port found between %d and %d", start, start+99)
+}
+
+// Process is a running model server
+type Process struct {
+	PID         int
+	CommandLine string
+	// Args is the process's argv, or nil where /proc is not available
+	Args []string
+}
+
+// Processes returns every running server of a model server, which may be
+// several when servers for different LLMs run side by side. Ollama serves all
+// of its models from one process and is not managed, so none are returned.
+func Processes(modelServer string) []Process {
+	switch modelServer {
+	case constants.Mlx_lm, constants.Mlx_vlm:
+		return pythonProcesses(modelServer + ".server")
+	default:
+		return nil
+	}
+}
+
+// ServesModel reports whether the server loads the model at modelPath with
+// its --model argument. The whole argument is compared, so ~/golms/mlx_lm/qwen
+// doesn't match a server of ~/golms/mlx_lm/qwen-7b. Without the argv, the
+// path must follow --model on the command line and be followed by its end or
+// another flag, since ps doesn't quote paths with spaces.
+func (p Process) ServesModel(modelPath string) bool {
+	if p.Args != nil {
+		for i, arg := range p.Args {
+			if arg == "--model="+modelPath || (arg == "--model" && i+1 < len(p.Args) && p.Args[i+1] == modelPath) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, argument := range []string{"--model " + modelPath, "--model=" + modelPath} {
+		for start := 0; ; start++ {
+			i := strings.Index(p.CommandLine[start:], argument)
+			if i < 0 {
+				break
+			}
+			start += i
+			rest := p.CommandLine[start+len(argument):]
+			if (start == 0 || p.CommandLine[start-1] == ' ') && (rest == "" || strings.HasPrefix(rest, " -")) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestServesModel(t *testing.T) {
+	const modelPath = "/home/me/golms/mlx_lm/qwen"
+	const spacedPath = "/home/me/golms/mlx_lm/My Model"
+	tests := []struct {
+		name      string
+		process   Process
+		modelPath string
+		want      bool
+	}{
+		{"command line", Process{CommandLine: "python -m mlx_lm.server --model /home/me/golms/mlx_lm/qwen --port 8080"}, modelPath, true},
+		{"command line with =", Process{CommandLine: "python -m mlx_lm.server --port 8080 --model=/home/me/golms/mlx_lm/qwen"}, modelPath, true},
+		{"longer path", Process{CommandLine: "python -m mlx_lm.server --model /home/me/golms/mlx_lm/qwen-7b --port 8080"}, modelPath, false},
+		{"longer path with =", Process{CommandLine: "python -m mlx_lm.server --model=/home/me/golms/mlx_lm/qwen-7b"}, modelPath, false},
+		{"other flag", Process{CommandLine: "python -m mlx_lm.server --adapter-path /home/me/golms/mlx_lm/qwen"}, modelPath, false},
+		{"no path", Process{CommandLine: "python -m mlx_lm.server --model"}, modelPath, false},
+		{"command line with spaces", Process{CommandLine: "python -m mlx_lm.server --model /home/me/golms/mlx_lm/My Model --port 8080"}, spacedPath, true},
+		{"command line with spaces at the end", Process{CommandLine: "python -m mlx_lm.server --model /home/me/golms/mlx_lm/My Model"}, spacedPath, true},
+		{"prefix of a path with spaces", Process{CommandLine: "python -m mlx_lm.server --model /home/me/golms/mlx_lm/My Model --port 8080"}, "/home/me/golms/mlx_lm/My", false},
+		{"argv with spaces", Process{Args: []string{"python", "-m", "mlx_lm.server", "--model", spacedPath, "--port", "8080"}}, spacedPath, true},
+		{"argv with =", Process{Args: []string{"mlx_lm.server", "--model=" + spacedPath}}, spacedPath, true},
+		{"argv prefix", Process{Args: []string{"mlx_lm.server", "--model", spacedPath}}, "/home/me/golms/mlx_lm/My", false},
+		{"argv other flag", Process{Args: []string{"mlx_lm.server", "--adapter-path", spacedPath}}, spacedPath, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.process.ServesModel(tt.modelPath); got != tt.want {
+				t.Errorf("ServesModel(%q) = %v, want %v", tt.modelPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+		t.Skip("/proc is not available")
+	}
+	const modelPath = "/home/me/golms/mlx_lm/My Model"
+	// The trailing command keeps sh from replacing itself with sleep
+	cmd := exec.Command("sh", "-c", "sleep 30; :", "mlx_lm.server", "--model", modelPath)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	args := processArgs(cmd.Process.Pid)
+	process := Process{PID: cmd.Process.Pid, CommandLine: strings.Join(args, " "), Args: args}
+	if !process.ServesModel(modelPath) {
+		t.Errorf("ServesModel(%q) = false for argv %q", modelPath, args)
+	}
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,54 +9,101 @@ import (
 	"strings"
 	"time"
 
-	"github.com/changminbark/golms/pkg/constants"
 	"github.com/changminbark/golms/pkg/ui"
 )
 
+// MlxLMServerManager manages an mlx_lm.server process. It also manages
+// mlx_vlm.server, which shares its command line interface.
 type MlxLMServerManager struct {
 	BaseModelServerManager
 }
 
+// command returns the server executable, e.g. mlx_lm.server
+func (m *MlxLMServerManager) command() string {
+	return m.modelServer + ".server"
+}
+
+// logPath returns the file the server output is written to, one per port
+// so that servers for different LLMs do not share a log
+func (m *MlxLMServerManager) logPath() string {
+	return fmt.Sprintf("/tmp/%s_server_%d.log", m.modelServer, m.port)
+}
+
+// modelPath returns the directory of the LLM under ~/golms/
+func (m *MlxLMServerManager) modelPath() (string, error) {
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homePath, "/golms", m.modelServer, m.llm), nil
+}
+
+// servesModel reports whether a server process loads the manager's LLM.
+// Managers created without an LLM match any server.
+func (m *MlxLMServerManager) servesModel(process Process) bool {
+	if m.llm == "" {
+		return true
+	}
+	modelPath, err := m.modelPath()
+	if err != nil {
+		return false
+	}
+	return process.ServesModel(modelPath)
+}
+
 func (m *MlxLMServerManager) IsRunning() (bool, int) {
+	for _, process := range pythonProcesses(m.command()) {
+		if m.servesModel(process) {
+			return true, process.PID
+		}
+	}
+	return false, -1
+}
+
+// pythonProcesses returns the running python processes whose command line
+// contains command, e.g. mlx_lm.server
+func pythonProcesses(command string) []Process {
 	// Check if python processes are running
-	cmd := exec.Command("pgrep", "python")
-	pgrepPython, err := cmd.Output()
+	pgrepPython, err := exec.Command("pgrep", "python").Output()
 	if err != nil {
-		return false, -1
+		return nil
 	}
-	pgrepPythonString := string(pgrepPython)
-	pgrepPythonStringList := strings.Split(pgrepPythonString, "\n")
 
-	// Check if any of the python processes contain mlx_lm.server
-	for _, process := range pgrepPythonStringList {
-		process = strings.TrimSpace(process)
-		if process == "" {
+	var processes []Process
+	for _, pid := range strings.Split(string(pgrepPython), "\n") {
+		pid = strings.TrimSpace(pid)
+		if pid == "" {
 			continue
 		}
 
 		// Use ps to get the full command line for this PID
-		cmd = exec.Command("ps", "-p", process, "-o", "command=")
-		psOutput, err := cmd.Output()
+		psOutput, err := exec.Command("ps", "-p", pid, "-o", "command=").Output()
 		if err != nil {
 			continue // Process might have terminated
 		}
-
-		// Check if the command contains mlx_lm.server
-		if strings.Contains(string(psOutput), "mlx_lm.server") {
-			pid, _ := strconv.Atoi(process)
-			return true, pid
+		if commandLine := strings.TrimSpace(string(psOutput)); strings.Contains(commandLine, command) {
+			n, _ := strconv.Atoi(pid)
+			processes = append(processes, Process{PID: n, CommandLine: commandLine, Args: processArgs(n)})
 		}
 	}
-	return false, -1
+	return processes
+}
+
+// processArgs reads the argv of a process from /proc, which keeps arguments
+// containing spaces apart. It returns nil where /proc is not available.
+func processArgs(pid int) []string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(cmdline) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
 }
 
 func (m *MlxLMServerManager) Start() error {
 	// Build model path
-	var modelPath string
-	if homePath, err := os.UserHomeDir(); err != nil {
+	modelPath, err := m.modelPath()
+	if err != nil {
 		return err
-	} else {
-		modelPath = path.Join(homePath, "/golms", constants.Mlx_lm, m.llm)
 	}
 
 	// Check if model path exists
@@ -65,30 +111,33 @@ func (m *MlxLMServerManager) Start() error {
 		return fmt.Errorf("model path does not exist: %s", modelPath)
 	}
 
+	// Use the first free port so servers for several LLMs can run side by side
+	m.port, err = freePort(defaultPort)
+	if err != nil {
+		return err
+	}
+
 	// Create log file for server output
-	logFile, err := os.Create("/tmp/mlx_lm_server.log")
+	logFile, err := os.Create(m.logPath())
 	if err != nil {
 		return fmt.Errorf("failed to create log file: %w", err)
 	}
 
-	// Set the port we'll use
-	m.port = 8080
-
 	// Run command in background
-	cmd := exec.Command("mlx_lm.server", "--model", modelPath, "--host", "127.0.0.1", "--port", strconv.Itoa(m.port))
+	cmd := exec.Command(m.command(), "--model", modelPath, "--host", "127.0.0.1", "--port", strconv.Itoa(m.port))
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
 	if err := cmd.Start(); err != nil {
 		logFile.Close()
-		return fmt.Errorf("failed to start mlx_lm.server: %w", err)
+		return fmt.Errorf("failed to start %s: %w", m.command(), err)
 	}
 
-	fmt.Println(ui.SubtleStyle.Render(fmt.Sprintf("Server started with PID: %d", cmd.Process.Pid)))
-	fmt.Println(ui.SubtleStyle.Render("Logs: /tmp/mlx_lm_server.log"))
+	fmt.Fprintln(Output, ui.SubtleStyle.Render(fmt.Sprintf("Server started with PID: %d", cmd.Process.Pid)))
+	fmt.Fprintln(Output, ui.SubtleStyle.Render("Logs: "+m.logPath()))
 
 	// Wait for server to start listening on the port
-	fmt.Print(ui.SubtleStyle.Render("Waiting for server to initialize"))
+	fmt.Fprint(Output, ui.SubtleStyle.Render("Waiting for server to initialize"))
 	for i := 0; i < 30; i++ {
 		time.Sleep(1 * time.Second)
 
@@ -99,15 +148,15 @@ func (m *MlxLMServerManager) Start() error {
 			checkCmd := exec.Command("lsof", "-Pan", "-p", strconv.Itoa(pid), "-i")
 			if output, err := checkCmd.Output(); err == nil && len(output) > 0 {
 				// Port is listening
-				fmt.Println()
-				fmt.Println(ui.FormatSuccess(fmt.Sprintf("Server is listening on port %d", m.port)))
+				fmt.Fprintln(Output)
+				fmt.Fprintln(Output, ui.FormatSuccess(fmt.Sprintf("Server is listening on port %d", m.port)))
 				return nil
 			}
 		}
 	}
-	fmt.Println()
-	fmt.Println(ui.FormatWarning("Server process started but may not be listening yet"))
-	fmt.Println(ui.SubtleStyle.Render("Check logs at /tmp/mlx_lm_server.log"))
+	fmt.Fprintln(Output)
+	fmt.Fprintln(Output, ui.FormatWarning("Server process started but may not be listening yet"))
+	fmt.Fprintln(Output, ui.SubtleStyle.Render("Check logs at "+m.logPath()))
 
 	return nil
 }
@@ -116,7 +165,7 @@ func (m *MlxLMServerManager) Stop() error {
 	// Check if running
 	isRunning, pid := m.IsRunning()
 	if !isRunning {
-		return errors.New("mlx_lm.server is not running")
+		return fmt.Errorf("%s is not running", m.command())
 	}
 	// Kill PID
 	if err := exec.Command("kill", "-9", strconv.Itoa(pid)).Run(); err != nil {
@@ -0,0 +1,311 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/config"
+)
+
+const titleLength = 50
+
+var (
+	ErrNotFound     = errors.New("session not found")
+	ErrTurnNotFound = errors.New("turn not found")
+)
+
+// Turn is a single message in a conversation along with its metadata.
+// Turns form a tree: regenerating or editing a message adds a sibling
+// instead of replacing it, so every alternative is kept as a branch.
+type Turn struct {
+	ID int `json:"id"`
+	// Parent is the ID of the previous turn, 0 for the first turn
+	Parent    int            `json:"parent,omitempty"`
+	Message   client.Message `json:"message"`
+	CreatedAt time.Time      `json:"created_at"`
+	Usage     *client.Usage  `json:"usage,omitempty"`
+	Latency   time.Duration  `json:"latency,omitempty"`
+}
+
+// Session is a saved conversation with a single LLM
+type Session struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Server    string    `json:"server"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Turns holds every turn of every branch in the order they were created
+	Turns []Turn `json:"turns"`
+	// Head is the ID of the last turn of the current branch
+	Head int `json:"head"`
+	// Options are the chat options of the latest response
+	Options *client.Overrides `json:"options,omitempty"`
+}
+
+// New creates an empty session for the given model server and LLM
+func New(modelServer string, llm string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        newID(now),
+		Server:    modelServer,
+		Model:     llm,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Turns:     []Turn{},
+	}
+}
+
+// Messages returns the current branch in the form expected by ChatRequest
+func (s *Session) Messages() []client.Message {
+	path := s.Path()
+	messages := make([]client.Message, 0, len(path))
+	for _, turn := range path {
+		messages = append(messages, turn.Message)
+	}
+	return messages
+}
+
+// Path returns the turns of the current branch, oldest first
+func (s *Session) Path() []Turn {
+	return s.pathTo(s.Head)
+}
+
+func (s *Session) pathTo(id int) []Turn {
+	var path []Turn
+	for id != 0 {
+		turn, ok := s.Turn(id)
+		if !ok {
+			break
+		}
+		path = append(path, turn)
+		id = turn.Parent
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// Turn returns the turn with the given ID
+func (s *Session) Turn(id int) (Turn, bool) {
+	// IDs are assigned in creation order, so they double as indexes
+	if id < 1 || id > len(s.Turns) {
+		return Turn{}, false
+	}
+	return s.Turns[id-1], true
+}
+
+// Append adds a message to the current branch, using the first user message
+// as the session title
+func (s *Session) Append(message client.Message, usage *client.Usage, latency time.Duration) {
+	now := time.Now()
+	turn := Turn{
+		ID:        len(s.Turns) + 1,
+		Parent:    s.Head,
+		Message:   message,
+		CreatedAt: now,
+		Usage:     usage,
+		Latency:   latency,
+	}
+	s.Turns = append(s.Turns, turn)
+	s.Head = turn.ID
+	s.UpdatedAt = now
+
+	if s.Title == "" && message.Role == "user" {
+		s.Title = makeTitle(message.Content)
+	}
+}
+
+// Checkout moves the head to the turn with the given ID, so the next message
+// continues from there and starts a new branch if the turn already has replies.
+// An ID of 0 moves before the first turn.
+func (s *Session) Checkout(id int) error {
+	if _, ok := s.Turn(id); !ok && id != 0 {
+		return fmt.Errorf("%w: %d", ErrTurnNotFound, id)
+	}
+	s.Head = id
+	return nil
+}
+
+// LastTurn returns the last turn with the given role in the current branch
+func (s *Session) LastTurn(role string) (Turn, bool) {
+	path := s.Path()
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Message.Role == role {
+			return path[i], true
+		}
+	}
+	return Turn{}, false
+}
+
+// Branch is one complete line of conversation, from the first turn to a leaf
+type Branch struct {
+	// Leaf is the ID of the last turn of the branch
+	Leaf  int
+	Turns []Turn
+}
+
+// Title describes the branch by its last user message
+func (b Branch) Title() string {
+	for i := len(b.Turns) - 1; i >= 0; i-- {
+		if b.Turns[i].Message.Role == "user" {
+			return makeTitle(b.Turns[i].Message.Content)
+		}
+	}
+	return ""
+}
+
+// Branches returns every branch of the conversation in the order they were started
+func (s *Session) Branches() []Branch {
+	hasChildren := make(map[int]bool)
+	for _, turn := range s.Turns {
+		hasChildren[turn.Parent] = true
+	}
+
+	var branches []Branch
+	for _, turn := range s.Turns {
+		if !hasChildren[turn.ID] {
+			branches = append(branches, Branch{Leaf: turn.ID, Turns: s.pathTo(turn.ID)})
+		}
+	}
+	return branches
+}
+
+// TotalUsage sums token usage over every turn of every branch
+func (s *Session) TotalUsage() client.Usage {
+	var total client.Usage
+	for _, turn := range s.Turns {
+		if turn.Usage != nil {
+			total.PromptTokens += turn.Usage.PromptTokens
+			total.CompletionTokens += turn.Usage.CompletionTokens
+			total.TotalTokens += turn.Usage.TotalTokens
+		}
+	}
+	return total
+}
+
+// Store persists sessions as JSON files under ~/.golms/sessions/
+type Store struct {
+	dir string
+}
+
+// NewStore opens the default session store
+func NewStore() (*Store, error) {
+	dir, err := config.SubDir("sessions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes the session to disk, replacing any previous version
+func (st *Store) Save(s *Session) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	sessionPath, err := st.path(s.ID)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated session
+	tmpPath := sessionPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return os.Rename(tmpPath, sessionPath)
+}
+
+// Load reads the session with the given id
+func (st *Store) Load(id string) (*Session, error) {
+	sessionPath, err := st.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(sessionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+	s.upgrade()
+	return &s, nil
+}
+
+// upgrade turns a session saved before branching was supported into a
+// single branch
+func (s *Session) upgrade() {
+	if len(s.Turns) == 0 || s.Turns[0].ID != 0 {
+		return
+	}
+	for i := range s.Turns {
+		s.Turns[i].ID = i + 1
+		s.Turns[i].Parent = i
+	}
+	s.Head = len(s.Turns)
+}
+
+// List returns all saved sessions, most recently updated first
+func (st *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		s, err := st.Load(id)
+		if err != nil {
+			// Skip unreadable files instead of hiding every other session
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// path returns the file of the session with the given id, refusing IDs
+// that would point outside the store
+func (st *Store) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	return path.Join(st.dir, id+".json"), nil
+}
+
+func newID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+func makeTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(title); len(runes) > titleLength {
+		title = string(runes[:titleLength-1]) + "…"
+	}
+	return title
+}
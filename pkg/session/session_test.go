@@ -0,0 +1,103 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+func contents(messages []client.Message) []string {
+	var out []string
+	for _, m := range messages {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBranches(t *testing.T) {
+	s := New("mlx_lm", "qwen")
+	s.Append(client.Message{Role: "user", Content: "hi"}, nil, 0)
+	s.Append(client.Message{Role: "assistant", Content: "hello"}, nil, 0)
+
+	// Regenerate the answer from the user turn
+	user, ok := s.LastTurn("user")
+	if !ok {
+		t.Fatal("expected a user turn")
+	}
+	if err := s.Checkout(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.Append(client.Message{Role: "assistant", Content: "hey"}, nil, 0)
+
+	if got := contents(s.Messages()); !equal(got, []string{"hi", "hey"}) {
+		t.Errorf("Messages() = %v after regenerating", got)
+	}
+
+	branches := s.Branches()
+	if len(branches) != 2 {
+		t.Fatalf("expected 2 branches, got %d", len(branches))
+	}
+	if err := s.Checkout(branches[0].Leaf); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(s.Messages()); !equal(got, []string{"hi", "hello"}) {
+		t.Errorf("Messages() = %v after switching back", got)
+	}
+	if branches[1].Title() != "hi" {
+		t.Errorf("Title() = %q", branches[1].Title())
+	}
+
+	if err := s.Checkout(42); err == nil {
+		t.Error("expected an error checking out a missing turn")
+	}
+}
+
+func TestLoadUpgradesLinearSessions(t *testing.T) {
+	st := &Store{dir: t.TempDir()}
+	legacy := `{"id": "old", "turns": [
+		{"message": {"role": "user", "content": "hi"}},
+		{"message": {"role": "assistant", "content": "hello"}}
+	]}`
+	if err := os.WriteFile(path.Join(st.dir, "old.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := st.Load("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(s.Messages()); !equal(got, []string{"hi", "hello"}) {
+		t.Errorf("Messages() = %v", got)
+	}
+	s.Append(client.Message{Role: "user", Content: "again"}, nil, 0)
+	if turn, _ := s.Turn(s.Head); turn.Parent != 2 {
+		t.Errorf("new turn has parent %d, want 2", turn.Parent)
+	}
+}
+
+func TestLoadRejectsPaths(t *testing.T) {
+	st := &Store{dir: t.TempDir()}
+	for _, id := range []string{"", "..", "../config", "a/b", `..\x`} {
+		if _, err := st.Load(id); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q) error = %v, want an invalid ID error", id, err)
+		}
+	}
+	if err := st.Save(&Session{ID: "../escaped"}); err == nil {
+		t.Error("expected saving a session with a path as its ID to fail")
+	}
+}
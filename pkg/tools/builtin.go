@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultAllowedCommands are the commands run_command may run unless
+// configured otherwise. Commands that can start other programs, such as find
+// with -exec or git through its config and aliases, are left out.
+var DefaultAllowedCommands = []string{"ls", "cat", "head", "tail", "wc", "grep", "pwd", "date"}
+
+// commandTimeout bounds how long run_command and fetch may take
+const commandTimeout = 30 * time.Second
+
+// Builtin returns the built-in local tools. run_command may only run the
+// programs in allowedCommands.
+func Builtin(allowedCommands []string) *Registry {
+	return NewRegistry(
+		ReadFile(),
+		ListDir(),
+		RunCommand(allowedCommands),
+		Fetch(),
+	)
+}
+
+// ReadFile returns a tool that reads a text file
+func ReadFile() Tool {
+	return Tool{
+		Name:        "read_file",
+		Description: "Read the contents of a text file",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{"type": "string", "description": "Path of the file to read"},
+			},
+			"required": []string{"path"},
+		},
+		Run: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			// Devices and FIFOs never end, so only regular files are read
+			info, err := os.Stat(args.Path)
+			if err != nil {
+				return "", err
+			}
+			if !info.Mode().IsRegular() {
+				return "", fmt.Errorf("%s is not a regular file", args.Path)
+			}
+			f, err := os.Open(args.Path)
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			data, err := io.ReadAll(io.LimitReader(f, MaxOutputSize+1))
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// ListDir returns a tool that lists the entries of a directory
+func ListDir() Tool {
+	return Tool{
+		Name:        "list_dir",
+		Description: "List the files and directories in a directory. Directories end with /.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{"type": "string", "description": "Directory to list, defaults to the current directory"},
+			},
+		},
+		Run: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.Path == "" {
+				args.Path = "."
+			}
+			entries, err := os.ReadDir(args.Path)
+			if err != nil {
+				return "", err
+			}
+			var b strings.Builder
+			for _, entry := range entries {
+				b.WriteString(entry.Name())
+				if entry.IsDir() {
+					b.WriteString("/")
+				}
+				b.WriteString("\n")
+			}
+			return b.String(), nil
+		},
+	}
+}
+
+// RunCommand returns a tool that runs one of allowedCommands without a shell
+func RunCommand(allowedCommands []string) Tool {
+	return Tool{
+		Name:        "run_command",
+		Description: "Run a command and return its combined output. Allowed commands: " + strings.Join(allowedCommands, ", "),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "Program to run", "enum": allowedCommands},
+				"args":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Arguments passed to the program"},
+			},
+			"required": []string{"command"},
+		},
+		Run: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				Command string   `json:"command"`
+				Args    []string `json:"args"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if !slices.Contains(allowedCommands, args.Command) {
+				return "", fmt.Errorf("command %q is not allowed (allowed: %s)", args.Command, strings.Join(allowedCommands, ", "))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+			defer cancel()
+			output := &cappedWriter{limit: MaxOutputSize + 1, full: cancel}
+			cmd := exec.CommandContext(ctx, args.Command, args.Args...)
+			cmd.Stdout = output
+			cmd.Stderr = output
+			err := cmd.Run()
+			if output.truncated {
+				// The command was stopped for writing too much, which is not its failure
+				return output.buf.String(), nil
+			}
+			if err != nil {
+				// The output usually explains the failure, so pass both back
+				return "", fmt.Errorf("%w\n%s", err, output.buf.String())
+			}
+			return output.buf.String(), nil
+		},
+	}
+}
+
+// cappedWriter keeps the first limit bytes written to it and calls full once
+// more is written, so a command can be stopped before it fills memory
+type cappedWriter struct {
+	buf       bytes.Buffer
+	limit     int
+	full      func()
+	truncated bool
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if room := w.limit - w.buf.Len(); len(p) > room {
+		w.buf.Write(p[:room])
+		if !w.truncated {
+			w.truncated = true
+			w.full()
+		}
+		return len(p), nil
+	}
+	return w.buf.Write(p)
+}
+
+// Fetch returns a tool that makes HTTP GET requests to services on localhost
+func Fetch() Tool {
+	httpClient := &http.Client{
+		Timeout: commandTimeout,
+		// Do not let a local service redirect the request elsewhere
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return checkLocalURL(req.URL.String())
+		},
+	}
+	return Tool{
+		Name:        "fetch",
+		Description: "Fetch a URL on localhost with an HTTP GET request",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{"type": "string", "description": "URL to fetch, e.g. http://localhost:8080/health"},
+			},
+			"required": []string{"url"},
+		},
+		Run: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				URL string `json:"url"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if err := checkLocalURL(args.URL); err != nil {
+				return "", err
+			}
+
+			resp, err := httpClient.Get(args.URL)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(io.LimitReader(resp.Body, MaxOutputSize+1))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("HTTP %s\n\n%s", resp.Status, body), nil
+		},
+	}
+}
+
+// checkLocalURL only allows http(s) URLs that point at the loopback interface
+func checkLocalURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return errors.New("only URLs on localhost may be fetched")
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+func TestRunCommandRefusesCommandsNotAllowed(t *testing.T) {
+	registry := NewRegistry(RunCommand([]string{"echo"}))
+
+	output, err := registry.Execute(toolCall("1", "run_command", `{"command":"echo","args":["hello"]}`))
+	if err != nil || strings.TrimSpace(output) != "hello" {
+		t.Errorf("echo: output %q, err %v", output, err)
+	}
+
+	if _, err := registry.Execute(toolCall("2", "run_command", `{"command":"rm","args":["-rf","/"]}`)); err == nil {
+		t.Error("expected rm to be refused")
+	}
+}
+
+func TestOutputIsLimited(t *testing.T) {
+	dir := t.TempDir()
+	large := filepath.Join(dir, "large.txt")
+	if err := os.WriteFile(large, bytes.Repeat([]byte("x"), 4*MaxOutputSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(ReadFile(), RunCommand([]string{"cat", "yes"}))
+
+	tests := []struct {
+		name      string
+		call      client.ToolCall
+		truncated bool
+	}{
+		{"large file", toolCall("1", "read_file", `{"path":"`+large+`"}`), true},
+		{"directory", toolCall("2", "read_file", `{"path":"`+dir+`"}`), false},
+		{"device", toolCall("3", "read_file", `{"path":"/dev/zero"}`), false},
+		{"endless command", toolCall("4", "run_command", `{"command":"yes"}`), true},
+		{"large command output", toolCall("5", "run_command", `{"command":"cat","args":["`+large+`"]}`), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := registry.Execute(tt.call)
+			if !tt.truncated {
+				if err == nil {
+					t.Errorf("expected an error, got %d bytes of output", len(output))
+				}
+				return
+			}
+			if err != nil || !strings.HasSuffix(output, "[output truncated]") || len(output) > MaxOutputSize+len("\n[output truncated]") {
+				t.Errorf("expected truncated output, got %d bytes, err %v", len(output), err)
+			}
+		})
+	}
+}
+
+func TestCheckLocalURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		local bool
+	}{
+		{"http://localhost:8080/health", true},
+		{"http://127.0.0.1/", true},
+		{"http://[::1]:3000/api", true},
+		{"https://example.com/", false},
+		{"http://10.0.0.1/", false},
+		{"file:///etc/passwd", false},
+		{"http://localhost.example.com/", false},
+	}
+	for _, tt := range tests {
+		err := checkLocalURL(tt.url)
+		if (err == nil) != tt.local {
+			t.Errorf("checkLocalURL(%q) = %v, want local %v", tt.url, err, tt.local)
+		}
+	}
+}
+
+func TestExecuteRejectsInvalidArguments(t *testing.T) {
+	registry := NewRegistry(ReadFile())
+	if _, err := registry.Execute(client.ToolCall{Function: client.FunctionCall{Name: "read_file", Arguments: "{not json"}}); err == nil {
+		t.Error("expected invalid arguments to be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// DefaultMaxRounds limits how many times in a row the model may call tools
+const DefaultMaxRounds = 10
+
+var (
+	ErrTooManyRounds = errors.New("model kept calling tools")
+	ErrDenied        = errors.New("the user declined to run this tool")
+)
+
+// Loop sends a conversation to the model and runs the tools it calls,
+// feeding their results back until it answers without calling tools
+type Loop struct {
+	Registry *Registry
+	// Send sends a request and returns the model's response
+	Send func(req *client.ChatRequest) (*client.ChatResponse, error)
+	// Confirm is asked before every tool call; calls it rejects are reported to the
+	// model as denied. A nil Confirm runs every call.
+	Confirm func(call client.ToolCall) bool
+	// OnMessage is called for every message added to the conversation,
+	// with the response it came from for assistant messages
+	OnMessage func(message client.Message, resp *client.ChatResponse)
+	// MaxRounds defaults to DefaultMaxRounds
+	MaxRounds int
+}
+
+// Run sends req and appends the model's messages and tool results to it,
+// returning the final response
+func (l *Loop) Run(req *client.ChatRequest) (*client.ChatResponse, error) {
+	maxRounds := l.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxRounds
+	}
+
+	for round := 0; ; round++ {
+		resp, err := l.Send(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, errors.New("response has no choices")
+		}
+
+		message := resp.Choices[0].Message
+		req.Messages = append(req.Messages, message)
+		l.notify(message, resp)
+
+		if len(message.ToolCalls) == 0 || l.Registry == nil {
+			return resp, nil
+		}
+		if round+1 >= maxRounds {
+			return resp, fmt.Errorf("%w: stopped after %d rounds", ErrTooManyRounds, maxRounds)
+		}
+
+		for _, call := range message.ToolCalls {
+			result := Result(call, "", ErrDenied)
+			if l.Confirm == nil || l.Confirm(call) {
+				output, err := l.Registry.Execute(call)
+				result = Result(call, output, err)
+			}
+			req.Messages = append(req.Messages, result)
+			l.notify(result, nil)
+		}
+	}
+}
+
+func (l *Loop) notify(message client.Message, resp *client.ChatResponse) {
+	if l.OnMessage != nil {
+		l.OnMessage(message, resp)
+	}
+}
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+)
+
+// newLoop returns a loop sending requests to a fake server scripted with replies
+func newLoop(t *testing.T, registry *Registry, replies ...fakeserver.Reply) (*Loop, *fakeserver.Server) {
+	t.Helper()
+	server := fakeserver.New(t, replies...)
+	modelServerClient := client.NewClient(constants.Mlx_lm, "fake", server.Host(), server.Port())
+	loop := &Loop{
+		Registry: registry,
+		Send: func(req *client.ChatRequest) (*client.ChatResponse, error) {
+			return modelServerClient.Chat(context.Background(), req, nil)
+		},
+	}
+	return loop, server
+}
+
+func toolCall(id string, name string, arguments string) client.ToolCall {
+	return client.ToolCall{ID: id, Type: "function", Function: client.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+// echoTool returns its "text" argument and counts how often it ran
+func echoTool(runs *int) Tool {
+	return Tool{
+		Name:       "echo",
+		Parameters: map[string]any{"type": "object"},
+		Run: func(arguments json.RawMessage) (string, error) {
+			*runs++
+			var args struct {
+				Text string `json:"text"`
+			}
+			err := decodeArguments(arguments, &args)
+			return args.Text, err
+		},
+	}
+}
+
+func TestLoopRunsToolAndSendsResult(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("remember the milk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	arguments, _ := json.Marshal(map[string]string{"path": path})
+
+	loop, server := newLoop(t, Builtin(DefaultAllowedCommands),
+		fakeserver.Reply{Message: client.Message{ToolCalls: []client.ToolCall{toolCall("call_1", "read_file", string(arguments))}}},
+		fakeserver.Reply{Message: client.Message{Content: "You need to buy milk."}},
+	)
+	var confirmed []string
+	loop.Confirm = func(call client.ToolCall) bool {
+		confirmed = append(confirmed, call.Function.Name)
+		return true
+	}
+
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	req.Tools = loop.Registry.Definitions()
+	req.Messages = []client.Message{{Role: "user", Content: "what is in my notes?"}}
+	resp, err := loop.Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resp.Choices[0].Message.Content; got != "You need to buy milk." {
+		t.Errorf("final answer = %q", got)
+	}
+	if len(confirmed) != 1 || confirmed[0] != "read_file" {
+		t.Errorf("confirmed calls = %v, want [read_file]", confirmed)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("server received %d requests, want 2", len(requests))
+	}
+	if len(requests[0].Tools) != 4 {
+		t.Errorf("first request offered %d tools, want 4", len(requests[0].Tools))
+	}
+	sent := requests[1].Messages
+	if len(sent) != 3 {
+		t.Fatalf("second request has %d messages, want 3", len(sent))
+	}
+	if sent[1].ToolCalls[0].Function.Name != "read_file" {
+		t.Errorf("assistant tool call not sent back: %+v", sent[1])
+	}
+	result := sent[2]
+	if result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != "remember the milk" {
+		t.Errorf("tool result = %+v", result)
+	}
+	if len(req.Messages) != 4 {
+		t.Errorf("conversation has %d messages, want 4", len(req.Messages))
+	}
+}
+
+func TestLoopReportsDeniedCalls(t *testing.T) {
+	runs := 0
+	loop, server := newLoop(t, NewRegistry(echoTool(&runs)),
+		fakeserver.Reply{Message: client.Message{ToolCalls: []client.ToolCall{toolCall("call_1", "echo", `{"text":"hi"}`)}}},
+		fakeserver.Reply{Message: client.Message{Content: "ok"}},
+	)
+	loop.Confirm = func(call client.ToolCall) bool { return false }
+
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	req.Messages = []client.Message{{Role: "user", Content: "say hi"}}
+	if _, err := loop.Run(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs != 0 {
+		t.Errorf("denied tool ran %d times", runs)
+	}
+	result := server.Requests()[1].Messages[2]
+	if !strings.Contains(result.Content, ErrDenied.Error()) {
+		t.Errorf("tool result = %q, want denial", result.Content)
+	}
+}
+
+func TestLoopReportsToolErrors(t *testing.T) {
+	loop, server := newLoop(t, NewRegistry(),
+		fakeserver.Reply{Message: client.Message{ToolCalls: []client.ToolCall{toolCall("call_1", "missing", `{}`)}}},
+		fakeserver.Reply{Message: client.Message{Content: "sorry"}},
+	)
+
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	req.Messages = []client.Message{{Role: "user", Content: "do it"}}
+	if _, err := loop.Run(req); err != nil {
+		t.Fatal(err)
+	}
+
+	result := server.Requests()[1].Messages[2]
+	if !strings.HasPrefix(result.Content, "Error: "+ErrUnknownTool.Error()) {
+		t.Errorf("tool result = %q, want unknown tool error", result.Content)
+	}
+}
+
+func TestLoopStreamedToolCalls(t *testing.T) {
+	runs := 0
+	loop, _ := newLoop(t, NewRegistry(echoTool(&runs)),
+		fakeserver.Reply{Message: client.Message{ToolCalls: []client.ToolCall{
+			toolCall("call_1", "echo", `{"text":"a"}`),
+			toolCall("call_2", "echo", `{"text":"b"}`),
+		}}},
+		fakeserver.Reply{Message: client.Message{Content: "done"}},
+	)
+	var results []string
+	loop.OnMessage = func(message client.Message, resp *client.ChatResponse) {
+		if message.Role == "tool" {
+			results = append(results, message.Content)
+		}
+	}
+
+	req := client.NewChatRequest(client.ChatOptions{Stream: true})
+	req.Messages = []client.Message{{Role: "user", Content: "echo a and b"}}
+	if _, err := loop.Run(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs != 2 || strings.Join(results, ",") != "a,b" {
+		t.Errorf("ran %d times with results %v, want 2 runs with [a b]", runs, results)
+	}
+}
+
+func TestLoopStopsAfterMaxRounds(t *testing.T) {
+	runs := 0
+	call := fakeserver.Reply{Message: client.Message{ToolCalls: []client.ToolCall{toolCall("call_1", "echo", `{}`)}}}
+	loop, _ := newLoop(t, NewRegistry(echoTool(&runs)), call, call, call)
+	loop.MaxRounds = 2
+
+	req := client.NewChatRequest(client.DefaultChatOptions)
+	req.Messages = []client.Message{{Role: "user", Content: "loop forever"}}
+	if _, err := loop.Run(req); !errors.Is(err, ErrTooManyRounds) {
+		t.Fatalf("err = %v, want ErrTooManyRounds", err)
+	}
+	if runs != 1 {
+		t.Errorf("tool ran %d times, want 1", runs)
+	}
+}
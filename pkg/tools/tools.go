@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/changminbark/golms/pkg/client"
+)
+
+// MaxOutputSize is the largest tool result sent back to the model; longer output is truncated
+const MaxOutputSize = 32 * 1024
+
+var ErrUnknownTool = errors.New("unknown tool")
+
+// Tool is a local function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]any
+	// Run executes the tool with its JSON-encoded arguments
+	Run func(arguments json.RawMessage) (string, error)
+}
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	tools []Tool
+}
+
+// NewRegistry creates a registry offering the given tools
+func NewRegistry(tools ...Tool) *Registry {
+	return &Registry{tools: tools}
+}
+
+// Tools returns the registered tools in the order they were added
+func (r *Registry) Tools() []Tool {
+	return r.tools
+}
+
+// Find returns the tool with the given name
+func (r *Registry) Find(name string) (*Tool, bool) {
+	for i := range r.tools {
+		if r.tools[i].Name == name {
+			return &r.tools[i], true
+		}
+	}
+	return nil, false
+}
+
+// Definitions returns the tools in the form sent in ChatRequest.Tools
+func (r *Registry) Definitions() []client.Tool {
+	definitions := make([]client.Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, client.Tool{
+			Type: "function",
+			Function: client.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// Execute runs a tool call and returns its output
+func (r *Registry) Execute(call client.ToolCall) (string, error) {
+	tool, ok := r.Find(call.Function.Name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTool, call.Function.Name)
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if strings.TrimSpace(call.Function.Arguments) == "" {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return "", fmt.Errorf("invalid arguments for %s: not a JSON object", tool.Name)
+	}
+
+	output, err := tool.Run(arguments)
+	if err != nil {
+		return "", err
+	}
+	if len(output) > MaxOutputSize {
+		output = output[:MaxOutputSize] + "\n[output truncated]"
+	}
+	return output, nil
+}
+
+// Result creates the "tool" role message that answers a call, reporting
+// errors to the model so it can correct itself
+func Result(call client.ToolCall, output string, err error) client.Message {
+	if err != nil {
+		output = "Error: " + err.Error()
+	}
+	return client.Message{
+		Role:       "tool",
+		Content:    output,
+		ToolCallID: call.ID,
+		Name:       call.Function.Name,
+	}
+}
+
+// decodeArguments unmarshals tool arguments into v
+func decodeArguments(arguments json.RawMessage, v any) error {
+	if err := json.Unmarshal(arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
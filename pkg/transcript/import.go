@@ -0,0 +1,58 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+var roles = []string{"system", "user", "assistant", "tool"}
+
+// ReadMessages reads a conversation in the OpenAI chat format, either a list
+// of messages or a chat completions request with "messages" and "model"
+func ReadMessages(r io.Reader) (model string, messages []client.Message, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &messages)
+	} else {
+		var req struct {
+			Model    string           `json:"model"`
+			Messages []client.Message `json:"messages"`
+		}
+		err = json.Unmarshal(data, &req)
+		model, messages = req.Model, req.Messages
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid messages JSON: %w", err)
+	}
+
+	if len(messages) == 0 {
+		return "", nil, errors.New("no messages found")
+	}
+	for i, message := range messages {
+		if !slices.Contains(roles, message.Role) {
+			return "", nil, fmt.Errorf("message %d has invalid role %q", i+1, message.Role)
+		}
+	}
+	return model, messages, nil
+}
+
+// Import creates a session holding messages as a single branch
+func Import(messages []client.Message, modelServer string, llm string) *session.Session {
+	s := session.New(modelServer, llm)
+	for _, message := range messages {
+		s.Append(message, nil, 0)
+	}
+	return s
+}
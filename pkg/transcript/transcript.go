@@ -0,0 +1,333 @@
+// Package transcript exports sessions as shareable transcripts and imports
+// conversations from other tools
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+type Format string
+
+const (
+	Markdown Format = "md"
+	JSON     Format = "json"
+	HTML     Format = "html"
+)
+
+var AvailableFormats = []Format{Markdown, JSON, HTML}
+
+// ParseFormat validates the value of a --format flag
+func ParseFormat(value string) (Format, error) {
+	for _, format := range AvailableFormats {
+		if string(format) == value {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid export format %q (expected one of md, json, html)", value)
+}
+
+// FormatFromPath picks the format matching a file's extension, Markdown by default
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".html", ".htm":
+		return HTML
+	}
+	return Markdown
+}
+
+// Options control what is included in a transcript
+type Options struct {
+	// Reasoning includes the model's thinking alongside its answers
+	Reasoning bool
+}
+
+// Transcript is the current branch of a session in export form
+type Transcript struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	Server    string            `json:"server"`
+	Model     string            `json:"model"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Options   *client.Overrides `json:"options,omitempty"`
+	Messages  []Message         `json:"messages"`
+	Usage     client.Usage      `json:"usage"`
+}
+
+// Message is a turn of a transcript
+type Message struct {
+	Role      string            `json:"role"`
+	Content   string            `json:"content"`
+	Reasoning string            `json:"reasoning,omitempty"`
+	ToolCalls []client.ToolCall `json:"tool_calls,omitempty"`
+	// Name is the tool that produced a tool result
+	Name      string        `json:"name,omitempty"`
+	Images    int           `json:"images,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Usage     *client.Usage `json:"usage,omitempty"`
+	LatencyMS int64         `json:"latency_ms,omitempty"`
+}
+
+// New builds the transcript of the current branch of a session
+func New(s *session.Session, options Options) Transcript {
+	t := Transcript{
+		ID:        s.ID,
+		Title:     s.Title,
+		Server:    s.Server,
+		Model:     s.Model,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Options:   s.Options,
+		Messages:  []Message{},
+	}
+	for _, turn := range s.Path() {
+		thought, answer := reasoning.FromMessage(turn.Message)
+		message := Message{
+			Role:      turn.Message.Role,
+			Content:   answer,
+			ToolCalls: turn.Message.ToolCalls,
+			Name:      turn.Message.Name,
+			Images:    len(turn.Message.Images),
+			CreatedAt: turn.CreatedAt,
+			Usage:     turn.Usage,
+			LatencyMS: turn.Latency.Milliseconds(),
+		}
+		if options.Reasoning {
+			message.Reasoning = thought
+		}
+		if turn.Usage != nil {
+			t.Usage.PromptTokens += turn.Usage.PromptTokens
+			t.Usage.CompletionTokens += turn.Usage.CompletionTokens
+			t.Usage.TotalTokens += turn.Usage.TotalTokens
+		}
+		t.Messages = append(t.Messages, message)
+	}
+	return t
+}
+
+// Create opens the file a transcript is exported to, refusing to replace an
+// existing file unless force is set
+func Create(path string, force bool) (*os.File, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_EXCL
+	if force {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	return file, err
+}
+
+// Write exports the current branch of a session in the given format
+func Write(w io.Writer, s *session.Session, format Format, options Options) error {
+	t := New(s, options)
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t)
+	case HTML:
+		return writeHTML(w, t)
+	case Markdown:
+		_, err := io.WriteString(w, t.Markdown())
+		return err
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// Markdown renders the transcript as a Markdown document
+func (t Transcript) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", t.heading())
+	for _, line := range t.details() {
+		fmt.Fprintf(&b, "- **%s:** %s\n", line[0], line[1])
+	}
+
+	for _, message := range t.Messages {
+		fmt.Fprintf(&b, "\n---\n\n### %s · %s\n\n", t.speaker(message), message.CreatedAt.Format(time.DateTime))
+		if message.Reasoning != "" {
+			fmt.Fprintf(&b, "<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n\n", message.Reasoning)
+		}
+		if message.Role == "tool" || message.Role == "system" {
+			fmt.Fprintf(&b, "%s\n", attach.Fence(message.Content, ""))
+		} else if message.Content != "" {
+			fmt.Fprintf(&b, "%s\n", message.Content)
+		}
+		for _, call := range message.ToolCalls {
+			fmt.Fprintf(&b, "\nCalled `%s` with `%s`\n", call.Function.Name, call.Function.Arguments)
+		}
+		if message.Images > 0 {
+			fmt.Fprintf(&b, "\n*%d image(s) attached*\n", message.Images)
+		}
+		if stats := message.stats(); stats != "" {
+			fmt.Fprintf(&b, "\n*%s*\n", stats)
+		}
+	}
+	return b.String()
+}
+
+// heading is the title of the transcript
+func (t Transcript) heading() string {
+	if t.Title == "" {
+		return "Conversation"
+	}
+	return t.Title
+}
+
+// details lists the transcript's metadata as label and value pairs
+func (t Transcript) details() [][2]string {
+	model := t.Model
+	if t.Server != "" {
+		model = t.Server + "/" + t.Model
+	}
+	details := [][2]string{
+		{"Model", model},
+		{"Started", t.CreatedAt.Format(time.DateTime)},
+		{"Updated", t.UpdatedAt.Format(time.DateTime)},
+	}
+	if t.Options != nil {
+		options := client.ChatOptions{}
+		options.Apply(*t.Options)
+		lines := append([]string{
+			fmt.Sprintf("temperature: %g", options.Temperature),
+			fmt.Sprintf("max_tokens: %d", options.MaxTokens),
+		}, options.Sampling.Describe()...)
+		details = append(details, [2]string{"Options", strings.Join(lines, ", ")})
+	}
+	details = append(details, [2]string{"Tokens", fmt.Sprintf("%d prompt, %d completion", t.Usage.PromptTokens, t.Usage.CompletionTokens)})
+	return details
+}
+
+// speaker names the author of a message
+func (t Transcript) speaker(message Message) string {
+	switch message.Role {
+	case "user":
+		return "You"
+	case "system":
+		return "System"
+	case "tool":
+		return "Tool result: " + message.Name
+	}
+	if t.Model == "" {
+		return "Assistant"
+	}
+	return t.Model
+}
+
+// stats describes a response's token usage and latency
+func (m Message) stats() string {
+	var parts []string
+	if m.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%d prompt + %d completion tokens", m.Usage.PromptTokens, m.Usage.CompletionTokens))
+	}
+	if m.LatencyMS > 0 {
+		parts = append(parts, fmt.Sprintf("%.1fs", float64(m.LatencyMS)/1000))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// markdown converts message content to HTML. Raw HTML in messages is left out.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+func renderMarkdown(content string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(content), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+	return template.HTML(buf.String())
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"markdown": renderMarkdown,
+	"time":     func(t time.Time) string { return t.Format(time.DateTime) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Heading }}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; color: #1f2328; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 0.25rem 1rem; color: #59636e; }
+dt { font-weight: 600; }
+dd { margin: 0; }
+.message { border-top: 1px solid #d1d9e0; padding: 0.5rem 0; }
+.speaker { font-weight: 600; }
+.user .speaker { color: #0969da; }
+.assistant .speaker { color: #8250df; }
+.meta, .stats { color: #59636e; font-size: 0.85rem; }
+details { color: #59636e; border-left: 3px solid #d1d9e0; padding-left: 0.75rem; }
+pre { background: #f6f8fa; padding: 0.75rem; overflow-x: auto; white-space: pre-wrap; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+</style>
+</head>
+<body>
+<h1>{{ .Heading }}</h1>
+<dl>
+{{- range .Details }}
+<dt>{{ index . 0 }}</dt><dd>{{ index . 1 }}</dd>
+{{- end }}
+</dl>
+{{- range .Messages }}
+<div class="message {{ .Role }}">
+<p><span class="speaker">{{ .Speaker }}</span> <span class="meta">{{ time .CreatedAt }}</span></p>
+{{- if .Reasoning }}
+<details><summary>Thinking</summary>{{ markdown .Reasoning }}</details>
+{{- end }}
+{{- if or (eq .Role "tool") (eq .Role "system") }}
+<pre>{{ .Content }}</pre>
+{{- else }}
+{{ markdown .Content }}
+{{- end }}
+{{- range .ToolCalls }}
+<p class="meta">Called <code>{{ .Function.Name }}</code> with <code>{{ .Function.Arguments }}</code></p>
+{{- end }}
+{{- if .Images }}
+<p class="meta">{{ .Images }} image(s) attached</p>
+{{- end }}
+{{- with .Stats }}
+<p class="stats">{{ . }}</p>
+{{- end }}
+</div>
+{{- end }}
+</body>
+</html>
+`))
+
+// writeHTML renders the transcript as a standalone HTML page
+func writeHTML(w io.Writer, t Transcript) error {
+	type htmlMessage struct {
+		Message
+		Speaker string
+		Stats   string
+	}
+	data := struct {
+		Heading  string
+		Details  [][2]string
+		Messages []htmlMessage
+	}{Heading: t.heading(), Details: t.details()}
+	for _, message := range t.Messages {
+		data.Messages = append(data.Messages, htmlMessage{message, t.speaker(message), message.stats()})
+	}
+	return htmlTemplate.Execute(w, data)
+}
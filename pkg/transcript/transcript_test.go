@@ -0,0 +1,161 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+func newSession() *session.Session {
+	s := session.New("mlx_lm", "qwen3-8b")
+	options := client.DefaultChatOptions.Overrides()
+	s.Options = &options
+	s.Append(client.Message{Role: "system", Content: "Be brief."}, nil, 0)
+	s.Append(client.Message{Role: "user", Content: "What is <b>2+2</b>?"}, nil, 0)
+	s.Append(client.Message{Role: "assistant", Content: "<think>Simple sum.</think>It is **4**."},
+		&client.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}, 1500*time.Millisecond)
+	return s
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, newSession(), Markdown, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+	for _, want := range []string{
+		"# What is <b>2+2</b>?",
+		"- **Model:** mlx_lm/qwen3-8b",
+		"temperature: 0.7, max_tokens: 512",
+		"- **Tokens:** 12 prompt, 5 completion",
+		"### You · ",
+		"### qwen3-8b · ",
+		"It is **4**.",
+		"*12 prompt + 5 completion tokens · 1.5s*",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "Simple sum.") {
+		t.Errorf("expected reasoning to be left out:\n%s", md)
+	}
+
+	buf.Reset()
+	Write(&buf, newSession(), Markdown, Options{Reasoning: true})
+	if !strings.Contains(buf.String(), "<summary>Thinking</summary>\n\nSimple sum.") {
+		t.Errorf("expected reasoning to be included:\n%s", buf.String())
+	}
+}
+
+func TestMarkdownFencesToolOutput(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "total 0", want: "```\ntotal 0\n```\n"},
+		{content: "```go\nfmt.Println()\n```", want: "````\n```go\nfmt.Println()\n```\n````\n"},
+		{content: "a ```` b", want: "`````\na ```` b\n`````\n"},
+	}
+	for _, tt := range tests {
+		s := session.New("mlx_lm", "qwen3-8b")
+		s.Append(client.Message{Role: "tool", Content: tt.content}, nil, 0)
+		if md := New(s, Options{}).Markdown(); !strings.Contains(md, tt.want) {
+			t.Errorf("expected %q in:\n%s", tt.want, md)
+		}
+	}
+}
+
+func TestCreateRefusesToOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.md")
+	if err := os.WriteFile(path, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(path, false); err == nil {
+		t.Fatal("expected an existing file to be kept")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep me" {
+		t.Errorf("expected the file to be untouched, got %q", data)
+	}
+
+	file, err := Create(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("new")
+	file.Close()
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("expected --force to overwrite the file, got %q", data)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, newSession(), JSON, Options{Reasoning: true}); err != nil {
+		t.Fatal(err)
+	}
+	var got Transcript
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 3 || got.Usage.CompletionTokens != 5 || got.Options == nil || *got.Options.MaxTokens != 512 {
+		t.Fatalf("unexpected transcript %+v", got)
+	}
+	answer := got.Messages[2]
+	if answer.Content != "It is **4**." || answer.Reasoning != "Simple sum." || answer.LatencyMS != 1500 || answer.Usage.PromptTokens != 12 {
+		t.Errorf("unexpected answer %+v", answer)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, newSession(), HTML, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if !strings.Contains(page, "<strong>4</strong>") {
+		t.Errorf("expected markdown to be rendered:\n%s", page)
+	}
+	if strings.Contains(page, "<b>2+2</b>") {
+		t.Errorf("expected raw HTML in messages to be escaped or left out:\n%s", page)
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	model, messages, err := ReadMessages(strings.NewReader(`{
+		"model": "gpt-4o",
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": [{"type": "text", "text": "Hi"}]},
+			{"role": "assistant", "content": "Hello!"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model != "gpt-4o" || len(messages) != 3 || messages[1].Content != "Hi" {
+		t.Fatalf("unexpected import %q %+v", model, messages)
+	}
+
+	s := Import(messages, "", model)
+	if s.Title != "Hi" || len(s.Messages()) != 3 || s.Head != 3 {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	// A bare list of messages works too
+	if _, messages, err := ReadMessages(strings.NewReader(`[{"role": "user", "content": "Hi"}]`)); err != nil || len(messages) != 1 {
+		t.Errorf("expected a single message, got %+v, %v", messages, err)
+	}
+	for _, input := range []string{`[]`, `{"messages": [{"role": "narrator", "content": "Hi"}]}`, `not json`} {
+		if _, _, err := ReadMessages(strings.NewReader(input)); err == nil {
+			t.Errorf("expected %s to be rejected", input)
+		}
+	}
+}
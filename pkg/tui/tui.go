@@ -0,0 +1,496 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/changminbark/golms/pkg/attach"
+	"github.com/changminbark/golms/pkg/chat"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/reasoning"
+	"github.com/changminbark/golms/pkg/session"
+	"github.com/changminbark/golms/pkg/ui"
+)
+
+const (
+	sidebarWidth = 30
+	inputHeight  = 3
+)
+
+type focus int
+
+type renderKey struct {
+	content string
+	width   int
+}
+
+const (
+	focusInput focus = iota
+	focusSidebar
+)
+
+// Messages produced by the background generation goroutine
+type (
+	streamDeltaMsg client.Delta
+	streamDoneMsg  struct {
+		resp    *client.ChatResponse
+		err     error
+		latency time.Duration
+	}
+)
+
+type model struct {
+	client      client.ModelServerClient
+	chatOptions client.ChatOptions
+	options     chat.Options
+	session     *session.Session
+	store       *session.Store
+
+	// Rendered markdown of finished turns, keyed by content and width
+	rendered map[renderKey]string
+
+	// Sidebar of saved sessions
+	sessions []*session.Session
+	cursor   int
+	focus    focus
+
+	viewport viewport.Model
+	input    textarea.Model
+	width    int
+	height   int
+
+	// In-flight generation
+	streaming bool
+	cancel    context.CancelFunc
+	stream    chan tea.Msg
+	partial   client.Message
+	started   time.Time
+	// quitting quits once the generation has finished
+	quitting bool
+
+	lastLatency time.Duration
+	err         error
+}
+
+// Run starts the full-screen chat TUI. Turns are recorded in s and saved to
+// store after every response when store is non-nil.
+func Run(c client.ModelServerClient, s *session.Session, store *session.Store, chatOptions client.ChatOptions, options chat.Options) error {
+	m := newModel(c, s, store, chatOptions, options)
+	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+	return err
+}
+
+func newModel(c client.ModelServerClient, s *session.Session, store *session.Store, chatOptions client.ChatOptions, options chat.Options) *model {
+	// Responses are always rendered live in the TUI
+	chatOptions.Stream = true
+
+	input := textarea.New()
+	input.Placeholder = "Send a message (enter to send, alt+enter for a new line)"
+	input.ShowLineNumbers = false
+	input.CharLimit = 0
+	input.SetHeight(inputHeight)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	input.Focus()
+
+	m := &model{
+		client:      c,
+		chatOptions: chatOptions,
+		options:     options,
+		session:     s,
+		store:       store,
+		rendered:    map[renderKey]string{},
+		viewport:    viewport.New(0, 0),
+		input:       input,
+	}
+	chat.StartSession(m.session, m.options)
+	m.refreshSessions()
+	return m
+}
+
+func (m *model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			// Cancel the generation in progress, or quit when there is none.
+			// esc quits too once the cancelled generation has been rolled back.
+			if m.streaming {
+				m.cancel()
+				m.quitting = msg.String() == "esc"
+				return m, nil
+			}
+			return m, tea.Quit
+		case "tab":
+			m.toggleFocus()
+			return m, nil
+		case "ctrl+t":
+			m.cycleReasoning()
+			return m, nil
+		case "ctrl+n":
+			if !m.streaming {
+				m.session = session.New(m.session.Server, m.client.LLM())
+				chat.StartSession(m.session, m.options)
+				m.err = nil
+				m.renderConversation()
+			}
+			return m, nil
+		}
+
+		if m.focus == focusSidebar {
+			m.updateSidebar(msg)
+			return m, nil
+		}
+
+		if msg.String() == "enter" {
+			return m, m.send()
+		}
+
+	case streamDeltaMsg:
+		m.partial.Content += msg.Content
+		m.partial.ReasoningContent += msg.Reasoning
+		m.renderConversation()
+		return m, waitForStream(m.stream)
+
+	case streamDoneMsg:
+		m.finishStream(msg)
+		if m.quitting {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	// Forward remaining input to the focused components
+	var cmd tea.Cmd
+	if m.focus == focusInput {
+		m.input, cmd = m.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	m.viewport, cmd = m.viewport.Update(msg)
+	cmds = append(cmds, cmd)
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return "Loading..."
+	}
+
+	sidebarStyle, mainStyle := ui.PaneStyle, ui.FocusedPaneStyle
+	if m.focus == focusSidebar {
+		sidebarStyle, mainStyle = ui.FocusedPaneStyle, ui.PaneStyle
+	}
+
+	sidebar := sidebarStyle.
+		Width(sidebarWidth - 2).
+		Height(m.height - 3).
+		Render(m.sidebarView())
+
+	main := lipgloss.JoinVertical(lipgloss.Left,
+		ui.PaneStyle.Render(m.viewport.View()),
+		mainStyle.Render(m.input.View()),
+	)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, sidebar, main),
+		m.statusView(),
+	)
+}
+
+// layout resizes the components to fit the terminal
+func (m *model) layout() {
+	mainWidth := max(m.width-sidebarWidth-2, 10)
+	m.input.SetWidth(mainWidth)
+	m.viewport.Width = mainWidth
+	// Two borders around each of the viewport and the input, plus the status bar
+	m.viewport.Height = max(m.height-inputHeight-5, 1)
+	m.renderConversation()
+}
+
+func (m *model) toggleFocus() {
+	if m.focus == focusInput {
+		m.focus = focusSidebar
+		m.input.Blur()
+	} else {
+		m.focus = focusInput
+		m.input.Focus()
+	}
+}
+
+func (m *model) updateSidebar(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.sessions)-1 {
+			m.cursor++
+		}
+	case "enter":
+		// Switching sessions mid-generation would record the answer in the wrong one
+		if m.streaming || len(m.sessions) == 0 {
+			return
+		}
+		selected := m.sessions[m.cursor]
+		if !m.canContinue(selected) {
+			m.err = fmt.Errorf("this session was saved with %s, connect to that model to continue it", selected.Model)
+			return
+		}
+		m.session = selected
+		m.err = nil
+		m.renderConversation()
+		m.toggleFocus()
+	}
+}
+
+// canContinue reports whether s was saved with the connected model, so its
+// conversation can be sent to it. Imported sessions may name no model.
+func (m *model) canContinue(s *session.Session) bool {
+	return (s.Model == "" || s.Model == m.client.LLM()) && (s.Server == "" || s.Server == m.session.Server)
+}
+
+// send starts generating a response to the text in the input
+func (m *model) send() tea.Cmd {
+	content := strings.TrimSpace(m.input.Value())
+	if content == "" || m.streaming {
+		return nil
+	}
+
+	// Attach files referenced as @path, keeping the input so it can be fixed on error
+	attachments, err := attach.ExpandReferences(content)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	content = attach.Compose(content, attachments)
+	m.input.Reset()
+	m.err = nil
+
+	m.session.Append(client.Message{Role: "user", Content: content}, nil, 0)
+	chatReq := client.NewChatRequest(m.chatOptions)
+	chatReq.Messages = reasoning.PrepareContext(m.session.Messages(), m.options.ReasoningContext)
+
+	m.streaming = true
+	m.partial = client.Message{}
+	m.started = time.Now()
+	m.stream = make(chan tea.Msg, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.renderConversation()
+
+	go func(stream chan<- tea.Msg, started time.Time) {
+		defer cancel()
+		resp, err := m.client.Chat(ctx, chatReq, func(delta client.Delta) {
+			stream <- streamDeltaMsg(delta)
+		})
+		stream <- streamDoneMsg{resp: resp, err: err, latency: time.Since(started)}
+	}(m.stream, m.started)
+
+	return waitForStream(m.stream)
+}
+
+func (m *model) finishStream(msg streamDoneMsg) {
+	m.streaming = false
+	m.partial = client.Message{}
+	m.lastLatency = msg.latency
+
+	if msg.err != nil {
+		// Drop the unanswered message from the conversation, keeping it as a branch
+		if turn, ok := m.session.LastTurn("user"); ok {
+			m.session.Checkout(turn.Parent)
+		}
+		switch hint := chat.ErrorHint(msg.err); {
+		case errors.Is(msg.err, context.Canceled):
+			m.err = errors.New("generation cancelled")
+		case hint != "":
+			m.err = fmt.Errorf("%w. %s", msg.err, hint)
+		default:
+			m.err = msg.err
+		}
+	} else {
+		m.session.Append(msg.resp.Choices[0].Message, &msg.resp.Usage, msg.latency)
+		options := m.chatOptions.Overrides()
+		m.session.Options = &options
+		if m.store != nil {
+			if err := m.store.Save(m.session); err != nil {
+				m.err = fmt.Errorf("failed to save session: %w", err)
+			} else if m.options.Index != nil {
+				if err := m.options.Index.Update(m.session); err != nil {
+					m.err = fmt.Errorf("failed to update search index: %w", err)
+				}
+			}
+		}
+		m.refreshSessions()
+	}
+	m.renderConversation()
+}
+
+// waitForStream returns a command that delivers the next generation event
+func waitForStream(stream <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-stream
+	}
+}
+
+func (m *model) refreshSessions() {
+	if m.store == nil {
+		return
+	}
+	sessions, err := m.store.List()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.sessions = sessions
+	m.cursor = min(m.cursor, max(len(m.sessions)-1, 0))
+}
+
+// renderConversation redraws the message viewport from the session
+func (m *model) renderConversation() {
+	width := max(m.viewport.Width-2, 10)
+	wrap := lipgloss.NewStyle().Width(width)
+
+	var b strings.Builder
+	for _, turn := range m.session.Path() {
+		switch turn.Message.Role {
+		case "user":
+			b.WriteString(ui.UserStyle.Render("You:") + "\n")
+			b.WriteString(wrap.Render(turn.Message.Content) + "\n\n")
+		case "assistant":
+			thought, answer := reasoning.FromMessage(turn.Message)
+			b.WriteString(ui.AIStyle.Render(m.client.LLM()+":") + "\n")
+			b.WriteString(m.renderReasoning(thought, width))
+			b.WriteString(m.renderResponse(answer, width) + "\n\n")
+		}
+	}
+	// Partial responses are shown as plain text until they are complete
+	if m.streaming {
+		thought, answer := reasoning.FromMessage(m.partial)
+		b.WriteString(ui.AIStyle.Render(m.client.LLM()+":") + "\n")
+		b.WriteString(m.renderReasoning(thought, width))
+		b.WriteString(wrap.Render(answer+"▌") + "\n")
+	}
+
+	m.viewport.SetContent(b.String())
+	m.viewport.GotoBottom()
+}
+
+// renderReasoning renders a response's reasoning according to the display mode
+func (m *model) renderReasoning(thought string, width int) string {
+	if thought == "" {
+		return ""
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+	switch m.options.Reasoning {
+	case reasoning.Show:
+		return ui.RenderLines(ui.ReasoningStyle, wrap.Render("Thinking:\n"+thought)) + "\n\n"
+	case reasoning.Collapse:
+		return ui.FormatCollapsedReasoning(thought, "ctrl+t to hide or show") + "\n"
+	}
+	return ""
+}
+
+// cycleReasoning switches between showing, collapsing and hiding reasoning
+func (m *model) cycleReasoning() {
+	switch m.options.Reasoning {
+	case reasoning.Show:
+		m.options.Reasoning = reasoning.Collapse
+	case reasoning.Collapse:
+		m.options.Reasoning = reasoning.Hide
+	default:
+		m.options.Reasoning = reasoning.Show
+	}
+	m.renderConversation()
+}
+
+// renderResponse renders a finished response as markdown unless raw output was requested
+func (m *model) renderResponse(content string, width int) string {
+	if m.options.Raw {
+		return lipgloss.NewStyle().Width(width).Render(content)
+	}
+
+	key := renderKey{content, width}
+	if rendered, ok := m.rendered[key]; ok {
+		return rendered
+	}
+	rendered, err := ui.RenderMarkdown(content, width)
+	if err != nil {
+		rendered = lipgloss.NewStyle().Width(width).Render(content)
+	}
+	m.rendered[key] = rendered
+	return rendered
+}
+
+func (m *model) sidebarView() string {
+	var b strings.Builder
+	b.WriteString(ui.HeaderStyle.Render("Sessions") + "\n")
+	if len(m.sessions) == 0 {
+		b.WriteString(ui.SubtleStyle.Render("No saved sessions"))
+	}
+	for i, s := range m.sessions {
+		title := s.Title
+		if title == "" {
+			title = s.ID
+		}
+		title = truncate(title, sidebarWidth-6)
+
+		line := "  " + title
+		if m.focus == focusSidebar && i == m.cursor {
+			line = ui.SelectedStyle.Render("▸ " + title)
+		} else if s.ID == m.session.ID {
+			line = ui.AIStyle.Render("• " + title)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n" + ui.SubtleStyle.Render("tab: focus  ctrl+n: new\nctrl+t: toggle thinking"))
+	return b.String()
+}
+
+func (m *model) statusView() string {
+	usage := m.session.TotalUsage()
+	parts := []string{
+		"model: " + m.client.LLM(),
+		fmt.Sprintf("tokens: %d", usage.TotalTokens),
+		"thinking: " + string(m.options.Reasoning),
+	}
+	if m.lastLatency > 0 {
+		parts = append(parts, fmt.Sprintf("latency: %s", m.lastLatency.Round(time.Millisecond)))
+	}
+	if m.streaming {
+		parts = append(parts, "generating...")
+	}
+
+	status := ui.StatusBarStyle.Render(strings.Join(parts, "  │  "))
+	if m.err != nil {
+		status += ui.ErrorStyle.Render("✗ " + m.err.Error())
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(status)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
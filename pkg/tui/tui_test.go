@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"net/http"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/changminbark/golms/pkg/chat"
+	"github.com/changminbark/golms/pkg/client"
+	"github.com/changminbark/golms/pkg/constants"
+	"github.com/changminbark/golms/pkg/fakeserver"
+	"github.com/changminbark/golms/pkg/session"
+)
+
+// newTestModel returns a model chatting with server and saving sessions
+// under a temporary home directory
+func newTestModel(t *testing.T, server *fakeserver.Server) *model {
+	t.Setenv("HOME", t.TempDir())
+	store, err := session.NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(constants.Mlx_lm, "test", server.Host(), server.Port())
+	return newModel(c, session.New(constants.Mlx_lm, "test"), store, client.DefaultChatOptions, chat.Options{})
+}
+
+// run delivers the messages of cmd to the model until the generation finishes
+func run(m *model, cmd tea.Cmd) {
+	for cmd != nil {
+		msg := cmd()
+		_, cmd = m.Update(msg)
+		if _, done := msg.(streamDoneMsg); done {
+			return
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        fakeserver.Reply
+		wantMessages int
+		wantErr      bool
+	}{
+		{name: "answered", reply: fakeserver.Reply{Message: client.Message{Content: "Hello!"}}, wantMessages: 2},
+		{name: "failed", reply: fakeserver.Reply{Status: http.StatusInternalServerError, Body: "out of memory"}, wantMessages: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeserver.New(t, tt.reply)
+			m := newTestModel(t, server)
+			m.input.SetValue("Hi")
+			run(m, m.send())
+
+			if m.streaming {
+				t.Error("expected the generation to be finished")
+			}
+			if (m.err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", m.err, tt.wantErr)
+			}
+			// A failed message is dropped so it can be sent again
+			if got := len(m.session.Messages()); got != tt.wantMessages {
+				t.Errorf("got %d messages, want %d", got, tt.wantMessages)
+			}
+			if requests := server.Requests(); len(requests) != 1 || !requests[0].Stream {
+				t.Errorf("expected one streamed request, got %+v", requests)
+			}
+
+			saved, err := m.store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantSaved := !tt.wantErr; (len(saved) == 1) != wantSaved || len(m.sessions) != len(saved) {
+				t.Errorf("got %d saved and %d listed sessions, want saved %v", len(saved), len(m.sessions), wantSaved)
+			}
+		})
+	}
+}
+
+func TestEscapeFinishesTheGenerationBeforeQuitting(t *testing.T) {
+	server := fakeserver.New(t, fakeserver.Reply{Message: client.Message{Content: "Hello!"}})
+	m := newTestModel(t, server)
+	m.input.SetValue("Hi")
+	cmd := m.send()
+
+	if _, quit := m.Update(tea.KeyMsg{Type: tea.KeyEsc}); quit != nil {
+		t.Fatal("expected esc to wait for the generation")
+	}
+	for {
+		msg := cmd()
+		_, cmd = m.Update(msg)
+		if _, done := msg.(streamDoneMsg); done {
+			break
+		}
+	}
+	if cmd == nil {
+		t.Fatal("expected to quit once the generation finished")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatal("expected to quit once the generation finished")
+	}
+
+	// The turn is either rolled back or answered and saved, never left half done
+	saved, err := m.store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch messages := len(m.session.Messages()); {
+	case messages == 0 && len(saved) == 0:
+	case messages == 2 && len(saved) == 1:
+	default:
+		t.Errorf("got %d messages and %d saved sessions", messages, len(saved))
+	}
+}
+
+func TestSidebarOpensSessionsOfTheModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   string
+		model    string
+		wantOpen bool
+	}{
+		{name: "same model", server: constants.Mlx_lm, model: "test", wantOpen: true},
+		{name: "imported without a model", wantOpen: true},
+		{name: "other model", server: constants.Mlx_lm, model: "other", wantOpen: false},
+		{name: "other server", server: constants.Ollama, model: "test", wantOpen: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, fakeserver.New(t))
+			saved := session.New(tt.server, tt.model)
+			saved.Append(client.Message{Role: "user", Content: "Hi"}, nil, 0)
+			if err := m.store.Save(saved); err != nil {
+				t.Fatal(err)
+			}
+			m.refreshSessions()
+			current := m.session
+
+			m.toggleFocus()
+			m.updateSidebar(tea.KeyMsg{Type: tea.KeyEnter})
+			if opened := m.session.ID == saved.ID; opened != tt.wantOpen {
+				t.Errorf("opened = %v, want %v", opened, tt.wantOpen)
+			}
+			if !tt.wantOpen && (m.session != current || m.err == nil) {
+				t.Error("expected the current session to be kept with an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+const defaultWidth = 80
+
+var (
+	renderersMu sync.Mutex
+	renderers   = map[int]*glamour.TermRenderer{}
+)
+
+// TerminalWidth returns the width of the terminal attached to stdout,
+// falling back to 80 columns when it cannot be determined
+func TerminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return defaultWidth
+	}
+	return width
+}
+
+// TerminalHeight returns the height of the terminal attached to stdout, or 0
+// when it cannot be determined
+func TerminalHeight() int {
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
+// IsTerminal reports whether stdout is attached to a terminal
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// RenderMarkdown renders markdown for the terminal with syntax-highlighted
+// code blocks, wrapped to the given width
+func RenderMarkdown(content string, width int) (string, error) {
+	renderer, err := markdownRenderer(width)
+	if err != nil {
+		return "", err
+	}
+
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	rendered, err := renderer.Render(content)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(rendered, "\n"), nil
+}
+
+// FormatAIMarkdown formats an AI message in chat with its content rendered as markdown.
+// Falls back to the plain message box if rendering fails.
+func FormatAIMarkdown(llmName, message string, width int) string {
+	rendered, err := RenderMarkdown(message, width)
+	if err != nil {
+		return FormatAIMessage(llmName, message)
+	}
+	return AIStyle.Render(llmName+":") + "\n" + rendered + "\n"
+}
+
+// CountRows returns how many terminal rows text occupies once wrapped at width
+func CountRows(text string, width int) int {
+	if width <= 0 {
+		width = defaultWidth
+	}
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		lineWidth := runewidth.StringWidth(line)
+		if lineWidth == 0 {
+			rows++
+			continue
+		}
+		rows += (lineWidth + width - 1) / width
+	}
+	return rows
+}
+
+// CanClear reports whether text printed to the terminal can still be cleared,
+// which it can't once its first rows scrolled off the screen
+func CanClear(text string) bool {
+	return CountRows(text, TerminalWidth()) < TerminalHeight()
+}
+
+// ClearRows moves the cursor up the given number of rows and clears everything below it
+func ClearRows(rows int) string {
+	if rows <= 1 {
+		return "\r\x1b[J"
+	}
+	return fmt.Sprintf("\r\x1b[%dA\x1b[J", rows-1)
+}
+
+func markdownRenderer(width int) (*glamour.TermRenderer, error) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	if renderer, ok := renderers[width]; ok {
+		return renderer, nil
+	}
+	// Glamour pads the document with a margin on each side
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(max(width-4, 20)),
+		glamour.WithEmoji(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create markdown renderer: %w", err)
+	}
+	renderers[width] = renderer
+	return renderer, nil
+}
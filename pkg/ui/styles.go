@@ -1,6 +1,9 @@
 package ui
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -9,7 +12,7 @@ var (
 	subtle      = lipgloss.AdaptiveColor{Light: "#9B9B9B", Dark: "#5C5C5C"}
 	highlight   = lipgloss.AdaptiveColor{Light: "#ac64d6ff", Dark: "#752d9fff"}
 	success     = lipgloss.AdaptiveColor{Light: "#43BF6D", Dark: "#73D788"}
-	error       = lipgloss.AdaptiveColor{Light: "#FF4545", Dark: "#FF6B6B"}
+	errorColor  = lipgloss.AdaptiveColor{Light: "#FF4545", Dark: "#FF6B6B"}
 	warning     = lipgloss.AdaptiveColor{Light: "#FFA500", Dark: "#FFB84D"}
 	userColor   = lipgloss.AdaptiveColor{Light: "#5B5B5B", Dark: "#D4D4D4"}
 	aiColor     = lipgloss.AdaptiveColor{Light: "#4A90E2", Dark: "#6BA3E8"}
@@ -35,7 +38,7 @@ var (
 
 	// Error messages
 	ErrorStyle = lipgloss.NewStyle().
-			Foreground(error).
+			Foreground(errorColor).
 			Bold(true)
 
 	// Warning messages
@@ -55,6 +58,11 @@ var (
 	AIStyle = lipgloss.NewStyle().
 		Foreground(aiColor)
 
+	// Model reasoning shown alongside a response
+	ReasoningStyle = lipgloss.NewStyle().
+			Foreground(subtle).
+			Italic(true)
+
 	// Chat message box
 	MessageBoxStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -74,6 +82,25 @@ var (
 	PromptStyle = lipgloss.NewStyle().
 			Foreground(highlight).
 			Bold(true)
+
+	// Full-screen TUI panes
+	PaneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(borderColor)
+
+	// Pane that currently has keyboard focus
+	FocusedPaneStyle = PaneStyle.
+				BorderForeground(highlight)
+
+	// Status bar along the bottom of the TUI
+	StatusBarStyle = lipgloss.NewStyle().
+			Foreground(subtle).
+			Padding(0, 1)
+
+	// Highlighted entry in a list
+	SelectedStyle = lipgloss.NewStyle().
+			Foreground(highlight).
+			Bold(true)
 )
 
 // FormatHeader formats a header with optional subtitle
@@ -122,6 +149,48 @@ func FormatAIMessage(llmName, message string) string {
 	return MessageBoxStyle.Render(content)
 }
 
+// RenderLines applies a style to each line of s separately so that
+// multi-line text is not padded into a block
+func RenderLines(style lipgloss.Style, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = style.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatReasoning formats a model's reasoning in a dimmed style
+func FormatReasoning(reasoning string) string {
+	return RenderLines(ReasoningStyle, "Thinking:\n"+reasoning)
+}
+
+// FormatCollapsedReasoning formats a one-line placeholder for hidden reasoning,
+// followed by a hint on how to expand it when one is given
+func FormatCollapsedReasoning(reasoning string, hint string) string {
+	summary := fmt.Sprintf("▸ Thought for %d words", len(strings.Fields(reasoning)))
+	if hint != "" {
+		summary += " (" + hint + ")"
+	}
+	return ReasoningStyle.Render(summary)
+}
+
+// FormatToolCall formats a tool call requested by the model
+func FormatToolCall(name, arguments string) string {
+	return WarningStyle.Render("⚙ "+name) + " " + SubtleStyle.Render(arguments)
+}
+
+// FormatToolResult formats the first lines of a tool's output
+func FormatToolResult(name, output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	summary := fmt.Sprintf("↳ %s returned %d lines", name, len(lines))
+	if len(lines) == 1 && len(lines[0]) <= 80 {
+		summary = fmt.Sprintf("↳ %s: %s", name, lines[0])
+	}
+	return SubtleStyle.Render(summary)
+}
+
 // FormatInfoBox formats an informational box
 func FormatInfoBox(content string) string {
 	return InfoBoxStyle.Render(content)